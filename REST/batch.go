@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"goMS1Assignment/REST/database"
+	"goMS1Assignment/REST/render"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	maxBatchOps   = 1000    //operations accepted in one batch request
+	maxBatchBytes = 1 << 20 //size limit of a batch request body
+)
+
+//batchOp is one operation of a batch: the op name alongside the course fields it works on.
+//create needs every field, update changes the fields given and delete only needs the code.
+type batchOp struct {
+	Op                  string `json:"Op" yaml:"Op"`
+	database.CourseInfo `yaml:",inline"`
+}
+
+//batchResult reports what happened to one operation, with the status it would have had as a single request
+type batchResult struct {
+	Index   int    `json:"Index" yaml:"Index"` //1-based position in the batch
+	Op      string `json:"Op" yaml:"Op"`
+	Code    int    `json:"Code" yaml:"Code"`
+	Status  int    `json:"Status" yaml:"Status"`
+	Message string `json:"Message" yaml:"Message"`
+}
+
+//batchReport is the response to a batch request
+type batchReport struct {
+	Mode      string        `json:"Mode" yaml:"Mode"`
+	Total     int           `json:"Total" yaml:"Total"`
+	Succeeded int           `json:"Succeeded" yaml:"Succeeded"`
+	Failed    int           `json:"Failed" yaml:"Failed"`
+	Results   []batchResult `json:"Results" yaml:"Results"`
+}
+
+//errBatchAborted rolls back an atomic batch after one of its operations failed
+var errBatchAborted = errors.New("batch aborted")
+
+//batchCourses runs a list of create, update and delete operations in one request.
+//mode=atomic (the default) runs them in one transaction that is rolled back if any operation fails;
+//mode=best-effort runs each on its own and reports which ones failed.
+func batchCourses(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r) {
+		return
+	}
+	report := batchReport{Mode: r.URL.Query().Get("mode")}
+	if report.Mode == "" {
+		report.Mode = "atomic"
+	}
+	if report.Mode != "atomic" && report.Mode != "best-effort" {
+		render.Status(w, r, http.StatusBadRequest, "mode must be atomic or best-effort")
+		return
+	}
+
+	var ops []batchOp
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBytes)
+	if err := render.Decode(r, &ops); err != nil {
+		if err == render.ErrUnsupportedMediaType {
+			render.Status(w, r, http.StatusUnsupportedMediaType, "Please supply the operations as application/json or application/yaml")
+			return
+		}
+		log.Error("Error at batch function, ", err.Error())
+		render.Status(w, r, http.StatusUnprocessableEntity, "Please supply a list of operations: "+err.Error())
+		return
+	}
+	if len(ops) == 0 || len(ops) > maxBatchOps {
+		render.Status(w, r, http.StatusUnprocessableEntity, "Please supply between 1 and 1000 operations")
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	report.Total = len(ops)
+	report.Results = make([]batchResult, len(ops))
+	for i, op := range ops {
+		report.Results[i] = batchResult{Index: i + 1, Op: op.Op, Code: op.Code}
+	}
+
+	if report.Mode == "atomic" {
+		failed := -1
+		err := repository.InTransaction(ctx, func(tx database.Repository) error {
+			for i := range ops {
+				status, message, err := applyBatchOp(ctx, tx, &ops[i])
+				if err != nil {
+					return err
+				}
+				report.Results[i].Status, report.Results[i].Message = status, message
+				if status >= 300 {
+					failed = i
+					return errBatchAborted
+				}
+			}
+			return nil
+		})
+		if err != nil && err != errBatchAborted {
+			databaseError(w, r, err)
+			return
+		}
+		if err == errBatchAborted {
+			for i := range report.Results {
+				switch {
+				case i < failed:
+					report.Results[i].Status, report.Results[i].Message = http.StatusFailedDependency, "Rolled back, a later operation failed"
+				case i > failed:
+					report.Results[i].Status, report.Results[i].Message = http.StatusFailedDependency, "Not run, an earlier operation failed"
+				}
+			}
+		}
+	} else {
+		for i := range ops {
+			status, message, err := applyBatchOp(ctx, repository, &ops[i])
+			if err != nil {
+				log.Error("Error at batch function. ", err.Error())
+				status, message = http.StatusInternalServerError, "Database error"
+				if errors.Is(err, context.DeadlineExceeded) {
+					status, message = http.StatusGatewayTimeout, "Database did not respond in time"
+				}
+			}
+			report.Results[i].Status, report.Results[i].Message = status, message
+		}
+	}
+
+	for _, result := range report.Results {
+		if result.Status < 300 {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+	}
+	status := http.StatusOK
+	switch {
+	case report.Failed > 0 && report.Succeeded == 0:
+		status = http.StatusUnprocessableEntity
+	case report.Failed > 0:
+		status = http.StatusMultiStatus
+	}
+	render.Write(w, r, status, report)
+}
+
+//applyBatchOp validates and runs one operation through repo, checking it the same way the single course endpoint does.
+//It returns the status and message of the operation; err is only set when the database failed.
+func applyBatchOp(ctx context.Context, repo database.Repository, op *batchOp) (int, string, error) {
+	if op.Op != "create" && op.Op != "update" && op.Op != "delete" {
+		return http.StatusBadRequest, "Op must be create, update or delete", nil
+	}
+	if err := validateAndSanitize(&op.CourseInfo); err != nil {
+		return http.StatusUnprocessableEntity, "Course information in wrong format", nil
+	}
+	course, exist, err := repo.FindRecord(ctx, op.Code)
+	if err != nil {
+		return 0, "", err
+	}
+
+	switch op.Op {
+	case "create":
+		if exist {
+			return http.StatusConflict, "Duplicate course ID", nil
+		}
+		if op.Title == "" || op.Dates == "" || op.Lecturer == "" || op.Description == "" {
+			return http.StatusUnprocessableEntity, "Title, Dates, Lecturer and Description are required", nil
+		}
+		if err := repo.InsertRecord(ctx, op.CourseInfo); err != nil {
+			return 0, "", err
+		}
+		return http.StatusCreated, "Course added", nil
+	case "update":
+		if !exist {
+			return http.StatusNotFound, "No course found", nil
+		}
+		if op.Title == "" {
+			op.Title = course.Title
+		}
+		if op.Dates == "" {
+			op.Dates = course.Dates
+		}
+		if op.Lecturer == "" {
+			op.Lecturer = course.Lecturer
+		}
+		if op.Description == "" {
+			op.Description = course.Description
+		}
+		if err := repo.EditRecord(ctx, op.CourseInfo); err != nil {
+			return 0, "", err
+		}
+		return http.StatusAccepted, "Course updated", nil
+	default:
+		if !exist {
+			return http.StatusNotFound, "No course found", nil
+		}
+		if err := repo.DeleteRecord(ctx, op.Code); err != nil {
+			return 0, "", err
+		}
+		return http.StatusAccepted, "Course deleted", nil
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"goMS1Assignment/REST/database"
+)
+
+//batchStatuses returns the status of each operation of a batch report
+func batchStatuses(t *testing.T, body []byte) []int {
+	var report batchReport
+	if err := json.Unmarshal(body, &report); err != nil {
+		t.Fatalf("report %s: %v", body, err)
+	}
+	statuses := make([]int, len(report.Results))
+	for i, result := range report.Results {
+		statuses[i] = result.Status
+	}
+	return statuses
+}
+
+//batchOps creates course 10, updates course 1, deletes course 2 and then updates course 3, which does not exist
+const batchOps = `[
+	{"Op": "create", "Code": 10, "Title": "Go", "Dates": "Jan", "Lecturer": "Tan", "Description": "basics"},
+	{"Op": "update", "Code": 1, "Title": "Renamed"},
+	{"Op": "delete", "Code": 2},
+	{"Op": "update", "Code": 3, "Title": "Missing"},
+	{"Op": "delete", "Code": 1}
+]`
+
+//TestBatchModes checks a failing operation rolls back an atomic batch, with the operations around it reported as
+//failed dependencies, while a best-effort batch keeps the operations that succeeded
+func TestBatchModes(t *testing.T) {
+	tests := []struct {
+		mode         string
+		wantStatus   int
+		wantStatuses []int
+		wantTitles   map[int]string
+	}{
+		{"atomic", http.StatusUnprocessableEntity,
+			[]int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusFailedDependency, http.StatusNotFound, http.StatusFailedDependency},
+			map[int]string{1: "Web", 2: "Cloud"}},
+		{"best-effort", http.StatusMultiStatus,
+			[]int{http.StatusCreated, http.StatusAccepted, http.StatusAccepted, http.StatusNotFound, http.StatusAccepted},
+			map[int]string{10: "Go"}},
+	}
+	for _, tt := range tests {
+		repo := newMemoryRepository(database.CourseInfo{Code: 1, Title: "Web"}, database.CourseInfo{Code: 2, Title: "Cloud"})
+		w := serveBody(useRepository(t, repo), "POST", "/api/v1/courses/batch?key=k&mode="+tt.mode, jsonHeader, batchOps)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d: %s", tt.mode, w.Code, tt.wantStatus, w.Body.String())
+		}
+		if got := batchStatuses(t, w.Body.Bytes()); !reflect.DeepEqual(got, tt.wantStatuses) {
+			t.Errorf("%s: results %v, want %v", tt.mode, got, tt.wantStatuses)
+		}
+		courses, _ := repo.GetRecords(context.Background())
+		titles := make(map[int]string)
+		for code, course := range courses {
+			titles[code] = course.Title
+		}
+		if !reflect.DeepEqual(titles, tt.wantTitles) {
+			t.Errorf("%s: courses after the batch %v, want %v", tt.mode, titles, tt.wantTitles)
+		}
+	}
+}
+
+//TestBatchSucceeds checks an atomic batch whose operations all succeed is committed
+func TestBatchSucceeds(t *testing.T) {
+	repo := newMemoryRepository(database.CourseInfo{Code: 1, Title: "Web"}, database.CourseInfo{Code: 2, Title: "Cloud"})
+	body := `[{"Op": "update", "Code": 1, "Title": "Renamed"}, {"Op": "delete", "Code": 2}]`
+	w := serveBody(useRepository(t, repo), "POST", "/api/v1/courses/batch?key=k", jsonHeader, body)
+	if got := batchStatuses(t, w.Body.Bytes()); w.Code != http.StatusOK || !reflect.DeepEqual(got, []int{http.StatusAccepted, http.StatusAccepted}) {
+		t.Errorf("status %d, results %v", w.Code, got)
+	}
+	if course, _, _ := repo.FindRecord(context.Background(), 1); course.Title != "Renamed" {
+		t.Errorf("course 1 is %q after the batch", course.Title)
+	}
+	if _, exist, _ := repo.FindRecord(context.Background(), 2); exist {
+		t.Error("course 2 was not deleted")
+	}
+}
+
+//TestBatchDatabaseErrors checks a database failure rolls back an atomic batch with a 5xx, and fails only its own
+//operation in a best-effort batch
+func TestBatchDatabaseErrors(t *testing.T) {
+	body := `[{"Op": "update", "Code": 1, "Title": "Renamed"}, {"Op": "delete", "Code": 2}]`
+	for err, want := range map[error]int{errors.New("connection refused"): http.StatusInternalServerError, context.DeadlineExceeded: http.StatusGatewayTimeout} {
+		repo := newMemoryRepository(database.CourseInfo{Code: 1, Title: "Web"}, database.CourseInfo{Code: 2, Title: "Cloud"})
+		repo.fail[2] = err
+		router := useRepository(t, repo)
+		if w := serveBody(router, "POST", "/api/v1/courses/batch?key=k&mode=atomic", jsonHeader, body); w.Code != want {
+			t.Errorf("atomic batch, %v: status %d, want %d", err, w.Code, want)
+		}
+		if course, _, _ := repo.FindRecord(context.Background(), 1); course.Title != "Web" {
+			t.Errorf("atomic batch, %v: course 1 is %q, want the update rolled back", err, course.Title)
+		}
+
+		w := serveBody(router, "POST", "/api/v1/courses/batch?key=k&mode=best-effort", jsonHeader, body)
+		if got := batchStatuses(t, w.Body.Bytes()); w.Code != http.StatusMultiStatus || !reflect.DeepEqual(got, []int{http.StatusAccepted, want}) {
+			t.Errorf("best-effort batch, %v: status %d, results %v", err, w.Code, got)
+		}
+		if course, _, _ := repo.FindRecord(context.Background(), 1); course.Title != "Renamed" {
+			t.Errorf("best-effort batch, %v: course 1 is %q, want it renamed", err, course.Title)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+//TestLRUEviction checks the least recently used entry is the one evicted, where reads and writes both count as use
+func TestLRUEviction(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  string //space separated: s<key> sets, g<key> gets
+		kept string //keys still cached afterwards
+		gone string //keys evicted
+	}{
+		{"oldest write evicted", "sa sb sc sd", "b c d", "a"},
+		{"read keeps an entry", "sa sb sc ga sd", "a c d", "b"},
+		{"rewrite keeps an entry", "sa sb sc sa sd", "a c d", "b"},
+		{"several evictions", "sa sb sc sd se", "c d e", "a b"},
+		{"reading a missing key changes nothing", "sa sb sc gz sd", "b c d", "a"},
+	}
+	for _, tt := range tests {
+		c := NewLRU(3, time.Minute)
+		for _, op := range strings.Fields(tt.ops) {
+			switch op[0] {
+			case 's':
+				c.Set(op[1:], op)
+			case 'g':
+				c.Get(op[1:])
+			}
+		}
+		for _, key := range strings.Fields(tt.kept) {
+			if _, ok := c.Get(key); !ok {
+				t.Errorf("%s: %s was evicted", tt.name, key)
+			}
+		}
+		for _, key := range strings.Fields(tt.gone) {
+			if _, ok := c.Get(key); ok {
+				t.Errorf("%s: %s is still cached", tt.name, key)
+			}
+		}
+		if want := uint64(len(strings.Fields(tt.gone))); c.Stats().Evictions != want {
+			t.Errorf("%s: %d evictions counted, want %d", tt.name, c.Stats().Evictions, want)
+		}
+	}
+}
+
+//TestLRUExpiry checks entries are not served after their TTL and that a rewrite starts the TTL again
+func TestLRUExpiry(t *testing.T) {
+	ttl := 50 * time.Millisecond
+	c := NewLRU(10, ttl)
+	c.Set("old", 1)
+	c.Set("rewritten", 1)
+	time.Sleep(ttl * 3 / 5)
+	c.Set("rewritten", 2)
+	c.Set("young", 3)
+	time.Sleep(ttl * 3 / 5)
+
+	if _, ok := c.Get("old"); ok {
+		t.Error("expired entry served")
+	}
+	if v, ok := c.Get("rewritten"); !ok || v != 2 {
+		t.Errorf("rewritten entry: got %v, %v; want 2, true", v, ok)
+	}
+	if _, ok := c.Get("young"); !ok {
+		t.Error("entry served before its TTL was over is missing")
+	}
+	stats := c.Stats()
+	if stats.Entries != 2 {
+		t.Errorf("%d entries left, want the expired one dropped", stats.Entries)
+	}
+	if stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("%d hits and %d misses, want 2 and 1", stats.Hits, stats.Misses)
+	}
+}
+
+//TestLRUDelete checks deleted entries are gone and deleting a missing key is harmless
+func TestLRUDelete(t *testing.T) {
+	c := NewLRU(3, time.Minute)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Delete("a")
+	c.Delete("missing")
+	if _, ok := c.Get("a"); ok {
+		t.Error("deleted entry served")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Error("other entry lost")
+	}
+	if n := c.Stats().Entries; n != 1 {
+		t.Errorf("%d entries, want 1", n)
+	}
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"goMS1Assignment/REST/database"
+)
+
+const allCoursesKey = "courses"
+
+//lookup is what is cached for a single course code, including the fact that it does not exist
+type lookup struct {
+	course database.CourseInfo
+	exists bool
+}
+
+//Repository is a read-through cache in front of another course repository.
+//Writes go to the wrapped repository and invalidate the affected entries.
+type Repository struct {
+	next  database.Repository
+	cache Cache
+
+	//generation is bumped by every write so a read that started before the write does not cache stale data
+	mu         sync.Mutex
+	generation uint64
+}
+
+//NewRepository wraps next with cache
+func NewRepository(next database.Repository, cache Cache) *Repository {
+	return &Repository{next: next, cache: cache}
+}
+
+//Stats returns the hit and miss counters of the underlying cache
+func (r *Repository) Stats() Stats {
+	return r.cache.Stats()
+}
+
+func courseKey(code int) string {
+	return "course:" + strconv.Itoa(code)
+}
+
+func (r *Repository) currentGeneration() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generation
+}
+
+//store caches value unless a write happened since generation was read
+func (r *Repository) store(generation uint64, key string, value interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.generation == generation {
+		r.cache.Set(key, value)
+	}
+}
+
+func (r *Repository) invalidate(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generation++
+	r.cache.Delete(courseKey(code))
+	r.cache.Delete(allCoursesKey)
+}
+
+//GetRecords returns all courses, from the cache when possible
+func (r *Repository) GetRecords(ctx context.Context) (map[int]database.CourseInfo, error) {
+	if cached, ok := r.cache.Get(allCoursesKey); ok {
+		return copyCourses(cached.(map[int]database.CourseInfo)), nil
+	}
+	generation := r.currentGeneration()
+	courses, err := r.next.GetRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r.store(generation, allCoursesKey, copyCourses(courses))
+	return courses, nil
+}
+
+//FindRecord returns a course and whether it exists, from the cache when possible
+func (r *Repository) FindRecord(ctx context.Context, code int) (database.CourseInfo, bool, error) {
+	if cached, ok := r.cache.Get(courseKey(code)); ok {
+		l := cached.(lookup)
+		return l.course, l.exists, nil
+	}
+	generation := r.currentGeneration()
+	course, exists, err := r.next.FindRecord(ctx, code)
+	if err != nil {
+		return course, false, err
+	}
+	r.store(generation, courseKey(code), lookup{course: course, exists: exists})
+	return course, exists, nil
+}
+
+//EachRecord streams courses from the wrapped repository; streamed listings are not cached
+func (r *Repository) EachRecord(ctx context.Context, filter database.Filter, fn func(database.CourseInfo) error) error {
+	return r.next.EachRecord(ctx, filter, fn)
+}
+
+//InsertRecord creates the course and invalidates the cached entries it affects
+func (r *Repository) InsertRecord(ctx context.Context, course database.CourseInfo) error {
+	defer r.invalidate(course.Code)
+	return r.next.InsertRecord(ctx, course)
+}
+
+//EditRecord updates the course and invalidates the cached entries it affects
+func (r *Repository) EditRecord(ctx context.Context, course database.CourseInfo) error {
+	defer r.invalidate(course.Code)
+	return r.next.EditRecord(ctx, course)
+}
+
+//DeleteRecord deletes the course and invalidates the cached entries it affects
+func (r *Repository) DeleteRecord(ctx context.Context, code int) error {
+	defer r.invalidate(code)
+	return r.next.DeleteRecord(ctx, code)
+}
+
+//InTransaction runs fn in a transaction of the wrapped repository; reads inside it bypass the cache.
+//The courses written are invalidated once the transaction has finished.
+func (r *Repository) InTransaction(ctx context.Context, fn func(tx database.Repository) error) error {
+	recorder := &database.Recorder{}
+	defer func() {
+		for _, change := range recorder.Changes {
+			r.invalidate(change.Course.Code)
+		}
+	}()
+	return r.next.InTransaction(ctx, func(tx database.Repository) error {
+		recorder.Repository = tx
+		return fn(recorder)
+	})
+}
+
+//copyCourses keeps callers from modifying a map that is shared through the cache
+func copyCourses(courses map[int]database.CourseInfo) map[int]database.CourseInfo {
+	copied := make(map[int]database.CourseInfo, len(courses))
+	for code, course := range courses {
+		copied[code] = course
+	}
+	return copied
+}
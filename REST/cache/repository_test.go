@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"goMS1Assignment/REST/database"
+)
+
+//fakeRepository keeps courses in a map and counts the reads that reach it
+type fakeRepository struct {
+	courses map[int]database.CourseInfo
+	lists   int //GetRecords calls
+	finds   int //FindRecord calls
+	//duringRead, if set, runs in the middle of a read, to make a write race with it
+	duringRead func()
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{courses: map[int]database.CourseInfo{
+		1: {Code: 1, Title: "Go"},
+		2: {Code: 2, Title: "Rust"},
+	}}
+}
+
+func (f *fakeRepository) GetRecords(ctx context.Context) (map[int]database.CourseInfo, error) {
+	f.lists++
+	courses := copyCourses(f.courses)
+	if f.duringRead != nil {
+		f.duringRead()
+	}
+	return courses, nil
+}
+
+func (f *fakeRepository) FindRecord(ctx context.Context, code int) (database.CourseInfo, bool, error) {
+	f.finds++
+	course, ok := f.courses[code]
+	if f.duringRead != nil {
+		f.duringRead()
+	}
+	return course, ok, nil
+}
+
+func (f *fakeRepository) EachRecord(ctx context.Context, filter database.Filter, fn func(database.CourseInfo) error) error {
+	for _, course := range f.courses {
+		if err := fn(course); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeRepository) InsertRecord(ctx context.Context, course database.CourseInfo) error {
+	if _, ok := f.courses[course.Code]; ok {
+		return errors.New("duplicate")
+	}
+	f.courses[course.Code] = course
+	return nil
+}
+
+func (f *fakeRepository) EditRecord(ctx context.Context, course database.CourseInfo) error {
+	f.courses[course.Code] = course
+	return nil
+}
+
+func (f *fakeRepository) DeleteRecord(ctx context.Context, code int) error {
+	delete(f.courses, code)
+	return nil
+}
+
+func (f *fakeRepository) InTransaction(ctx context.Context, fn func(tx database.Repository) error) error {
+	return fn(f)
+}
+
+//TestRepositoryReadThrough checks repeated reads are answered from the cache, including for missing courses
+func TestRepositoryReadThrough(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeRepository()
+	r := NewRepository(fake, NewLRU(10, time.Minute))
+
+	for i := 0; i < 3; i++ {
+		if courses, _ := r.GetRecords(ctx); len(courses) != 2 {
+			t.Fatalf("GetRecords returned %d courses, want 2", len(courses))
+		}
+		if course, ok, _ := r.FindRecord(ctx, 1); !ok || course.Title != "Go" {
+			t.Fatalf("FindRecord(1) = %+v, %v", course, ok)
+		}
+		if _, ok, _ := r.FindRecord(ctx, 9); ok {
+			t.Fatal("FindRecord(9) found a course that does not exist")
+		}
+	}
+	if fake.lists != 1 || fake.finds != 2 {
+		t.Errorf("%d list and %d find calls reached the repository, want 1 and 2", fake.lists, fake.finds)
+	}
+
+	//callers may change what they get without changing the cache
+	courses, _ := r.GetRecords(ctx)
+	delete(courses, 1)
+	if courses, _ := r.GetRecords(ctx); len(courses) != 2 {
+		t.Errorf("changing a returned map changed the cached one")
+	}
+}
+
+//TestRepositoryInvalidation checks every kind of write drops the cached list and the course written, and nothing else
+func TestRepositoryInvalidation(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		write func(r *Repository) error
+		code  int
+		check func(course database.CourseInfo, exists bool) bool
+	}{
+		{"insert", func(r *Repository) error {
+			return r.InsertRecord(ctx, database.CourseInfo{Code: 3, Title: "Zig"})
+		}, 3, func(c database.CourseInfo, ok bool) bool { return ok && c.Title == "Zig" }},
+		{"edit", func(r *Repository) error {
+			return r.EditRecord(ctx, database.CourseInfo{Code: 1, Title: "Go 2"})
+		}, 1, func(c database.CourseInfo, ok bool) bool { return ok && c.Title == "Go 2" }},
+		{"delete", func(r *Repository) error {
+			return r.DeleteRecord(ctx, 1)
+		}, 1, func(c database.CourseInfo, ok bool) bool { return !ok }},
+		{"transaction", func(r *Repository) error {
+			return r.InTransaction(ctx, func(tx database.Repository) error {
+				return tx.EditRecord(ctx, database.CourseInfo{Code: 1, Title: "Go 3"})
+			})
+		}, 1, func(c database.CourseInfo, ok bool) bool { return ok && c.Title == "Go 3" }},
+	}
+	for _, tt := range tests {
+		fake := newFakeRepository()
+		r := NewRepository(fake, NewLRU(10, time.Minute))
+		//fill the cache, including the fact that course 3 does not exist yet
+		r.GetRecords(ctx)
+		r.FindRecord(ctx, tt.code)
+		r.FindRecord(ctx, 2)
+
+		if err := tt.write(r); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		course, exists, _ := r.FindRecord(ctx, tt.code)
+		if !tt.check(course, exists) {
+			t.Errorf("%s: FindRecord(%d) = %+v, %v from a stale cache entry", tt.name, tt.code, course, exists)
+		}
+		courses, _ := r.GetRecords(ctx)
+		if listed, ok := courses[tt.code]; ok != exists || listed != course {
+			t.Errorf("%s: GetRecords holds %+v, %v for course %d, want %+v, %v", tt.name, listed, ok, tt.code, course, exists)
+		}
+		finds := fake.finds
+		r.FindRecord(ctx, 2)
+		if fake.finds != finds {
+			t.Errorf("%s: course 2 was invalidated too", tt.name)
+		}
+	}
+}
+
+//TestRepositoryWriteDuringRead checks a read that started before a write does not put its old result in the cache
+func TestRepositoryWriteDuringRead(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeRepository()
+	r := NewRepository(fake, NewLRU(10, time.Minute))
+	fake.duringRead = func() {
+		fake.duringRead = nil
+		r.EditRecord(ctx, database.CourseInfo{Code: 1, Title: "Go 2"})
+	}
+
+	if course, _, _ := r.FindRecord(ctx, 1); course.Title != "Go" {
+		t.Fatalf("the racing read got %q, want the value it read", course.Title)
+	}
+	if course, _, _ := r.FindRecord(ctx, 1); course.Title != "Go 2" {
+		t.Errorf("read after the write got %q from the cache, want Go 2", course.Title)
+	}
+}
+
+//TestRepositoryExpiry checks entries are read again from the repository once the TTL is over
+func TestRepositoryExpiry(t *testing.T) {
+	ctx := context.Background()
+	fake := newFakeRepository()
+	r := NewRepository(fake, NewLRU(10, 20*time.Millisecond))
+	r.FindRecord(ctx, 1)
+	//a change made behind the cache's back shows once the entry expires
+	fake.courses[1] = database.CourseInfo{Code: 1, Title: "Go 2"}
+	if course, _, _ := r.FindRecord(ctx, 1); course.Title != "Go" {
+		t.Errorf("got %q before the TTL was over, want the cached Go", course.Title)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if course, _, _ := r.FindRecord(ctx, 1); course.Title != "Go 2" {
+		t.Errorf("got %q after the TTL was over, want Go 2", course.Title)
+	}
+}
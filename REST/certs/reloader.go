@@ -0,0 +1,122 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//Reloader serves a TLS certificate pair that can be swapped while the server keeps running
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	notAfter time.Time
+	loadedAt time.Time
+}
+
+//NewReloader loads the certificate pair once and fails if it cannot be parsed
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+//Reload reads the certificate pair from disk. The current certificate is kept if the new pair fails to parse.
+func (r *Reloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+	cert.Leaf = leaf
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.notAfter = leaf.NotAfter
+	r.loadedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+//GetCertificate is used as tls.Config.GetCertificate so every new connection gets the latest certificate
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+//NotAfter returns the expiry time of the certificate currently being served
+func (r *Reloader) NotAfter() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.notAfter
+}
+
+//LoadedAt returns when the certificate currently being served was read from disk
+func (r *Reloader) LoadedAt() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.loadedAt
+}
+
+//Watch reloads the certificate pair on SIGHUP and whenever either file changes on disk, checked every interval.
+//It blocks until stop is closed.
+func (r *Reloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastMod := r.modTime()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+			r.reloadAndLog("SIGHUP")
+			lastMod = r.modTime()
+		case <-ticker.C:
+			//any change counts, as a pair restored from a backup or copied with its times kept can be older than the last
+			if mod := r.modTime(); !mod.Equal(lastMod) {
+				lastMod = mod
+				r.reloadAndLog("file change")
+			}
+		}
+	}
+}
+
+func (r *Reloader) reloadAndLog(reason string) {
+	if err := r.Reload(); err != nil {
+		log.Error("Error reloading TLS certificate after "+reason+", keeping the current one. ", err.Error())
+		return
+	}
+	fmt.Println("TLS certificate reloaded after " + reason + ", valid until " + r.NotAfter().Format(time.RFC3339))
+}
+
+//modTime returns the latest modification time of the certificate and key files
+func (r *Reloader) modTime() time.Time {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
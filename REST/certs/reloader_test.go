@@ -0,0 +1,136 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//writePair writes a self-signed certificate valid until notAfter and its key to dir, and returns their paths
+func writePair(t *testing.T, dir string, notAfter time.Time) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(notAfter.Unix()),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, kind string, der []byte) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: kind, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//waitFor polls cond until it holds or a second has passed
+func waitFor(cond func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+//TestWatchSwapsCertificate checks a new pair written over the files is served, even one whose files are older
+//than the pair it replaces
+func TestWatchSwapsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	first := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	certFile, keyFile := writePair(t, dir, first)
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go r.Watch(10*time.Millisecond, stop)
+	time.Sleep(30 * time.Millisecond)
+
+	second := first.Add(24 * time.Hour)
+	writePair(t, dir, second)
+	if !waitFor(func() bool { return r.NotAfter().Equal(second) }) {
+		t.Fatalf("serving a certificate valid until %v after the swap, want %v", r.NotAfter(), second)
+	}
+	cert, _ := r.GetCertificate(nil)
+	if !cert.Leaf.NotAfter.Equal(second) {
+		t.Errorf("GetCertificate returns a certificate valid until %v", cert.Leaf.NotAfter)
+	}
+
+	//a pair put back from a backup keeps its older modification time
+	third := second.Add(24 * time.Hour)
+	writePair(t, dir, third)
+	old := time.Now().Add(-48 * time.Hour)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !waitFor(func() bool { return r.NotAfter().Equal(third) }) {
+		t.Errorf("a pair with older files was not loaded: serving one valid until %v", r.NotAfter())
+	}
+}
+
+//TestBrokenPairKeepsCertificate checks the current certificate is kept while the files hold a pair that cannot be loaded
+func TestBrokenPairKeepsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	first := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	certFile, keyFile := writePair(t, dir, first)
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a certificate whose key has not been written yet
+	key, _ := ioutil.ReadFile(keyFile)
+	writePair(t, dir, first.Add(24*time.Hour))
+	if err := ioutil.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("a certificate with the wrong key was loaded")
+	}
+	if err := ioutil.WriteFile(certFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("a file that is not a certificate was loaded")
+	}
+
+	stop := make(chan struct{})
+	go r.Watch(10*time.Millisecond, stop)
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	cert, _ := r.GetCertificate(nil)
+	if !r.NotAfter().Equal(first) || cert == nil || !cert.Leaf.NotAfter.Equal(first) {
+		t.Errorf("serving a certificate valid until %v after broken pairs, want the first one, valid until %v", r.NotAfter(), first)
+	}
+	if _, err := NewReloader(certFile, keyFile); err == nil {
+		t.Error("NewReloader accepted a broken pair")
+	}
+}
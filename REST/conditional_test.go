@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"goMS1Assignment/REST/database"
+)
+
+//TestConditionalListAfterDelete checks a client revalidating the course list sees a deletion,
+//whichever of If-None-Match and If-Modified-Since it sends
+func TestConditionalListAfterDelete(t *testing.T) {
+	updated := time.Now().Add(-time.Hour)
+	router := useRepository(t, newMemoryRepository(
+		database.CourseInfo{Code: 1, Title: "Go", Dates: "Jan", Lecturer: "Tan", Description: "intro", UpdatedAt: updated},
+		database.CourseInfo{Code: 2, Title: "Rust", Dates: "Feb", Lecturer: "Lee", Description: "intro", UpdatedAt: updated},
+	))
+
+	first := serve(router, "GET", "/api/v1/courses?key=k", nil)
+	if first.Code != http.StatusOK {
+		t.Fatalf("list: status %d", first.Code)
+	}
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("list sent no ETag")
+	}
+	if lm := first.Header().Get("Last-Modified"); lm != "" {
+		t.Errorf("list sent Last-Modified %q, which deletions do not move", lm)
+	}
+	unchanged := serve(router, "GET", "/api/v1/courses?key=k", http.Header{"If-None-Match": {etag}})
+	if unchanged.Code != http.StatusNotModified {
+		t.Errorf("unchanged list with If-None-Match: status %d, want 304", unchanged.Code)
+	}
+
+	if w := serve(router, "DELETE", "/api/v1/courses/2?key=k", nil); w.Code != http.StatusAccepted {
+		t.Fatalf("delete: status %d", w.Code)
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+	}{
+		{"If-None-Match", http.Header{"If-None-Match": {etag}}},
+		{"If-Modified-Since", http.Header{"If-Modified-Since": {time.Now().UTC().Format(http.TimeFormat)}}},
+		{"both", http.Header{"If-None-Match": {etag}, "If-Modified-Since": {time.Now().UTC().Format(http.TimeFormat)}}},
+	}
+	for _, tt := range tests {
+		w := serve(router, "GET", "/api/v1/courses?key=k", tt.header)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s after delete: status %d, want 200", tt.name, w.Code)
+			continue
+		}
+		if strings.Contains(w.Body.String(), "Rust") {
+			t.Errorf("%s after delete: list still holds the deleted course: %s", tt.name, w.Body)
+		}
+	}
+}
+
+//TestConditionalCourse checks a single course still honours both validators
+func TestConditionalCourse(t *testing.T) {
+	updated := time.Now().Add(-time.Hour)
+	router := useRepository(t, newMemoryRepository(
+		database.CourseInfo{Code: 1, Title: "Go", Dates: "Jan", Lecturer: "Tan", Description: "intro", UpdatedAt: updated},
+	))
+
+	first := serve(router, "GET", "/api/v1/courses/1?key=k", nil)
+	if first.Code != http.StatusOK || first.Header().Get("Last-Modified") == "" {
+		t.Fatalf("get: status %d, Last-Modified %q", first.Code, first.Header().Get("Last-Modified"))
+	}
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"matching ETag", http.Header{"If-None-Match": {first.Header().Get("ETag")}}, http.StatusNotModified},
+		{"other ETag", http.Header{"If-None-Match": {`"other"`}}, http.StatusOK},
+		{"modified since before", http.Header{"If-Modified-Since": {updated.Add(-time.Minute).UTC().Format(http.TimeFormat)}}, http.StatusOK},
+		{"not modified since", http.Header{"If-Modified-Since": {first.Header().Get("Last-Modified")}}, http.StatusNotModified},
+	}
+	for _, tt := range tests {
+		if w := serve(router, "GET", "/api/v1/courses/1?key=k", tt.header); w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
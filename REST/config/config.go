@@ -0,0 +1,411 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-sql-driver/mysql"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v2"
+)
+
+//Config holds every setting the REST API needs at startup
+type Config struct {
+	APIKey string       `yaml:"api_key" toml:"api_key"`
+	DB     DBConfig     `yaml:"db" toml:"db"`
+	Server ServerConfig `yaml:"server" toml:"server"`
+	Cache  CacheConfig  `yaml:"cache" toml:"cache"`
+	Search SearchConfig `yaml:"search" toml:"search"`
+
+	Idempotency IdempotencyConfig `yaml:"idempotency" toml:"idempotency"`
+	Webhook     WebhookConfig     `yaml:"webhook" toml:"webhook"`
+	Events      EventsConfig      `yaml:"events" toml:"events"`
+}
+
+//DBConfig describes how to reach the MySQL database
+type DBConfig struct {
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	Name     string `yaml:"name" toml:"name"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	//ConnectTimeout bounds how long startup keeps retrying an unreachable database
+	ConnectTimeout time.Duration `yaml:"connect_timeout" toml:"connect_timeout"`
+	//QueryTimeout bounds every query issued on behalf of a request
+	QueryTimeout time.Duration `yaml:"query_timeout" toml:"query_timeout"`
+	//ExportTimeout bounds a streamed export, which reads the whole table and so outlasts QueryTimeout
+	ExportTimeout time.Duration `yaml:"export_timeout" toml:"export_timeout"`
+}
+
+//ServerConfig describes where the API listens and which TLS material it serves
+type ServerConfig struct {
+	Addr     string `yaml:"addr" toml:"addr"`
+	CertFile string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile  string `yaml:"key_file" toml:"key_file"`
+	//CacheControl is the Cache-Control header sent with course reads
+	CacheControl string `yaml:"cache_control" toml:"cache_control"`
+	//CertReload is how often the certificate files are checked for changes; SIGHUP forces a reload at any time
+	CertReload time.Duration `yaml:"cert_reload" toml:"cert_reload"`
+	//GRPCAddr is where the gRPC CourseService listens, with the same certificates; empty disables it
+	GRPCAddr string `yaml:"grpc_addr" toml:"grpc_addr"`
+}
+
+//CacheConfig sizes the read-through cache in front of the course repository
+type CacheConfig struct {
+	Size int           `yaml:"size" toml:"size"` //0 disables the cache
+	TTL  time.Duration `yaml:"ttl" toml:"ttl"`
+}
+
+//SearchConfig selects how course searches are answered
+type SearchConfig struct {
+	//Engine is "fulltext" to use the MySQL FULLTEXT index or "index" for the in-process inverted index
+	Engine string `yaml:"engine" toml:"engine"`
+}
+
+//IdempotencyConfig bounds how many responses to Idempotency-Key requests are kept and for how long
+type IdempotencyConfig struct {
+	Size int           `yaml:"size" toml:"size"`
+	TTL  time.Duration `yaml:"ttl" toml:"ttl"`
+}
+
+//WebhookConfig controls how webhook deliveries are sent and retried
+type WebhookConfig struct {
+	Timeout     time.Duration `yaml:"timeout" toml:"timeout"`           //for each delivery attempt
+	MaxAttempts int           `yaml:"max_attempts" toml:"max_attempts"` //before a delivery is marked failed
+	Backoff     time.Duration `yaml:"backoff" toml:"backoff"`           //before the first retry, doubled for each one after
+	Poll        time.Duration `yaml:"poll" toml:"poll"`                 //how often the outbox is checked for new events
+	//Retention is how long finished deliveries and relayed outbox events are kept; 0 keeps them forever
+	Retention time.Duration `yaml:"retention" toml:"retention"`
+}
+
+//EventsConfig sizes the log of recent course changes that event streams resume from
+type EventsConfig struct {
+	LogSize int `yaml:"log_size" toml:"log_size"`
+}
+
+//field binds one setting to its flag name, environment variable and struct field
+type field struct {
+	flag   string
+	env    []string //first entry is the preferred name, the rest are legacy names
+	usage  string
+	secret bool
+	str    *string
+	num    *int
+	dur    *time.Duration
+}
+
+//Default returns the configuration used when nothing else is supplied
+func Default() Config {
+	return Config{
+		DB: DBConfig{
+			User: "root",
+			Host: "localhost",
+			Port: 3306,
+			Name: "goMS1_db",
+
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+			ConnectTimeout:  time.Minute,
+			QueryTimeout:    5 * time.Second,
+			ExportTimeout:   10 * time.Minute,
+		},
+		Server: ServerConfig{
+			Addr:       ":5000",
+			CertFile:   "./server.crt",
+			KeyFile:    "./server.key",
+			CertReload: 30 * time.Second,
+			GRPCAddr:   ":5001",
+			//clients may keep a copy but must revalidate it with ETag or Last-Modified before use
+			CacheControl: "private, no-cache",
+		},
+		Cache: CacheConfig{
+			Size: 1000,
+			TTL:  time.Minute,
+		},
+		Search: SearchConfig{
+			Engine: "fulltext",
+		},
+		Idempotency: IdempotencyConfig{
+			Size: 10000,
+			TTL:  24 * time.Hour,
+		},
+		Webhook: WebhookConfig{
+			Timeout:     10 * time.Second,
+			MaxAttempts: 8,
+			Backoff:     30 * time.Second,
+			Poll:        time.Second,
+			Retention:   7 * 24 * time.Hour,
+		},
+		Events: EventsConfig{
+			LogSize: 1000,
+		},
+	}
+}
+
+func (c *Config) fields() []field {
+	return []field{
+		{flag: "api-key", env: []string{"API_KEY"}, usage: "access key clients must supply", secret: true, str: &c.APIKey},
+		{flag: "db-user", env: []string{"DB_USER"}, usage: "database user", str: &c.DB.User},
+		{flag: "db-password", env: []string{"DB_PASSWORD", "PASSWORD"}, usage: "database password", secret: true, str: &c.DB.Password},
+		{flag: "db-host", env: []string{"DB_HOST"}, usage: "database host", str: &c.DB.Host},
+		{flag: "db-port", env: []string{"DB_PORT"}, usage: "database port", num: &c.DB.Port},
+		{flag: "db-name", env: []string{"DB_NAME"}, usage: "database name", str: &c.DB.Name},
+		{flag: "db-max-open", env: []string{"DB_MAX_OPEN_CONNS"}, usage: "maximum open database connections, 0 for unlimited", num: &c.DB.MaxOpenConns},
+		{flag: "db-max-idle", env: []string{"DB_MAX_IDLE_CONNS"}, usage: "maximum idle database connections", num: &c.DB.MaxIdleConns},
+		{flag: "db-conn-lifetime", env: []string{"DB_CONN_MAX_LIFETIME"}, usage: "maximum time a connection is reused, 0 for forever", dur: &c.DB.ConnMaxLifetime},
+		{flag: "db-connect-timeout", env: []string{"DB_CONNECT_TIMEOUT"}, usage: "how long to keep retrying the database on startup", dur: &c.DB.ConnectTimeout},
+		{flag: "db-query-timeout", env: []string{"DB_QUERY_TIMEOUT"}, usage: "timeout for each request's database queries", dur: &c.DB.QueryTimeout},
+		{flag: "db-export-timeout", env: []string{"DB_EXPORT_TIMEOUT"}, usage: "timeout for streaming a course export", dur: &c.DB.ExportTimeout},
+		{flag: "addr", env: []string{"LISTEN_ADDR"}, usage: "address the API listens on", str: &c.Server.Addr},
+		{flag: "tls-cert", env: []string{"TLS_CERT_FILE"}, usage: "TLS certificate file", str: &c.Server.CertFile},
+		{flag: "tls-key", env: []string{"TLS_KEY_FILE"}, usage: "TLS private key file", str: &c.Server.KeyFile},
+		{flag: "tls-reload", env: []string{"TLS_RELOAD_INTERVAL"}, usage: "how often to check the TLS files for changes", dur: &c.Server.CertReload},
+		{flag: "grpc-addr", env: []string{"GRPC_LISTEN_ADDR"}, usage: "address the gRPC service listens on, empty to disable", str: &c.Server.GRPCAddr},
+		{flag: "cache-control", env: []string{"CACHE_CONTROL"}, usage: "Cache-Control header sent with course reads", str: &c.Server.CacheControl},
+		{flag: "cache-size", env: []string{"CACHE_SIZE"}, usage: "number of cached course lookups, 0 to disable", num: &c.Cache.Size},
+		{flag: "cache-ttl", env: []string{"CACHE_TTL"}, usage: "how long a cached course lookup is served", dur: &c.Cache.TTL},
+		{flag: "search-engine", env: []string{"SEARCH_ENGINE"}, usage: "fulltext (MySQL) or index (in-process)", str: &c.Search.Engine},
+		{flag: "idempotency-size", env: []string{"IDEMPOTENCY_SIZE"}, usage: "number of Idempotency-Key responses kept", num: &c.Idempotency.Size},
+		{flag: "idempotency-ttl", env: []string{"IDEMPOTENCY_TTL"}, usage: "how long an Idempotency-Key response is replayed", dur: &c.Idempotency.TTL},
+		{flag: "webhook-timeout", env: []string{"WEBHOOK_TIMEOUT"}, usage: "timeout for each webhook delivery attempt", dur: &c.Webhook.Timeout},
+		{flag: "webhook-attempts", env: []string{"WEBHOOK_MAX_ATTEMPTS"}, usage: "webhook delivery attempts before giving up", num: &c.Webhook.MaxAttempts},
+		{flag: "webhook-backoff", env: []string{"WEBHOOK_BACKOFF"}, usage: "wait before the first webhook retry, doubled for each one after", dur: &c.Webhook.Backoff},
+		{flag: "webhook-poll", env: []string{"WEBHOOK_POLL"}, usage: "how often the outbox is checked for new course events", dur: &c.Webhook.Poll},
+		{flag: "webhook-retention", env: []string{"WEBHOOK_RETENTION"}, usage: "how long finished webhook deliveries are kept, 0 for forever", dur: &c.Webhook.Retention},
+		{flag: "events-log", env: []string{"EVENTS_LOG_SIZE"}, usage: "number of recent course changes event streams can resume from", num: &c.Events.LogSize},
+	}
+}
+
+func (f field) set(value string) error {
+	if f.num != nil {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", f.flag, value)
+		}
+		*f.num = n
+		return nil
+	}
+	if f.dur != nil {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a duration", f.flag, value)
+		}
+		*f.dur = d
+		return nil
+	}
+	*f.str = value
+	return nil
+}
+
+func (f field) value() string {
+	if f.num != nil {
+		return strconv.Itoa(*f.num)
+	}
+	if f.dur != nil {
+		return f.dur.String()
+	}
+	return *f.str
+}
+
+//Load builds the configuration by layering defaults, the config file, environment variables and flags, in that order.
+//The config file is taken from the -config flag or the CONFIG_FILE environment variable.
+func Load(name string, args []string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", "", "path to a YAML or TOML config file")
+	for _, f := range cfg.fields() {
+		fs.String(f.flag, "", f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	//.env is optional; it only seeds environment variables that are not already set
+	godotenv.Load(".env")
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	//PORT is the legacy "(host:port)" part of the DSN; DB_HOST and DB_PORT below take precedence over it
+	if v, ok := os.LookupEnv("PORT"); ok {
+		if err := cfg.DB.setLegacyAddress(v); err != nil {
+			return cfg, err
+		}
+	}
+
+	fields := cfg.fields()
+	for _, f := range fields {
+		for _, env := range f.env {
+			if v, ok := os.LookupEnv(env); ok {
+				if err := f.set(v); err != nil {
+					return cfg, err
+				}
+				break
+			}
+		}
+	}
+
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range fields {
+			if f.flag == fl.Name && err == nil {
+				err = f.set(fl.Value.String())
+			}
+		}
+	})
+	return cfg, err
+}
+
+//setLegacyAddress sets the host and port from the "(host:port)" form PORT held, e.g. "(127.0.0.1:3306)".
+//An empty host keeps the configured one, as MySQL itself defaults it.
+func (d *DBConfig) setLegacyAddress(value string) error {
+	addr := strings.TrimSpace(value)
+	addr = strings.TrimPrefix(addr, "tcp")
+	if strings.HasPrefix(addr, "(") && strings.HasSuffix(addr, ")") {
+		addr = addr[1 : len(addr)-1]
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("PORT: %q is not a (host:port) address", value)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return fmt.Errorf("PORT: %q is not a (host:port) address", value)
+	}
+	if host != "" {
+		d.Host = host
+	}
+	d.Port = n
+	return nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, cfg)
+	case ".toml":
+		_, err = toml.Decode(string(data), cfg)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+//Validate reports every problem with the configuration at once
+func (c Config) Validate() error {
+	var problems []string
+	if c.APIKey == "" {
+		problems = append(problems, "api key is required")
+	}
+	if c.DB.User == "" {
+		problems = append(problems, "database user is required")
+	}
+	if c.DB.Host == "" {
+		problems = append(problems, "database host is required")
+	}
+	if c.DB.Port < 1 || c.DB.Port > 65535 {
+		problems = append(problems, fmt.Sprintf("database port %d is out of range", c.DB.Port))
+	}
+	if c.DB.Name == "" {
+		problems = append(problems, "database name is required")
+	}
+	if c.DB.MaxOpenConns < 0 || c.DB.MaxIdleConns < 0 || c.DB.ConnMaxLifetime < 0 {
+		problems = append(problems, "database pool settings must not be negative")
+	}
+	if c.DB.ConnectTimeout <= 0 || c.DB.QueryTimeout <= 0 || c.DB.ExportTimeout <= 0 {
+		problems = append(problems, "database connect, query and export timeouts must be positive")
+	}
+	if c.Cache.Size < 0 {
+		problems = append(problems, "cache size must not be negative")
+	}
+	if c.Cache.Size > 0 && c.Cache.TTL <= 0 {
+		problems = append(problems, "cache TTL must be positive")
+	}
+	if c.Idempotency.Size <= 0 || c.Idempotency.TTL <= 0 {
+		problems = append(problems, "idempotency size and TTL must be positive")
+	}
+	if c.Webhook.Timeout <= 0 || c.Webhook.MaxAttempts <= 0 || c.Webhook.Backoff <= 0 || c.Webhook.Poll <= 0 {
+		problems = append(problems, "webhook timeout, attempts, backoff and poll interval must be positive")
+	}
+	if c.Webhook.Retention < 0 {
+		problems = append(problems, "webhook retention must not be negative")
+	}
+	if c.Events.LogSize < 0 {
+		problems = append(problems, "events log size must not be negative")
+	}
+	if c.Search.Engine != "fulltext" && c.Search.Engine != "index" {
+		problems = append(problems, fmt.Sprintf("search engine %q must be fulltext or index", c.Search.Engine))
+	}
+	if c.Server.Addr == "" {
+		problems = append(problems, "listen address is required")
+	}
+	if c.Server.GRPCAddr != "" && c.Server.GRPCAddr == c.Server.Addr {
+		problems = append(problems, "gRPC and REST listen addresses must differ")
+	}
+	if c.Server.CertReload <= 0 {
+		problems = append(problems, "TLS reload interval must be positive")
+	}
+	for _, file := range []string{c.Server.CertFile, c.Server.KeyFile} {
+		if _, err := os.Stat(file); err != nil {
+			problems = append(problems, fmt.Sprintf("TLS file %q: %v", file, err))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+//DSN returns the MySQL data source name for the database settings
+func (d DBConfig) DSN() string {
+	dsn := mysql.NewConfig()
+	dsn.User = d.User
+	dsn.Passwd = d.Password
+	dsn.Net = "tcp"
+	dsn.Addr = net.JoinHostPort(d.Host, strconv.Itoa(d.Port))
+	dsn.DBName = d.Name
+	dsn.ParseTime = true
+	return dsn.FormatDSN()
+}
+
+//Dump writes the effective configuration with secrets redacted
+func (c Config) Dump(w io.Writer) {
+	fmt.Fprintln(w, "Effective configuration:")
+	for _, f := range c.fields() {
+		value := f.value()
+		if f.secret && value != "" {
+			value = "********"
+		}
+		fmt.Fprintf(w, "  %-20s = %s\n", f.flag, value)
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+//setenv sets or, for an empty value, unsets environment variables until the test ends
+func setenv(t *testing.T, vars map[string]string) {
+	for name, value := range vars {
+		old, had := os.LookupEnv(name)
+		if value == "" {
+			os.Unsetenv(name)
+		} else {
+			os.Setenv(name, value)
+		}
+		name := name
+		t.Cleanup(func() {
+			if had {
+				os.Setenv(name, old)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+}
+
+//TestLegacyEnv checks the environment variables of earlier versions are still read in the form they had,
+//and that the current names win when both are set
+func TestLegacyEnv(t *testing.T) {
+	unset := map[string]string{"DB_HOST": "", "DB_PORT": "", "PORT": "", "DB_PASSWORD": "", "PASSWORD": ""}
+	tests := []struct {
+		name         string
+		env          map[string]string
+		args         []string
+		wantHost     string
+		wantPort     int
+		wantPassword string
+	}{
+		{"defaults", nil, nil, "localhost", 3306, ""},
+		{"legacy names", map[string]string{"PORT": "(127.0.0.1:3307)", "PASSWORD": "old"}, nil, "127.0.0.1", 3307, "old"},
+		{"legacy address without a host", map[string]string{"PORT": "(:3307)"}, nil, "localhost", 3307, ""},
+		{"legacy IPv6 address", map[string]string{"PORT": "([::1]:3307)"}, nil, "::1", 3307, ""},
+		{"current names win", map[string]string{"PORT": "(db.internal:3307)", "DB_HOST": "db", "DB_PORT": "3308", "PASSWORD": "old", "DB_PASSWORD": "new"}, nil, "db", 3308, "new"},
+		{"current port with the legacy host", map[string]string{"PORT": "(db.internal:3307)", "DB_PORT": "3308"}, nil, "db.internal", 3308, ""},
+		{"flags win", map[string]string{"PORT": "(127.0.0.1:3307)"}, []string{"-db-port", "3309"}, "127.0.0.1", 3309, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setenv(t, unset)
+			setenv(t, tt.env)
+			cfg, err := Load("test", tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.DB.Host != tt.wantHost || cfg.DB.Port != tt.wantPort || cfg.DB.Password != tt.wantPassword {
+				t.Errorf("host %q, port %d, password %q, want %q, %d, %q", cfg.DB.Host, cfg.DB.Port, cfg.DB.Password, tt.wantHost, tt.wantPort, tt.wantPassword)
+			}
+			if err := cfg.Validate(); err != nil && !strings.Contains(err.Error(), "api key") {
+				t.Errorf("legacy settings fail validation: %v", err)
+			}
+		})
+	}
+
+	for _, bad := range []string{"3306", "(127.0.0.1)", "(127.0.0.1:mysql)"} {
+		setenv(t, unset)
+		setenv(t, map[string]string{"PORT": bad})
+		if _, err := Load("test", nil); err == nil {
+			t.Errorf("PORT=%s was accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package coursepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// CourseServiceClient is the client API for CourseService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CourseServiceClient interface {
+	// Get returns one course, or NOT_FOUND.
+	Get(ctx context.Context, in *GetCourseRequest, opts ...grpc.CallOption) (*Course, error)
+	// List streams the courses matching the filter, ordered by code.
+	List(ctx context.Context, in *ListCoursesRequest, opts ...grpc.CallOption) (CourseService_ListClient, error)
+	// Create adds a course with every field set, or fails with ALREADY_EXISTS.
+	Create(ctx context.Context, in *CreateCourseRequest, opts ...grpc.CallOption) (*Course, error)
+	// Update changes an existing course. Fields left empty keep their current value.
+	Update(ctx context.Context, in *UpdateCourseRequest, opts ...grpc.CallOption) (*Course, error)
+	// Delete removes a course and returns it as it was.
+	Delete(ctx context.Context, in *DeleteCourseRequest, opts ...grpc.CallOption) (*Course, error)
+	// Watch streams course changes as they happen. A client that loses the stream can resume with the
+	// ID of the last event it received; if the server no longer has every event since then, the stream
+	// starts with a RESET event and the client should reload the courses.
+	Watch(ctx context.Context, in *WatchCoursesRequest, opts ...grpc.CallOption) (CourseService_WatchClient, error)
+}
+
+type courseServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCourseServiceClient(cc grpc.ClientConnInterface) CourseServiceClient {
+	return &courseServiceClient{cc}
+}
+
+func (c *courseServiceClient) Get(ctx context.Context, in *GetCourseRequest, opts ...grpc.CallOption) (*Course, error) {
+	out := new(Course)
+	err := c.cc.Invoke(ctx, "/course.v1.CourseService/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *courseServiceClient) List(ctx context.Context, in *ListCoursesRequest, opts ...grpc.CallOption) (CourseService_ListClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CourseService_ServiceDesc.Streams[0], "/course.v1.CourseService/List", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &courseServiceListClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CourseService_ListClient interface {
+	Recv() (*Course, error)
+	grpc.ClientStream
+}
+
+type courseServiceListClient struct {
+	grpc.ClientStream
+}
+
+func (x *courseServiceListClient) Recv() (*Course, error) {
+	m := new(Course)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *courseServiceClient) Create(ctx context.Context, in *CreateCourseRequest, opts ...grpc.CallOption) (*Course, error) {
+	out := new(Course)
+	err := c.cc.Invoke(ctx, "/course.v1.CourseService/Create", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *courseServiceClient) Update(ctx context.Context, in *UpdateCourseRequest, opts ...grpc.CallOption) (*Course, error) {
+	out := new(Course)
+	err := c.cc.Invoke(ctx, "/course.v1.CourseService/Update", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *courseServiceClient) Delete(ctx context.Context, in *DeleteCourseRequest, opts ...grpc.CallOption) (*Course, error) {
+	out := new(Course)
+	err := c.cc.Invoke(ctx, "/course.v1.CourseService/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *courseServiceClient) Watch(ctx context.Context, in *WatchCoursesRequest, opts ...grpc.CallOption) (CourseService_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CourseService_ServiceDesc.Streams[1], "/course.v1.CourseService/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &courseServiceWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CourseService_WatchClient interface {
+	Recv() (*CourseEvent, error)
+	grpc.ClientStream
+}
+
+type courseServiceWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *courseServiceWatchClient) Recv() (*CourseEvent, error) {
+	m := new(CourseEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// CourseServiceServer is the server API for CourseService service.
+// All implementations must embed UnimplementedCourseServiceServer
+// for forward compatibility
+type CourseServiceServer interface {
+	// Get returns one course, or NOT_FOUND.
+	Get(context.Context, *GetCourseRequest) (*Course, error)
+	// List streams the courses matching the filter, ordered by code.
+	List(*ListCoursesRequest, CourseService_ListServer) error
+	// Create adds a course with every field set, or fails with ALREADY_EXISTS.
+	Create(context.Context, *CreateCourseRequest) (*Course, error)
+	// Update changes an existing course. Fields left empty keep their current value.
+	Update(context.Context, *UpdateCourseRequest) (*Course, error)
+	// Delete removes a course and returns it as it was.
+	Delete(context.Context, *DeleteCourseRequest) (*Course, error)
+	// Watch streams course changes as they happen. A client that loses the stream can resume with the
+	// ID of the last event it received; if the server no longer has every event since then, the stream
+	// starts with a RESET event and the client should reload the courses.
+	Watch(*WatchCoursesRequest, CourseService_WatchServer) error
+	mustEmbedUnimplementedCourseServiceServer()
+}
+
+// UnimplementedCourseServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCourseServiceServer struct {
+}
+
+func (UnimplementedCourseServiceServer) Get(context.Context, *GetCourseRequest) (*Course, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedCourseServiceServer) List(*ListCoursesRequest, CourseService_ListServer) error {
+	return status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedCourseServiceServer) Create(context.Context, *CreateCourseRequest) (*Course, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedCourseServiceServer) Update(context.Context, *UpdateCourseRequest) (*Course, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedCourseServiceServer) Delete(context.Context, *DeleteCourseRequest) (*Course, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedCourseServiceServer) Watch(*WatchCoursesRequest, CourseService_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedCourseServiceServer) mustEmbedUnimplementedCourseServiceServer() {}
+
+// UnsafeCourseServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CourseServiceServer will
+// result in compilation errors.
+type UnsafeCourseServiceServer interface {
+	mustEmbedUnimplementedCourseServiceServer()
+}
+
+func RegisterCourseServiceServer(s grpc.ServiceRegistrar, srv CourseServiceServer) {
+	s.RegisterService(&CourseService_ServiceDesc, srv)
+}
+
+func _CourseService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCourseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CourseServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/course.v1.CourseService/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CourseServiceServer).Get(ctx, req.(*GetCourseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CourseService_List_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListCoursesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CourseServiceServer).List(m, &courseServiceListServer{stream})
+}
+
+type CourseService_ListServer interface {
+	Send(*Course) error
+	grpc.ServerStream
+}
+
+type courseServiceListServer struct {
+	grpc.ServerStream
+}
+
+func (x *courseServiceListServer) Send(m *Course) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CourseService_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCourseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CourseServiceServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/course.v1.CourseService/Create",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CourseServiceServer).Create(ctx, req.(*CreateCourseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CourseService_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateCourseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CourseServiceServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/course.v1.CourseService/Update",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CourseServiceServer).Update(ctx, req.(*UpdateCourseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CourseService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCourseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CourseServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/course.v1.CourseService/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CourseServiceServer).Delete(ctx, req.(*DeleteCourseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CourseService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchCoursesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CourseServiceServer).Watch(m, &courseServiceWatchServer{stream})
+}
+
+type CourseService_WatchServer interface {
+	Send(*CourseEvent) error
+	grpc.ServerStream
+}
+
+type courseServiceWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *courseServiceWatchServer) Send(m *CourseEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// CourseService_ServiceDesc is the grpc.ServiceDesc for CourseService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CourseService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "course.v1.CourseService",
+	HandlerType: (*CourseServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _CourseService_Get_Handler,
+		},
+		{
+			MethodName: "Create",
+			Handler:    _CourseService_Create_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _CourseService_Update_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _CourseService_Delete_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "List",
+			Handler:       _CourseService_List_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _CourseService_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "course.proto",
+}
@@ -0,0 +1,5 @@
+//Package coursepb holds the CourseService protobuf definition and the Go code generated from it, including the client
+//other services use to call the course API over gRPC. Regenerate after editing course.proto.
+package coursepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative course.proto
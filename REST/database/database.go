@@ -3,120 +3,252 @@ package database
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"errors"
+	"strings"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	log "github.com/sirupsen/logrus"
 )
 
 type CourseInfo struct {
-	Code        int    `json:"Code"`
-	Title       string `json:"Title"`
-	Dates       string `json:"Dates"`
-	Lecturer    string `json:"Lecturer"`
-	Description string `json:"Description"`
+	Code        int    `json:"Code" xml:"Code" yaml:"Code"`
+	Title       string `json:"Title" xml:"Title" yaml:"Title"`
+	Dates       string `json:"Dates" xml:"Dates" yaml:"Dates"`
+	Lecturer    string `json:"Lecturer" xml:"Lecturer" yaml:"Lecturer"`
+	Description string `json:"Description" xml:"Description" yaml:"Description"`
+	//UpdatedAt is maintained by MySQL and drives the Last-Modified header; it is not part of the response body
+	UpdatedAt time.Time `json:"-" xml:"-" yaml:"-"`
 }
 
-//InsertRecord queries the database to delete existing course
-func DeleteRecord(db *sql.DB, Code int) {
-	ctx := context.Background()
-	query := "DELETE FROM CourseInfo WHERE Code = ?"
-	_, err := db.QueryContext(ctx, query, Code)
+//DBTX is satisfied by both *sql.DB and *sql.Tx, so every query below can run inside or outside a transaction
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+//PoolSettings controls the size and recycling of the connection pool
+type PoolSettings struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+//Connect opens the database, applies the pool settings and pings it until it answers.
+//Failed pings are retried with exponential backoff until the deadline passes.
+func Connect(dsn string, pool PoolSettings, deadline time.Duration) (*sql.DB, error) {
+	db, err := sql.Open("mysql", dsn)
 	if err != nil {
-		log.Panic("Error deleting record.", err.Error())
+		return nil, err
+	}
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+
+	backoff := 500 * time.Millisecond
+	for {
+		err = db.PingContext(ctx)
+		if err == nil {
+			return db, nil
+		}
+		log.Warning("Database not ready, retrying in ", backoff, ": ", err.Error())
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return nil, err
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > 10*time.Second {
+			backoff = 10 * time.Second
+		}
 	}
-
-	// query := fmt.Sprintf("DELETE FROM CourseInfo WHERE Code='%d'", Code)
-	// _, err := db.Query(query)
-	// if err != nil {
-	// 	panic(err.Error())
-	// }
 }
 
-//InsertRecord queries the database to update existing course
-func EditRecord(db *sql.DB, Code int, Title string, Dates string, Lecturer string, Description string) {
+//DeleteRecord queries the database to delete existing course
+func DeleteRecord(ctx context.Context, db DBTX, Code int) error {
+	query := "DELETE FROM CourseInfo WHERE Code = ?"
+	_, err := db.ExecContext(ctx, query, Code)
+	if err != nil {
+		log.Error("Error deleting record.", err.Error())
+	}
+	return err
+}
 
-	ctx := context.Background()
+//EditRecord queries the database to update existing course
+func EditRecord(ctx context.Context, db DBTX, Code int, Title string, Dates string, Lecturer string, Description string) error {
 	query := "UPDATE CourseInfo SET Title=?, Dates=?, Lecturer=?, Description=? WHERE Code=?"
-	_, err := db.QueryContext(ctx, query, Title, Dates, Lecturer, Description, Code)
+	_, err := db.ExecContext(ctx, query, Title, Dates, Lecturer, Description, Code)
 	if err != nil {
-		log.Panic("Panic at Update Record.", err.Error())
+		log.Error("Error at Update Record.", err.Error())
 	}
-
+	return err
 }
 
-//InsertRecord queries the database to create new course
-func InsertRecord(db *sql.DB, Code int, Title string, Dates string, Lecturer string, Description string) {
+//ErrDuplicate is returned by InsertRecord when a course with the same code already exists
+var ErrDuplicate = errors.New("duplicate course ID, course already exists")
 
-	ctx := context.Background()
+//erDupEntry is the MySQL error number of a duplicate key
+const erDupEntry = 1062
+
+//InsertRecord queries the database to create new course
+func InsertRecord(ctx context.Context, db DBTX, Code int, Title string, Dates string, Lecturer string, Description string) error {
 	query := "INSERT INTO CourseInfo (Code, Title, Dates, Lecturer, Description) VALUES (?, ?, ?, ?, ?)"
-	_, err := db.QueryContext(ctx, query, Code, Title, Dates, Lecturer, Description)
+	_, err := db.ExecContext(ctx, query, Code, Title, Dates, Lecturer, Description)
 	if err != nil {
-		log.Panic("Panic at Insert Record.", err.Error())
+		log.Error("Error at Insert Record.", err.Error())
+		var mysqlErr *mysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == erDupEntry {
+			return ErrDuplicate
+		}
 	}
-
+	return err
 }
 
-//GetRecord queries the database to return all courses
-func GetRecords(db *sql.DB) map[int]CourseInfo {
+//GetRecords queries the database to return all courses
+func GetRecords(ctx context.Context, db DBTX) (map[int]CourseInfo, error) {
 	courses := make(map[int]CourseInfo)
 
-	results, err := db.Query("Select * FROM goMS1_db.CourseInfo")
+	results, err := db.QueryContext(ctx, "SELECT Code, Title, Dates, Lecturer, Description, UpdatedAt FROM CourseInfo")
 	if err != nil {
-		log.Panic("Panic at Get Records.", err.Error())
+		log.Error("Error at Get Records.", err.Error())
+		return nil, err
 	}
+	defer results.Close()
 
 	for results.Next() {
 		// map this type to the record in the table
 		var course CourseInfo
-		err = results.Scan(&course.Code, &course.Title, &course.Dates, &course.Lecturer, &course.Description)
+		err = results.Scan(&course.Code, &course.Title, &course.Dates, &course.Lecturer, &course.Description, &course.UpdatedAt)
 		if err != nil {
-			log.Panic("Panic at Get Records.", err.Error())
+			log.Error("Error at Get Records.", err.Error())
+			return nil, err
 		}
-
-		//fmt.Println(course.Code, course.Title, course.Dates, course.Lecturer, course.Description)
-		courses[course.Code] = CourseInfo{course.Code, course.Title, course.Dates, course.Lecturer, course.Description}
+		courses[course.Code] = course
 	}
-	//fmt.Println(courses)
-	return courses
+	return courses, results.Err()
 }
 
-//GetRecord queries the SQL database and returns a course
-func GetRecord(db *sql.DB, Code int) CourseInfo {
+//Filter narrows down a listing of courses; empty fields match everything
+type Filter struct {
+	Lecturer string //part of the lecturer's name
+	Title    string //part of the title
+}
 
-	ctx := context.Background()
-	var course CourseInfo
-	query := "SELECT * FROM goMS1_db.CourseInfo WHERE Code =?"
-	results, err := db.QueryContext(ctx, query, Code)
+//IsEmpty reports whether the filter matches every course
+func (f Filter) IsEmpty() bool {
+	return f.Lecturer == "" && f.Title == ""
+}
+
+//likeEscaper makes user input match literally inside a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+//EachRecord streams the courses matching filter, ordered by Code, straight from the database cursor.
+//fn is called once per row; returning an error from it stops the iteration.
+func EachRecord(ctx context.Context, db DBTX, filter Filter, fn func(CourseInfo) error) error {
+	query := "SELECT Code, Title, Dates, Lecturer, Description, UpdatedAt FROM CourseInfo WHERE 1 = 1"
+	var args []interface{}
+	if filter.Lecturer != "" {
+		query += " AND Lecturer LIKE ?"
+		args = append(args, "%"+likeEscaper.Replace(filter.Lecturer)+"%")
+	}
+	if filter.Title != "" {
+		query += " AND Title LIKE ?"
+		args = append(args, "%"+likeEscaper.Replace(filter.Title)+"%")
+	}
+	query += " ORDER BY Code"
+
+	results, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Panic("Panic at Get Record.", err.Error())
+		log.Error("Error at Each Record.", err.Error())
+		return err
 	}
+	defer results.Close()
 
 	for results.Next() {
-		// map this type to the record in the table
-		err = results.Scan(&course.Code, &course.Title, &course.Dates, &course.Lecturer, &course.Description)
+		var course CourseInfo
+		err = results.Scan(&course.Code, &course.Title, &course.Dates, &course.Lecturer, &course.Description, &course.UpdatedAt)
 		if err != nil {
-			log.Panic("Panic at Get Record.", err.Error())
+			log.Error("Error at Each Record.", err.Error())
+			return err
+		}
+		if err = fn(course); err != nil {
+			return err
 		}
 	}
+	return results.Err()
+}
 
-	return course
+//GetRecord queries the SQL database and returns a course
+func GetRecord(ctx context.Context, db DBTX, Code int) (CourseInfo, error) {
+	course, _, err := FindRecord(ctx, db, Code)
+	return course, err
+}
 
+//FindRecord queries the SQL database once and returns a course together with whether it exists
+func FindRecord(ctx context.Context, db DBTX, Code int) (CourseInfo, bool, error) {
+	var course CourseInfo
+	query := "SELECT Code, Title, Dates, Lecturer, Description, UpdatedAt FROM CourseInfo WHERE Code = ?"
+	err := db.QueryRowContext(ctx, query, Code).Scan(&course.Code, &course.Title, &course.Dates, &course.Lecturer, &course.Description, &course.UpdatedAt)
+	if err == sql.ErrNoRows {
+		log.Warning("Code ", Code, " does not exist. Warning triggered at function FindRecord.")
+		return course, false, nil
+	}
+	if err != nil {
+		log.Error("Error at Find Record.", err.Error())
+		return course, false, err
+	}
+	return course, true, nil
 }
 
 //RowExists queries table CourseInfo with code and returns a bool if code exists
-func RowExists(db *sql.DB, code int) bool {
+func RowExists(ctx context.Context, db DBTX, code int) (bool, error) {
 	var exists bool
-	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM CourseInfo WHERE code = '%d')", code)
-	err := db.QueryRow(query).Scan(&exists)
+	query := "SELECT EXISTS (SELECT 1 FROM CourseInfo WHERE Code = ?)"
+	err := db.QueryRowContext(ctx, query, code).Scan(&exists)
 
 	if err != nil {
 		log.Error("Error at Row Exists", err.Error())
+		return false, err
 	}
 
 	if exists == false {
 		log.Warning("Code ", code, " does not exist. Warning triggered at function RowExists.")
 	}
-	return exists
+	return exists, nil
+}
+
+//ScoredCourse is a course returned by a full-text search together with its relevance
+type ScoredCourse struct {
+	CourseInfo
+	Score float64
+}
+
+//SearchRecords runs a boolean mode FULLTEXT search over Title, Description and Lecturer, most relevant first
+func SearchRecords(ctx context.Context, db DBTX, booleanQuery string, limit int) ([]ScoredCourse, error) {
+	query := "SELECT Code, Title, Dates, Lecturer, Description, UpdatedAt, " +
+		"MATCH (Title, Description, Lecturer) AGAINST (? IN BOOLEAN MODE) AS Score " +
+		"FROM CourseInfo WHERE MATCH (Title, Description, Lecturer) AGAINST (? IN BOOLEAN MODE) " +
+		"ORDER BY Score DESC, Code LIMIT ?"
+	results, err := db.QueryContext(ctx, query, booleanQuery, booleanQuery, limit)
+	if err != nil {
+		log.Error("Error at Search Records.", err.Error())
+		return nil, err
+	}
+	defer results.Close()
 
+	hits := []ScoredCourse{}
+	for results.Next() {
+		var hit ScoredCourse
+		err = results.Scan(&hit.Code, &hit.Title, &hit.Dates, &hit.Lecturer, &hit.Description, &hit.UpdatedAt, &hit.Score)
+		if err != nil {
+			log.Error("Error at Search Records.", err.Error())
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, results.Err()
 }
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//Course lifecycle events recorded in the outbox
+const (
+	EventCreated = "course.created"
+	EventUpdated = "course.updated"
+	EventDeleted = "course.deleted"
+)
+
+//Events lists every course lifecycle event
+var Events = []string{EventCreated, EventUpdated, EventDeleted}
+
+//OutboxEvent is a course change written to the Outbox table in the same transaction as the change itself,
+//so an event exists exactly when its change was committed
+type OutboxEvent struct {
+	ID        int64
+	Type      string
+	Course    CourseInfo
+	CreatedAt time.Time
+}
+
+//InsertOutboxEvent records that course was created, updated or deleted
+func InsertOutboxEvent(ctx context.Context, db DBTX, eventType string, course CourseInfo) error {
+	payload, err := json.Marshal(course)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "INSERT INTO Outbox (Type, Payload) VALUES (?, ?)", eventType, payload)
+	if err != nil {
+		log.Error("Error at InsertOutboxEvent function. ", err.Error())
+	}
+	return err
+}
+
+//ClaimOutboxEvents locks up to limit unprocessed events in the transaction db, oldest first.
+//Events already locked by another server are skipped, so several servers can relay the same outbox.
+func ClaimOutboxEvents(ctx context.Context, db DBTX, limit int) ([]OutboxEvent, error) {
+	rows, err := db.QueryContext(ctx, "SELECT ID, Type, Payload, CreatedAt FROM Outbox WHERE ProcessedAt IS NULL ORDER BY ID LIMIT ? FOR UPDATE SKIP LOCKED", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var event OutboxEvent
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.Type, &payload, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &event.Course); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+//MarkOutboxEventProcessed records that an event has been handed on
+func MarkOutboxEventProcessed(ctx context.Context, db DBTX, id int64) error {
+	_, err := db.ExecContext(ctx, "UPDATE Outbox SET ProcessedAt = CURRENT_TIMESTAMP WHERE ID = ?", id)
+	return err
+}
+
+//PruneOutboxEvents deletes up to limit events that were processed before before, returning how many it deleted
+func PruneOutboxEvents(ctx context.Context, db DBTX, before time.Time, limit int) (int64, error) {
+	result, err := db.ExecContext(ctx, "DELETE FROM Outbox WHERE ProcessedAt < ? LIMIT ?", before.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
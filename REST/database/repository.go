@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+//Repository is the course storage used by the handlers. Implementations can wrap each other, e.g. to add caching.
+type Repository interface {
+	GetRecords(ctx context.Context) (map[int]CourseInfo, error)
+	FindRecord(ctx context.Context, code int) (CourseInfo, bool, error)
+	//EachRecord streams matching courses without holding them all in memory
+	EachRecord(ctx context.Context, filter Filter, fn func(CourseInfo) error) error
+	InsertRecord(ctx context.Context, course CourseInfo) error
+	EditRecord(ctx context.Context, course CourseInfo) error
+	DeleteRecord(ctx context.Context, code int) error
+	//InTransaction runs fn with a repository whose writes are committed together, or not at all if fn returns an error
+	InTransaction(ctx context.Context, fn func(tx Repository) error) error
+}
+
+//SQLRepository stores courses in the MySQL CourseInfo table
+type SQLRepository struct {
+	db     DBTX
+	conn   *sql.DB //nil when the repository already runs inside a transaction
+	outbox bool    //record every write in the Outbox table as well
+}
+
+//NewSQLRepository returns a repository backed by db
+func NewSQLRepository(db *sql.DB) *SQLRepository {
+	return &SQLRepository{db: db, conn: db}
+}
+
+//EnableOutbox makes every write also record an OutboxEvent in the same transaction
+func (r *SQLRepository) EnableOutbox() {
+	r.outbox = true
+}
+
+func (r *SQLRepository) GetRecords(ctx context.Context) (map[int]CourseInfo, error) {
+	return GetRecords(ctx, r.db)
+}
+
+func (r *SQLRepository) FindRecord(ctx context.Context, code int) (CourseInfo, bool, error) {
+	return FindRecord(ctx, r.db, code)
+}
+
+func (r *SQLRepository) EachRecord(ctx context.Context, filter Filter, fn func(CourseInfo) error) error {
+	return EachRecord(ctx, r.db, filter, fn)
+}
+
+func (r *SQLRepository) InsertRecord(ctx context.Context, course CourseInfo) error {
+	return r.write(ctx, EventCreated, course, func(db DBTX) error {
+		return InsertRecord(ctx, db, course.Code, course.Title, course.Dates, course.Lecturer, course.Description)
+	})
+}
+
+func (r *SQLRepository) EditRecord(ctx context.Context, course CourseInfo) error {
+	return r.write(ctx, EventUpdated, course, func(db DBTX) error {
+		return EditRecord(ctx, db, course.Code, course.Title, course.Dates, course.Lecturer, course.Description)
+	})
+}
+
+func (r *SQLRepository) DeleteRecord(ctx context.Context, code int) error {
+	if !r.outbox {
+		return DeleteRecord(ctx, r.db, code)
+	}
+	//the event carries the course as it was before deletion
+	return r.InTransaction(ctx, func(tx Repository) error {
+		db := tx.(*SQLRepository).db
+		course, _, err := FindRecord(ctx, db, code)
+		if err != nil {
+			return err
+		}
+		course.Code = code
+		if err := DeleteRecord(ctx, db, code); err != nil {
+			return err
+		}
+		return InsertOutboxEvent(ctx, db, EventDeleted, course)
+	})
+}
+
+//write runs fn and, with the outbox enabled, records eventType for course in the same transaction
+func (r *SQLRepository) write(ctx context.Context, eventType string, course CourseInfo, fn func(db DBTX) error) error {
+	if !r.outbox {
+		return fn(r.db)
+	}
+	return r.InTransaction(ctx, func(tx Repository) error {
+		db := tx.(*SQLRepository).db
+		if err := fn(db); err != nil {
+			return err
+		}
+		return InsertOutboxEvent(ctx, db, eventType, course)
+	})
+}
+
+func (r *SQLRepository) SearchRecords(ctx context.Context, booleanQuery string, limit int) ([]ScoredCourse, error) {
+	return SearchRecords(ctx, r.db, booleanQuery, limit)
+}
+
+//InTransaction begins a database transaction, or joins the current one when called inside InTransaction
+func (r *SQLRepository) InTransaction(ctx context.Context, fn func(tx Repository) error) error {
+	if r.conn == nil {
+		return fn(r)
+	}
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(&SQLRepository{db: tx, outbox: r.outbox}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+//Change is one write made through a Recorder
+type Change struct {
+	Deleted bool
+	Course  CourseInfo //only Code is set for deletions
+}
+
+//Recorder passes every call through to a repository and notes the writes that succeeded.
+//Wrapping repositories use it to learn what a transaction changed and catch up once it has committed.
+type Recorder struct {
+	Repository
+	Changes []Change
+}
+
+func (r *Recorder) InsertRecord(ctx context.Context, course CourseInfo) error {
+	err := r.Repository.InsertRecord(ctx, course)
+	if err == nil {
+		r.Changes = append(r.Changes, Change{Course: course})
+	}
+	return err
+}
+
+func (r *Recorder) EditRecord(ctx context.Context, course CourseInfo) error {
+	err := r.Repository.EditRecord(ctx, course)
+	if err == nil {
+		r.Changes = append(r.Changes, Change{Course: course})
+	}
+	return err
+}
+
+func (r *Recorder) DeleteRecord(ctx context.Context, code int) error {
+	err := r.Repository.DeleteRecord(ctx, code)
+	if err == nil {
+		r.Changes = append(r.Changes, Change{Deleted: true, Course: CourseInfo{Code: code}})
+	}
+	return err
+}
+
+func (r *Recorder) InTransaction(ctx context.Context, fn func(tx Repository) error) error {
+	return r.Repository.InTransaction(ctx, func(tx Repository) error {
+		inner := &Recorder{Repository: tx}
+		err := fn(inner)
+		if err == nil {
+			r.Changes = append(r.Changes, inner.Changes...)
+		}
+		return err
+	})
+}
@@ -0,0 +1,105 @@
+//Package events broadcasts course changes made by this server to live subscribers, such as Server-Sent Events streams.
+//A bounded log of recent events lets a subscriber that lost its connection resume where it left off.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"goMS1Assignment/REST/database"
+)
+
+//Event types
+const (
+	Created = "created"
+	Updated = "updated"
+	Deleted = "deleted"
+)
+
+//subscriberBuffer is how many events a subscriber may fall behind before it is dropped
+const subscriberBuffer = 64
+
+//Event is one course change. IDs increase by one per event and are only meaningful on the server that issued them.
+type Event struct {
+	ID     uint64
+	Type   string
+	Course database.CourseInfo
+	Time   time.Time
+}
+
+//Broker keeps the most recent events and hands new ones to every subscriber
+type Broker struct {
+	mu          sync.Mutex
+	log         []Event //ring buffer of the last len(log) events
+	next        uint64  //ID of the next event
+	subscribers map[chan Event]struct{}
+}
+
+//NewBroker keeps the last size events for resuming subscribers
+func NewBroker(size int) *Broker {
+	return &Broker{
+		log:         make([]Event, 0, size),
+		next:        1,
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+//Publish records a change and sends it to the subscribers. A subscriber that has fallen too far behind is dropped;
+//its channel is closed and it can resume from the log with the last ID it received.
+func (b *Broker) Publish(eventType string, course database.CourseInfo) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := Event{ID: b.next, Type: eventType, Course: course, Time: time.Now()}
+	b.next++
+	if len(b.log) < cap(b.log) {
+		b.log = append(b.log, event)
+	} else if cap(b.log) > 0 {
+		copy(b.log, b.log[1:])
+		b.log[len(b.log)-1] = event
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return event
+}
+
+//Subscribe returns the logged events after lastID followed by a channel of new ones, and a function to unsubscribe.
+//complete is false when events after lastID have already dropped out of the log, so the subscriber missed some.
+//A lastID of 0 means the subscriber wants new events only.
+func (b *Broker) Subscribe(lastID uint64) (backlog []Event, complete bool, updates <-chan Event, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = true
+	if lastID > 0 {
+		//lastID may come from before a restart; anything newer than the next ID cannot be resumed
+		complete = lastID < b.next && (len(b.log) == 0 || b.log[0].ID <= lastID+1)
+		for _, event := range b.log {
+			if event.ID > lastID {
+				backlog = append(backlog, event)
+			}
+		}
+		if lastID >= b.next {
+			backlog = append([]Event(nil), b.log...)
+		}
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return backlog, complete, ch, cancel
+}
@@ -0,0 +1,80 @@
+package events
+
+import (
+	"context"
+
+	"goMS1Assignment/REST/database"
+)
+
+//Repository publishes an event for every write made through it. Writes inside a transaction are published once it has committed.
+type Repository struct {
+	database.Repository
+	broker  *Broker
+	pending *[]Event //set inside a transaction, collecting its events until commit
+}
+
+//NewRepository wraps next so its writes are published on broker
+func NewRepository(next database.Repository, broker *Broker) *Repository {
+	return &Repository{Repository: next, broker: broker}
+}
+
+func (r *Repository) emit(eventType string, course database.CourseInfo) {
+	if r.pending != nil {
+		*r.pending = append(*r.pending, Event{Type: eventType, Course: course})
+		return
+	}
+	r.broker.Publish(eventType, course)
+}
+
+//InsertRecord creates the course and publishes a created event
+func (r *Repository) InsertRecord(ctx context.Context, course database.CourseInfo) error {
+	if err := r.Repository.InsertRecord(ctx, course); err != nil {
+		return err
+	}
+	r.emit(Created, course)
+	return nil
+}
+
+//EditRecord updates the course and publishes an updated event
+func (r *Repository) EditRecord(ctx context.Context, course database.CourseInfo) error {
+	if err := r.Repository.EditRecord(ctx, course); err != nil {
+		return err
+	}
+	r.emit(Updated, course)
+	return nil
+}
+
+//DeleteRecord deletes the course and publishes a deleted event carrying the course as it was
+func (r *Repository) DeleteRecord(ctx context.Context, code int) error {
+	course, _, err := r.Repository.FindRecord(ctx, code)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.DeleteRecord(ctx, code); err != nil {
+		return err
+	}
+	course.Code = code
+	r.emit(Deleted, course)
+	return nil
+}
+
+//InTransaction runs fn in a transaction of the wrapped repository and publishes its events once it has committed
+func (r *Repository) InTransaction(ctx context.Context, fn func(tx database.Repository) error) error {
+	var pending []Event
+	err := r.Repository.InTransaction(ctx, func(tx database.Repository) error {
+		pending = pending[:0]
+		return fn(&Repository{Repository: tx, broker: r.broker, pending: &pending})
+	})
+	if err != nil {
+		return err
+	}
+	if r.pending != nil {
+		//a nested transaction commits with the outer one
+		*r.pending = append(*r.pending, pending...)
+		return nil
+	}
+	for _, event := range pending {
+		r.broker.Publish(event.Type, event.Course)
+	}
+	return nil
+}
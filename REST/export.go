@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"goMS1Assignment/REST/database"
+	"goMS1Assignment/REST/render"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//exportFormat describes how one export format is written
+type exportFormat struct {
+	contentType string
+	extension   string
+	//open starts the file and returns functions that write one course and finish the file
+	open func(w io.Writer) (write func(database.CourseInfo) error, flush func() error)
+}
+
+var exportFormats = map[string]exportFormat{
+	"csv":    {"text/csv; charset=utf-8", "csv", openCSV(false)},
+	"excel":  {"text/csv; charset=utf-8", "csv", openCSV(true)},
+	"ndjson": {"application/x-ndjson", "ndjson", openNDJSON},
+}
+
+//filterParams reads the lecturer and title filters shared by the list and export endpoints
+func filterParams(r *http.Request) database.Filter {
+	query := r.URL.Query()
+	return database.Filter{
+		Lecturer: strings.TrimSpace(query.Get("lecturer")),
+		Title:    strings.TrimSpace(query.Get("title")),
+	}
+}
+
+//exportCourses streams the courses as a downloadable file, row by row from the database cursor.
+//format is csv, excel (CSV that spreadsheet programs open as UTF-8) or ndjson, and the list filters apply.
+func exportCourses(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r) {
+		return
+	}
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = "csv"
+	}
+	format, ok := exportFormats[name]
+	if !ok {
+		render.Status(w, r, http.StatusBadRequest, "format must be csv, excel or ndjson")
+		return
+	}
+
+	//the rows are read while the client downloads them, so the per-request query timeout would cut large exports short
+	ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+	defer cancel()
+
+	//headers are only sent with the first row, so a query that fails straight away can still be reported
+	write, flush := format.open(w)
+	started := false
+	err := repository.EachRecord(ctx, filterParams(r), func(course database.CourseInfo) error {
+		if !started {
+			started = true
+			setExportHeaders(w, format)
+		}
+		validateAndSanitize(&course)
+		return write(course)
+	})
+	if err != nil && !started {
+		databaseError(w, r, err)
+		return
+	}
+	if err != nil {
+		//the status line has gone out already; cutting the stream short is all that is left
+		log.Error("Error at export function, stream interrupted. ", err.Error())
+		return
+	}
+	if !started {
+		setExportHeaders(w, format)
+	}
+	if err := flush(); err != nil {
+		log.Error("Error at export function. ", err.Error())
+	}
+}
+
+func setExportHeaders(w http.ResponseWriter, format exportFormat) {
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="courses.`+format.extension+`"`)
+}
+
+//openCSV writes a header row and one row per course. For spreadsheets the file starts with a UTF-8 byte order mark,
+//uses CRLF line endings and prefixes cells that would otherwise be read as formulas with an apostrophe.
+//Besides = + - @, a leading tab or carriage return is escaped too, as spreadsheets skip it and read the formula after.
+func openCSV(spreadsheet bool) func(w io.Writer) (func(database.CourseInfo) error, func() error) {
+	return func(w io.Writer) (func(database.CourseInfo) error, func() error) {
+		writer := csv.NewWriter(w)
+		writer.UseCRLF = spreadsheet
+		headerDone := false
+		cell := func(s string) string {
+			if spreadsheet && s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+				return "'" + s
+			}
+			return s
+		}
+		header := func() error {
+			if headerDone {
+				return nil
+			}
+			headerDone = true
+			if spreadsheet {
+				if _, err := io.WriteString(w, "\ufeff"); err != nil {
+					return err
+				}
+			}
+			return writer.Write([]string{"Code", "Title", "Dates", "Lecturer", "Description"})
+		}
+		write := func(course database.CourseInfo) error {
+			if err := header(); err != nil {
+				return err
+			}
+			return writer.Write([]string{strconv.Itoa(course.Code), cell(course.Title), cell(course.Dates), cell(course.Lecturer), cell(course.Description)})
+		}
+		flush := func() error {
+			if err := header(); err != nil {
+				return err
+			}
+			writer.Flush()
+			return writer.Error()
+		}
+		return write, flush
+	}
+}
+
+//openNDJSON writes one JSON object per line
+func openNDJSON(w io.Writer) (func(database.CourseInfo) error, func() error) {
+	encoder := json.NewEncoder(w)
+	write := func(course database.CourseInfo) error {
+		return encoder.Encode(course)
+	}
+	return write, func() error { return nil }
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"goMS1Assignment/REST/database"
+)
+
+//deadlineRepository records the deadline of the context its rows are read with
+type deadlineRepository struct {
+	*memoryRepository
+	deadline time.Time
+}
+
+func (d *deadlineRepository) EachRecord(ctx context.Context, filter database.Filter, fn func(database.CourseInfo) error) error {
+	d.deadline, _ = ctx.Deadline()
+	return d.memoryRepository.EachRecord(ctx, filter, fn)
+}
+
+//TestExportTimeout checks an export is bounded by the export timeout rather than the per-request query timeout
+func TestExportTimeout(t *testing.T) {
+	repo := &deadlineRepository{memoryRepository: newMemoryRepository(database.CourseInfo{Code: 1, Title: "Go"})}
+	router := useRepository(t, repo)
+
+	start := time.Now()
+	if w := serve(router, "GET", "/api/v1/courses/export?key=k", nil); w.Code != http.StatusOK {
+		t.Fatalf("export: status %d", w.Code)
+	}
+	if repo.deadline.Before(start.Add(exportTimeout)) || repo.deadline.After(time.Now().Add(exportTimeout)) {
+		t.Errorf("export rows read with %v left, want the export timeout of %v", repo.deadline.Sub(start), exportTimeout)
+	}
+}
+
+//TestExportFormulaGuard checks spreadsheet exports escape every cell a spreadsheet would read as a formula
+func TestExportFormulaGuard(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"=1+1", "'=1+1"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1+1", "'\t=1+1"},
+		{"\r=1+1", "'\r=1+1"},
+		{"Go 1+1=2", "Go 1+1=2"},
+	}
+	for _, tt := range tests {
+		router := useRepository(t, newMemoryRepository(database.CourseInfo{Code: 1, Title: tt.title}))
+		for format, want := range map[string]string{"excel": tt.want, "csv": tt.title} {
+			//the row is compared as written, since reading the CSV back would drop a carriage return
+			var row strings.Builder
+			writer := csv.NewWriter(&row)
+			writer.UseCRLF = format == "excel"
+			writer.Write([]string{"1", want, "", "", ""})
+			writer.Flush()
+
+			w := serve(router, "GET", "/api/v1/courses/export?key=k&format="+format, nil)
+			if !strings.Contains(w.Body.String(), row.String()) {
+				t.Errorf("%s export of %q: %q, want the row %q", format, tt.title, w.Body.String(), row.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"goMS1Assignment/REST/database"
+	"goMS1Assignment/REST/search"
+
+	"github.com/graphql-go/graphql"
+	log "github.com/sirupsen/logrus"
+)
+
+//maxGraphQLBytes bounds the size of a GraphQL request body
+const maxGraphQLBytes = 1 << 20
+
+//graphqlRequest is the body of a GraphQL POST, or the query parameters of a GET
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+//graphqlErrors is the response for a request rejected before it runs
+type graphqlErrors struct {
+	Errors []graphqlError `json:"errors"`
+}
+
+type graphqlError struct {
+	Message string `json:"message"`
+}
+
+var courseSchema graphql.Schema
+
+//courseField resolves one field of a course from its CourseInfo
+func courseField(typ graphql.Output, get func(database.CourseInfo) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: typ,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(database.CourseInfo)), nil
+		},
+	}
+}
+
+var courseType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Course",
+	Fields: graphql.Fields{
+		"code":        courseField(graphql.NewNonNull(graphql.Int), func(c database.CourseInfo) interface{} { return c.Code }),
+		"title":       courseField(graphql.String, func(c database.CourseInfo) interface{} { return c.Title }),
+		"dates":       courseField(graphql.String, func(c database.CourseInfo) interface{} { return c.Dates }),
+		"lecturer":    courseField(graphql.String, func(c database.CourseInfo) interface{} { return c.Lecturer }),
+		"description": courseField(graphql.String, func(c database.CourseInfo) interface{} { return c.Description }),
+		"updatedAt": courseField(graphql.String, func(c database.CourseInfo) interface{} {
+			if c.UpdatedAt.IsZero() {
+				return nil
+			}
+			return c.UpdatedAt.UTC().Format(time.RFC3339)
+		}),
+	},
+})
+
+//coursePage is one page of a course listing
+type coursePage struct {
+	Items  []database.CourseInfo
+	Total  int
+	Offset int
+	Limit  int
+}
+
+var coursePageType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "CoursePage",
+	Fields: graphql.Fields{
+		"items": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(courseType))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(coursePage).Items, nil },
+		},
+		"total": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(coursePage).Total, nil },
+		},
+		"offset": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(coursePage).Offset, nil },
+		},
+		"limit": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.Int),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(coursePage).Limit, nil },
+		},
+		"hasMore": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.Boolean),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				page := p.Source.(coursePage)
+				return page.Offset+len(page.Items) < page.Total, nil
+			},
+		},
+	},
+})
+
+var snippetType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Snippet",
+	Fields: graphql.Fields{
+		"field": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"text":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var searchResultType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "SearchResult",
+	Fields: graphql.Fields{
+		"course": &graphql.Field{
+			Type:    graphql.NewNonNull(courseType),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(search.Result).Course, nil },
+		},
+		"score": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.Float),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(search.Result).Score, nil },
+		},
+		"snippets": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(snippetType))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				result := p.Source.(search.Result)
+				snippets := make([]map[string]interface{}, 0, len(result.Snippets))
+				for field, text := range result.Snippets {
+					snippets = append(snippets, map[string]interface{}{"field": field, "text": text})
+				}
+				sort.Slice(snippets, func(i, j int) bool { return snippets[i]["field"].(string) < snippets[j]["field"].(string) })
+				return snippets, nil
+			},
+		},
+	},
+})
+
+//lecturer groups the courses taught by one lecturer
+type lecturer struct {
+	Name    string
+	Courses []database.CourseInfo
+}
+
+var lecturerType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Lecturer",
+	Fields: graphql.Fields{
+		"name": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.String),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(lecturer).Name, nil },
+		},
+		"courses": &graphql.Field{
+			Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(courseType))),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) { return p.Source.(lecturer).Courses, nil },
+		},
+	},
+})
+
+var courseFilterType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "CourseFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"lecturer": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"title":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+//courseInputType carries a course to create, or the code and changed fields of a course to update
+var courseInputType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "CourseInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"code":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.Int)},
+		"title":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"dates":       &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"lecturer":    &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"description": &graphql.InputObjectFieldConfig{Type: graphql.String},
+	},
+})
+
+func init() {
+	var err error
+	courseSchema, err = graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"course": &graphql.Field{
+					Type:    courseType,
+					Args:    graphql.FieldConfigArgument{"code": {Type: graphql.NewNonNull(graphql.Int)}},
+					Resolve: resolveCourse,
+				},
+				"courses": &graphql.Field{
+					Type: graphql.NewNonNull(coursePageType),
+					Args: graphql.FieldConfigArgument{
+						"filter": {Type: courseFilterType},
+						"offset": {Type: graphql.Int, DefaultValue: 0},
+						"limit":  {Type: graphql.Int, DefaultValue: 20},
+					},
+					Resolve: resolveCourses,
+				},
+				"search": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(searchResultType))),
+					Args: graphql.FieldConfigArgument{
+						"query": {Type: graphql.NewNonNull(graphql.String)},
+						"limit": {Type: graphql.Int, DefaultValue: 20},
+						"fuzzy": {Type: graphql.Boolean, DefaultValue: false},
+					},
+					Resolve: resolveSearch,
+				},
+				"lecturers": &graphql.Field{
+					Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(lecturerType))),
+					Resolve: resolveLecturers,
+				},
+			},
+		}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Mutation",
+			Fields: graphql.Fields{
+				"createCourse": &graphql.Field{
+					Type:    graphql.NewNonNull(courseType),
+					Args:    graphql.FieldConfigArgument{"input": {Type: graphql.NewNonNull(courseInputType)}},
+					Resolve: resolveCreateCourse,
+				},
+				"updateCourse": &graphql.Field{
+					Type:    graphql.NewNonNull(courseType),
+					Args:    graphql.FieldConfigArgument{"input": {Type: graphql.NewNonNull(courseInputType)}},
+					Resolve: resolveUpdateCourse,
+				},
+				"deleteCourse": &graphql.Field{
+					Type:    graphql.NewNonNull(courseType),
+					Args:    graphql.FieldConfigArgument{"code": {Type: graphql.NewNonNull(graphql.Int)}},
+					Resolve: resolveDeleteCourse,
+				},
+			},
+		}),
+	})
+	if err != nil {
+		log.Fatal("Error building GraphQL schema: ", err)
+	}
+}
+
+//pageArgs reads the limit argument, which like the REST limit must lie between 1 and 100
+func pageArgs(p graphql.ResolveParams) (int, error) {
+	limit, _ := p.Args["limit"].(int)
+	if limit < 1 || limit > 100 {
+		return 0, errors.New("limit must be an integer between 1 and 100")
+	}
+	return limit, nil
+}
+
+func resolveCourse(p graphql.ResolveParams) (interface{}, error) {
+	course, exist, err := repository.FindRecord(p.Context, p.Args["code"].(int))
+	if err != nil {
+		return nil, graphqlDatabaseError(err)
+	}
+	if !exist {
+		return nil, nil
+	}
+	validateAndSanitize(&course)
+	return course, nil
+}
+
+func resolveCourses(p graphql.ResolveParams) (interface{}, error) {
+	limit, err := pageArgs(p)
+	if err != nil {
+		return nil, err
+	}
+	offset, _ := p.Args["offset"].(int)
+	if offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+	var filter database.Filter
+	if f, ok := p.Args["filter"].(map[string]interface{}); ok {
+		filter.Lecturer, _ = f["lecturer"].(string)
+		filter.Title, _ = f["title"].(string)
+	}
+
+	page := coursePage{Items: []database.CourseInfo{}, Offset: offset, Limit: limit}
+	err = repository.EachRecord(p.Context, filter, func(course database.CourseInfo) error {
+		if page.Total >= offset && len(page.Items) < limit {
+			validateAndSanitize(&course)
+			page.Items = append(page.Items, course)
+		}
+		page.Total++
+		return nil
+	})
+	if err != nil {
+		return nil, graphqlDatabaseError(err)
+	}
+	return page, nil
+}
+
+func resolveSearch(p graphql.ResolveParams) (interface{}, error) {
+	limit, err := pageArgs(p)
+	if err != nil {
+		return nil, err
+	}
+	q := p.Args["query"].(string)
+	if len(search.Terms(q)) == 0 {
+		return nil, errors.New("Please supply a search query")
+	}
+	var results []search.Result
+	if fuzzy, _ := p.Args["fuzzy"].(bool); fuzzy {
+		results, err = courseIndex.FuzzySearch(p.Context, q, limit)
+	} else {
+		results, err = searcher.Search(p.Context, q, limit)
+	}
+	if err != nil {
+		return nil, graphqlDatabaseError(err)
+	}
+	for i := range results {
+		validateAndSanitize(&results[i].Course)
+	}
+	return results, nil
+}
+
+func resolveLecturers(p graphql.ResolveParams) (interface{}, error) {
+	courses, err := repository.GetRecords(p.Context)
+	if err != nil {
+		return nil, graphqlDatabaseError(err)
+	}
+	byName := make(map[string]*lecturer)
+	var lecturers []*lecturer
+	for _, course := range courses {
+		validateAndSanitize(&course)
+		l, ok := byName[course.Lecturer]
+		if !ok {
+			l = &lecturer{Name: course.Lecturer}
+			byName[course.Lecturer] = l
+			lecturers = append(lecturers, l)
+		}
+		l.Courses = append(l.Courses, course)
+	}
+	sort.Slice(lecturers, func(i, j int) bool { return lecturers[i].Name < lecturers[j].Name })
+	result := make([]lecturer, len(lecturers))
+	for i, l := range lecturers {
+		sort.Slice(l.Courses, func(a, b int) bool { return l.Courses[a].Code < l.Courses[b].Code })
+		result[i] = *l
+	}
+	return result, nil
+}
+
+//courseInput reads a CourseInput argument and validates and sanitizes it like a REST request body
+func courseInput(p graphql.ResolveParams) (database.CourseInfo, error) {
+	input := p.Args["input"].(map[string]interface{})
+	var course database.CourseInfo
+	course.Code = input["code"].(int)
+	course.Title, _ = input["title"].(string)
+	course.Dates, _ = input["dates"].(string)
+	course.Lecturer, _ = input["lecturer"].(string)
+	course.Description, _ = input["description"].(string)
+	if err := validateAndSanitize(&course); err != nil {
+		return course, errors.New("Course information in wrong format")
+	}
+	return course, nil
+}
+
+func resolveCreateCourse(p graphql.ResolveParams) (interface{}, error) {
+	course, err := courseInput(p)
+	if err != nil {
+		return nil, err
+	}
+	if course.Title == "" || course.Dates == "" || course.Lecturer == "" || course.Description == "" {
+		return nil, errors.New("Please supply course information")
+	}
+	_, exist, err := repository.FindRecord(p.Context, course.Code)
+	if err != nil {
+		return nil, graphqlDatabaseError(err)
+	}
+	if exist {
+		return nil, errors.New("Duplicate course ID")
+	}
+	if err := repository.InsertRecord(p.Context, course); err != nil {
+		return nil, graphqlDatabaseError(err)
+	}
+	return course, nil
+}
+
+func resolveUpdateCourse(p graphql.ResolveParams) (interface{}, error) {
+	course, err := courseInput(p)
+	if err != nil {
+		return nil, err
+	}
+	existing, exist, err := repository.FindRecord(p.Context, course.Code)
+	if err != nil {
+		return nil, graphqlDatabaseError(err)
+	}
+	if !exist {
+		return nil, errors.New("No course found")
+	}
+	if course.Title == "" {
+		course.Title = existing.Title
+	}
+	if course.Dates == "" {
+		course.Dates = existing.Dates
+	}
+	if course.Lecturer == "" {
+		course.Lecturer = existing.Lecturer
+	}
+	if course.Description == "" {
+		course.Description = existing.Description
+	}
+	if err := repository.EditRecord(p.Context, course); err != nil {
+		return nil, graphqlDatabaseError(err)
+	}
+	validateAndSanitize(&course)
+	return course, nil
+}
+
+func resolveDeleteCourse(p graphql.ResolveParams) (interface{}, error) {
+	code := p.Args["code"].(int)
+	course, exist, err := repository.FindRecord(p.Context, code)
+	if err != nil {
+		return nil, graphqlDatabaseError(err)
+	}
+	if !exist {
+		return nil, errors.New("No course found")
+	}
+	if err := repository.DeleteRecord(p.Context, code); err != nil {
+		return nil, graphqlDatabaseError(err)
+	}
+	validateAndSanitize(&course)
+	return course, nil
+}
+
+//graphqlDatabaseError logs a failed query and hides its details from the client, as databaseError does for REST
+func graphqlDatabaseError(err error) error {
+	log.Error("Database query failed. ", err.Error())
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errors.New("Database did not respond in time")
+	}
+	return errors.New("Database error")
+}
+
+//graphqlHandler answers GraphQL queries sent as a JSON POST body or, for queries only, as GET query parameters.
+//Requests are checked against the depth and complexity limits before they run.
+func graphqlHandler(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r) {
+		return
+	}
+	var request graphqlRequest
+	if r.Method == "GET" {
+		query := r.URL.Query()
+		request.Query, request.OperationName = query.Get("query"), query.Get("operationName")
+		if v := query.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &request.Variables); err != nil {
+				writeGraphQLErrors(w, http.StatusBadRequest, "variables must be a JSON object")
+				return
+			}
+		}
+	} else {
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxGraphQLBytes))
+		if err == nil {
+			err = json.Unmarshal(body, &request)
+		}
+		if err != nil {
+			writeGraphQLErrors(w, http.StatusBadRequest, "Please supply a JSON body with query, variables and operationName")
+			return
+		}
+	}
+	if request.Query == "" {
+		writeGraphQLErrors(w, http.StatusBadRequest, "Please supply a query")
+		return
+	}
+	isMutation, err := checkGraphQLLimits(request)
+	if err != nil {
+		writeGraphQLErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if isMutation && r.Method == "GET" {
+		writeGraphQLErrors(w, http.StatusMethodNotAllowed, "Mutations must be sent with POST")
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+	result := graphql.Do(graphql.Params{
+		Schema:         courseSchema,
+		RequestString:  request.Query,
+		VariableValues: request.Variables,
+		OperationName:  request.OperationName,
+		Context:        ctx,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func writeGraphQLErrors(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(graphqlErrors{Errors: []graphqlError{{Message: message}}})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+const (
+	maxGraphQLDepth      = 6    //nesting of selections, e.g. lecturers { courses { title } } is 3
+	maxGraphQLComplexity = 1000 //see graphqlLimits.cost
+	defaultListSize      = 20   //assumed size of a list field without a limit argument
+)
+
+//graphqlLimits measures a query before it runs
+type graphqlLimits struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	visiting  map[string]bool //fragments being expanded, to stop cycles
+}
+
+//checkGraphQLLimits rejects queries nested deeper than maxGraphQLDepth or costing more than maxGraphQLComplexity,
+//and fragments that spread themselves, which the graphql library's validation would recurse on forever.
+//It reports whether the operation that will run is a mutation. Syntax errors are left for the executor to report.
+func checkGraphQLLimits(request graphqlRequest) (bool, error) {
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(request.Query)})})
+	if err != nil {
+		return false, nil
+	}
+	limits := graphqlLimits{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: request.Variables,
+		visiting:  make(map[string]bool),
+	}
+	var operations []*ast.OperationDefinition
+	for _, definition := range doc.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			limits.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			operations = append(operations, definition)
+		}
+	}
+
+	for name := range limits.fragments {
+		if limits.spreadsItself(name, name, make(map[string]bool)) {
+			return false, fmt.Errorf("fragment %s spreads itself", name)
+		}
+	}
+
+	isMutation := false
+	for _, operation := range operations {
+		name := ""
+		if operation.Name != nil {
+			name = operation.Name.Value
+		}
+		if request.OperationName != "" && name != request.OperationName {
+			continue
+		}
+		isMutation = isMutation || operation.Operation == ast.OperationTypeMutation
+		depth, cost := limits.measure(operation.SelectionSet)
+		if depth > maxGraphQLDepth {
+			return isMutation, fmt.Errorf("query is nested %d levels deep, the limit is %d", depth, maxGraphQLDepth)
+		}
+		if cost > maxGraphQLComplexity {
+			return isMutation, fmt.Errorf("query complexity is %d, the limit is %d", cost, maxGraphQLComplexity)
+		}
+	}
+	return isMutation, nil
+}
+
+//measure returns the depth and cost of a selection set. Every field costs 1; the fields below a field that takes
+//a limit argument, or returns a list of courses, count once for every item it can return.
+//Introspection fields are not counted, so tools can load the schema.
+func (l *graphqlLimits) measure(set *ast.SelectionSet) (depth, cost int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, selection := range set.Selections {
+		var d, c int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if len(selection.Name.Value) > 1 && selection.Name.Value[:2] == "__" {
+				continue
+			}
+			d, c = l.measure(selection.SelectionSet)
+			d++
+			c = 1 + c*l.listSize(selection)
+		case *ast.InlineFragment:
+			d, c = l.measure(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment, ok := l.fragments[name]
+			if !ok || l.visiting[name] {
+				continue
+			}
+			l.visiting[name] = true
+			d, c = l.measure(fragment.SelectionSet)
+			l.visiting[name] = false
+		}
+		if d > depth {
+			depth = d
+		}
+		cost += c
+	}
+	return depth, cost
+}
+
+//spreadsItself reports whether fragment name reaches target through its fragment spreads
+func (l *graphqlLimits) spreadsItself(name, target string, seen map[string]bool) bool {
+	fragment, ok := l.fragments[name]
+	if !ok || seen[name] {
+		return false
+	}
+	seen[name] = true
+	for _, spread := range fragmentSpreads(fragment.SelectionSet, nil) {
+		if spread == target || l.spreadsItself(spread, target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+//fragmentSpreads appends the names of the fragments spread anywhere in set
+func fragmentSpreads(set *ast.SelectionSet, names []string) []string {
+	if set == nil {
+		return names
+	}
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			names = fragmentSpreads(selection.SelectionSet, names)
+		case *ast.InlineFragment:
+			names = fragmentSpreads(selection.SelectionSet, names)
+		case *ast.FragmentSpread:
+			names = append(names, selection.Name.Value)
+		}
+	}
+	return names
+}
+
+//listSize is how many items a field can return: its limit argument, or defaultListSize for the other list fields
+func (l *graphqlLimits) listSize(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "limit" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(value.Value); err == nil && n > 0 {
+				return n
+			}
+		case *ast.Variable:
+			if n, ok := l.variables[value.Name.Value].(float64); ok && n > 0 {
+				return int(n)
+			}
+		}
+		return defaultListSize
+	}
+	switch field.Name.Value {
+	case "courses", "lecturers", "search", "snippets":
+		return defaultListSize
+	}
+	return 1
+}
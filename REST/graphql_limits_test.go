@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+//nested returns a query selecting depth fields, each inside the one before
+func nested(depth int) string {
+	return strings.Repeat("{ f ", depth) + strings.Repeat("}", depth)
+}
+
+//TestGraphQLLimits checks queries just under the depth and complexity limits pass and those just over them do not,
+//and that fragments cannot hide depth or spread themselves
+func TestGraphQLLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   graphqlRequest
+		wantError string
+		mutation  bool
+	}{
+		{"at the depth limit", graphqlRequest{Query: nested(maxGraphQLDepth)}, "", false},
+		{"over the depth limit", graphqlRequest{Query: nested(maxGraphQLDepth + 1)}, "nested 7 levels deep", false},
+		{"depth through a fragment", graphqlRequest{Query: "{ a { b { ...F } } } fragment F on Course " + nested(maxGraphQLDepth-1)},
+			"nested 7 levels deep", false},
+		{"depth through an inline fragment", graphqlRequest{Query: "{ a { ... on Course " + nested(maxGraphQLDepth) + " } }"},
+			"nested 7 levels deep", false},
+		//1 for the list and 1 for each item's field
+		{"at the complexity cap", graphqlRequest{Query: "{ courses(limit: 999) { code } }"}, "", false},
+		{"over the complexity cap", graphqlRequest{Query: "{ courses(limit: 1000) { code } }"}, "complexity is 1001", false},
+		{"limit from a variable", graphqlRequest{Query: "query Q($n: Int) { courses(limit: $n) { code } }", Variables: map[string]interface{}{"n": 1000.0}},
+			"complexity is 1001", false},
+		//lists without a limit count defaultListSize items
+		{"nested lists", graphqlRequest{Query: "{ lecturers { courses { code title } } }"}, "", false},
+		{"nested lists over the cap", graphqlRequest{Query: "{ lecturers { courses { code title dates } } }"}, "complexity is 1221", false},
+		{"introspection is free", graphqlRequest{Query: "{ __schema { types { fields { type { ofType { ofType { ofType { name } } } } } } } }"}, "", false},
+		{"fragment spreading itself", graphqlRequest{Query: "{ course { ...F } } fragment F on Course { title ...F }"}, "fragment F spreads itself", false},
+		{"fragment cycle", graphqlRequest{Query: "{ course { ...A } } fragment A on Course { title ...B } fragment B on Course { code ...A }"},
+			"spreads itself", false},
+		{"fragment used twice", graphqlRequest{Query: "{ a { ...F } b { ...F } } fragment F on Course { code }"}, "", false},
+		{"mutation", graphqlRequest{Query: "mutation { deleteCourse(code: 1) { code } }"}, "", true},
+		{"query chosen among a mutation", graphqlRequest{Query: "query Q { a } mutation M { b }", OperationName: "Q"}, "", false},
+		{"mutation chosen among a query", graphqlRequest{Query: "query Q { a } mutation M { b }", OperationName: "M"}, "", true},
+		//syntax errors are reported by the executor
+		{"syntax error", graphqlRequest{Query: "{ a { "}, "", false},
+	}
+	for _, tt := range tests {
+		mutation, err := checkGraphQLLimits(tt.request)
+		switch {
+		case tt.wantError == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantError != "" && (err == nil || !strings.Contains(err.Error(), tt.wantError)):
+			t.Errorf("%s: %v, want an error containing %q", tt.name, err, tt.wantError)
+		}
+		if mutation != tt.mutation {
+			t.Errorf("%s: mutation %v, want %v", tt.name, mutation, tt.mutation)
+		}
+	}
+}
+
+//TestGraphQLHandlerLimits checks the handler refuses queries over the limits before they run, and mutations sent with GET
+func TestGraphQLHandlerLimits(t *testing.T) {
+	router := useRepository(t, newMemoryRepository())
+	get := func(query string) int {
+		return serve(router, "GET", "/api/v1/graphql?key=k&query="+url.QueryEscape(query), nil).Code
+	}
+	if code := get(nested(maxGraphQLDepth + 1)); code != http.StatusBadRequest {
+		t.Errorf("query over the depth limit: status %d, want 400", code)
+	}
+	if code := get("mutation { deleteCourse(code: 1) { code } }"); code != http.StatusMethodNotAllowed {
+		t.Errorf("mutation sent with GET: status %d, want 405", code)
+	}
+	if code := get("{ courses { code } }"); code != http.StatusOK {
+		t.Errorf("query sent with GET: status %d, want 200", code)
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"goMS1Assignment/REST/coursepb"
+	"goMS1Assignment/REST/database"
+	"goMS1Assignment/REST/events"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//eventTypes maps course event types to their protobuf values
+var eventTypes = map[string]coursepb.CourseEvent_Type{
+	events.Created: coursepb.CourseEvent_CREATED,
+	events.Updated: coursepb.CourseEvent_UPDATED,
+	events.Deleted: coursepb.CourseEvent_DELETED,
+}
+
+//courseServer implements the gRPC CourseService over the same repository and validation as the REST handlers
+type courseServer struct {
+	coursepb.UnimplementedCourseServiceServer
+}
+
+//serveGRPC serves CourseService on addr with the TLS settings of the REST API; it only returns if the listener fails
+func serveGRPC(addr string, tlsConfig *tls.Config) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatal("Error starting gRPC listener: ", err)
+	}
+	server := newGRPCServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+
+	fmt.Println("gRPC listening at " + addr)
+	log.Fatal(server.Serve(listener))
+}
+
+//newGRPCServer returns a server with CourseService registered, checking the access key of every call.
+//Idle connections are pinged every 30 seconds so Watch streams survive proxies that close quiet connections.
+func newGRPCServer(options ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(append(options,
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := grpcKey(ctx); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := grpcKey(stream.Context()); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: 30 * time.Second}),
+	)...)
+	coursepb.RegisterCourseServiceServer(server, &courseServer{})
+	return server
+}
+
+//grpcKey checks the access key, which gRPC clients send in the "key" metadata entry
+func grpcKey(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	key := md.Get("key")
+	if len(key) == 0 {
+		return status.Error(codes.Unauthenticated, "Please supply access key")
+	}
+	if key[0] != API_key {
+		return status.Error(codes.Unauthenticated, "Invalid key")
+	}
+	return nil
+}
+
+//grpcDatabaseError logs a failed query and returns a status that does not reveal its details
+func grpcDatabaseError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Error("Database query timed out. ", err.Error())
+		return status.Error(codes.DeadlineExceeded, "Database did not respond in time")
+	}
+	log.Error("Database query failed. ", err.Error())
+	return status.Error(codes.Internal, "Database error")
+}
+
+//courseMessage converts a stored course for sending, sanitized like REST responses
+func courseMessage(course database.CourseInfo) *coursepb.Course {
+	validateAndSanitize(&course)
+	message := &coursepb.Course{
+		Code:        int32(course.Code),
+		Title:       course.Title,
+		Dates:       course.Dates,
+		Lecturer:    course.Lecturer,
+		Description: course.Description,
+	}
+	if !course.UpdatedAt.IsZero() {
+		message.UpdatedAt = timestamppb.New(course.UpdatedAt)
+	}
+	return message
+}
+
+//courseFromMessage validates and sanitizes a course received in a request, like a REST request body
+func courseFromMessage(message *coursepb.Course) (database.CourseInfo, error) {
+	if message == nil {
+		return database.CourseInfo{}, status.Error(codes.InvalidArgument, "Please supply course information")
+	}
+	course := database.CourseInfo{
+		Code:        int(message.Code),
+		Title:       message.Title,
+		Dates:       message.Dates,
+		Lecturer:    message.Lecturer,
+		Description: message.Description,
+	}
+	if err := validateAndSanitize(&course); err != nil {
+		return course, status.Error(codes.InvalidArgument, "Course information in wrong format")
+	}
+	return course, nil
+}
+
+//stored reads a course back after a write so the reply carries its update time
+func stored(ctx context.Context, course database.CourseInfo) (*coursepb.Course, error) {
+	saved, exist, err := repository.FindRecord(ctx, course.Code)
+	if err != nil {
+		return nil, grpcDatabaseError(err)
+	}
+	if exist {
+		course = saved
+	}
+	return courseMessage(course), nil
+}
+
+func (s *courseServer) Get(ctx context.Context, req *coursepb.GetCourseRequest) (*coursepb.Course, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	course, exist, err := repository.FindRecord(ctx, int(req.Code))
+	if err != nil {
+		return nil, grpcDatabaseError(err)
+	}
+	if !exist {
+		return nil, status.Error(codes.NotFound, "No course found")
+	}
+	return courseMessage(course), nil
+}
+
+//List streams every course matching the filters. Like an export it is bounded by exportTimeout, as a large
+//listing sent to a slow client can take far longer than one query.
+func (s *courseServer) List(req *coursepb.ListCoursesRequest, stream coursepb.CourseService_ListServer) error {
+	ctx, cancel := context.WithTimeout(stream.Context(), exportTimeout)
+	defer cancel()
+
+	filter := database.Filter{Title: req.Title, Lecturer: req.Lecturer}
+	err := repository.EachRecord(ctx, filter, func(course database.CourseInfo) error {
+		return stream.Send(courseMessage(course))
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err //the client went away
+		}
+		return grpcDatabaseError(err)
+	}
+	return nil
+}
+
+func (s *courseServer) Create(ctx context.Context, req *coursepb.CreateCourseRequest) (*coursepb.Course, error) {
+	course, err := courseFromMessage(req.Course)
+	if err != nil {
+		return nil, err
+	}
+	if course.Title == "" || course.Dates == "" || course.Lecturer == "" || course.Description == "" {
+		return nil, status.Error(codes.InvalidArgument, "Please supply course information")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	_, exist, err := repository.FindRecord(ctx, course.Code)
+	if err != nil {
+		return nil, grpcDatabaseError(err)
+	}
+	if exist {
+		return nil, status.Error(codes.AlreadyExists, "Duplicate course ID")
+	}
+	if err := repository.InsertRecord(ctx, course); err != nil {
+		return nil, grpcDatabaseError(err)
+	}
+	return stored(ctx, course)
+}
+
+func (s *courseServer) Update(ctx context.Context, req *coursepb.UpdateCourseRequest) (*coursepb.Course, error) {
+	course, err := courseFromMessage(req.Course)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	existing, exist, err := repository.FindRecord(ctx, course.Code)
+	if err != nil {
+		return nil, grpcDatabaseError(err)
+	}
+	if !exist {
+		return nil, status.Error(codes.NotFound, "No course found")
+	}
+	if course.Title == "" {
+		course.Title = existing.Title
+	}
+	if course.Dates == "" {
+		course.Dates = existing.Dates
+	}
+	if course.Lecturer == "" {
+		course.Lecturer = existing.Lecturer
+	}
+	if course.Description == "" {
+		course.Description = existing.Description
+	}
+	if err := repository.EditRecord(ctx, course); err != nil {
+		return nil, grpcDatabaseError(err)
+	}
+	return stored(ctx, course)
+}
+
+func (s *courseServer) Delete(ctx context.Context, req *coursepb.DeleteCourseRequest) (*coursepb.Course, error) {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	course, exist, err := repository.FindRecord(ctx, int(req.Code))
+	if err != nil {
+		return nil, grpcDatabaseError(err)
+	}
+	if !exist {
+		return nil, status.Error(codes.NotFound, "No course found")
+	}
+	if err := repository.DeleteRecord(ctx, int(req.Code)); err != nil {
+		return nil, grpcDatabaseError(err)
+	}
+	return courseMessage(course), nil
+}
+
+//Watch streams course changes from the event log shared with the SSE and WebSocket endpoints.
+//A client that cannot keep up is ended with UNAVAILABLE and can resume from the last event it received.
+func (s *courseServer) Watch(req *coursepb.WatchCoursesRequest, stream coursepb.CourseService_WatchServer) error {
+	sub := &subscription{codes: make(map[int]bool), lecturers: make(map[string]bool)}
+	request := socketRequest{Type: "subscribe", Lecturers: req.Lecturers}
+	for _, code := range req.Codes {
+		request.Codes = append(request.Codes, int(code))
+	}
+	if _, err := sub.apply(request); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	all := len(req.Codes) == 0 && len(req.Lecturers) == 0
+
+	backlog, complete, updates, cancel := courseEvents.Subscribe(req.LastEventId)
+	defer cancel()
+
+	send := func(event events.Event) error {
+		if !all && !sub.matches(event.Course) {
+			return nil
+		}
+		return stream.Send(&coursepb.CourseEvent{
+			Id:     event.ID,
+			Type:   eventTypes[event.Type],
+			Course: courseMessage(event.Course),
+			Time:   timestamppb.New(event.Time),
+		})
+	}
+	if !complete {
+		if err := stream.Send(&coursepb.CourseEvent{Type: coursepb.CourseEvent_RESET, Time: timestamppb.Now()}); err != nil {
+			return err
+		}
+	}
+	for _, event := range backlog {
+		if err := send(event); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-updates:
+			if !ok {
+				log.Warning("gRPC watch subscriber too slow, closing stream")
+				return status.Error(codes.Unavailable, "Client too slow, resume with the last event ID")
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
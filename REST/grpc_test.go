@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"goMS1Assignment/REST/coursepb"
+	"goMS1Assignment/REST/database"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+//grpcClient serves CourseService over an in-process connection with repo behind it
+func grpcClient(t *testing.T, repo database.Repository) coursepb.CourseServiceClient {
+	useRepository(t, repo)
+	listener := bufconn.Listen(1 << 20)
+	server := newGRPCServer()
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return coursepb.NewCourseServiceClient(conn)
+}
+
+//withKey returns a context carrying the access key the way gRPC clients send it
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "key", key)
+}
+
+//listCodes returns the codes streamed by List
+func listCodes(t *testing.T, client coursepb.CourseServiceClient, req *coursepb.ListCoursesRequest) []int32 {
+	stream, err := client.List(withKey("k"), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var codes []int32
+	for {
+		course, err := stream.Recv()
+		if err == io.EOF {
+			return codes
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes = append(codes, course.Code)
+	}
+}
+
+//TestGRPCRoundTrip creates, reads, lists, updates and deletes a course through the gRPC service
+func TestGRPCRoundTrip(t *testing.T) {
+	repo := newMemoryRepository(database.CourseInfo{Code: 1, Title: "Web", Dates: "Jan", Lecturer: "Tan", Description: "servers"})
+	client := grpcClient(t, repo)
+	ctx := withKey("k")
+
+	created, err := client.Create(ctx, &coursepb.CreateCourseRequest{Course: &coursepb.Course{Code: 2, Title: "Go", Dates: "Feb", Lecturer: "Lee", Description: "basics"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Title != "Go" || created.UpdatedAt == nil {
+		t.Errorf("created %v", created)
+	}
+	got, err := client.Get(ctx, &coursepb.GetCourseRequest{Code: 2})
+	if err != nil || got.Lecturer != "Lee" {
+		t.Errorf("get: %v, %v", got, err)
+	}
+	if codes := listCodes(t, client, &coursepb.ListCoursesRequest{Lecturer: "Lee"}); len(codes) != 1 || codes[0] != 2 {
+		t.Errorf("list by lecturer: %v, want [2]", codes)
+	}
+	if codes := listCodes(t, client, &coursepb.ListCoursesRequest{}); len(codes) != 2 {
+		t.Errorf("list: %v, want both courses", codes)
+	}
+
+	//fields left out keep their values
+	updated, err := client.Update(ctx, &coursepb.UpdateCourseRequest{Course: &coursepb.Course{Code: 2, Title: "Advanced Go"}})
+	if err != nil || updated.Title != "Advanced Go" || updated.Lecturer != "Lee" {
+		t.Errorf("update: %v, %v", updated, err)
+	}
+	if _, err := client.Delete(ctx, &coursepb.DeleteCourseRequest{Code: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, exist, _ := repo.FindRecord(context.Background(), 2); exist {
+		t.Error("course 2 was not deleted")
+	}
+}
+
+//TestGRPCErrors checks failed calls get the gRPC code matching the REST status
+func TestGRPCErrors(t *testing.T) {
+	client := grpcClient(t, newMemoryRepository(database.CourseInfo{Code: 1, Title: "Web", Dates: "Jan", Lecturer: "Tan", Description: "servers"}))
+	complete := &coursepb.Course{Code: 1, Title: "Go", Dates: "Feb", Lecturer: "Lee", Description: "basics"}
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"no key", func() error {
+			_, err := client.Get(context.Background(), &coursepb.GetCourseRequest{Code: 1})
+			return err
+		}, codes.Unauthenticated},
+		{"wrong key", func() error {
+			_, err := client.Get(withKey("x"), &coursepb.GetCourseRequest{Code: 1})
+			return err
+		}, codes.Unauthenticated},
+		{"list without a key", func() error {
+			stream, err := client.List(context.Background(), &coursepb.ListCoursesRequest{})
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			return err
+		}, codes.Unauthenticated},
+		{"missing course", func() error {
+			_, err := client.Get(withKey("k"), &coursepb.GetCourseRequest{Code: 9})
+			return err
+		}, codes.NotFound},
+		{"duplicate", func() error {
+			_, err := client.Create(withKey("k"), &coursepb.CreateCourseRequest{Course: complete})
+			return err
+		}, codes.AlreadyExists},
+		{"incomplete", func() error {
+			_, err := client.Create(withKey("k"), &coursepb.CreateCourseRequest{Course: &coursepb.Course{Code: 3, Title: "Go"}})
+			return err
+		}, codes.InvalidArgument},
+		{"wrong format", func() error {
+			_, err := client.Update(withKey("k"), &coursepb.UpdateCourseRequest{Course: &coursepb.Course{Code: 1, Title: "<b>{}</b>"}})
+			return err
+		}, codes.InvalidArgument},
+		{"watching too many codes", func() error {
+			req := &coursepb.WatchCoursesRequest{Lecturers: []string{"Tan"}}
+			for code := int32(1); code <= socketMaxFilters; code++ {
+				req.Codes = append(req.Codes, code)
+			}
+			stream, err := client.Watch(withKey("k"), req)
+			if err == nil {
+				_, err = stream.Recv()
+			}
+			return err
+		}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		if got := status.Code(tt.call()); got != tt.want {
+			t.Errorf("%s: code %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+//TestGRPCListTimeout checks List is bounded by the export timeout rather than the per-request query timeout
+func TestGRPCListTimeout(t *testing.T) {
+	repo := &deadlineRepository{memoryRepository: newMemoryRepository(database.CourseInfo{Code: 1, Title: "Go"})}
+	client := grpcClient(t, repo)
+	start := time.Now()
+	listCodes(t, client, &coursepb.ListCoursesRequest{})
+	if repo.deadline.Before(start.Add(exportTimeout)) || repo.deadline.After(time.Now().Add(exportTimeout)) {
+		t.Errorf("courses listed with %v left, want the export timeout of %v", repo.deadline.Sub(start), exportTimeout)
+	}
+}
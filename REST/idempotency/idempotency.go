@@ -0,0 +1,178 @@
+//Package idempotency lets clients retry writes safely with an Idempotency-Key header.
+//The first response to each key is stored and replayed for later requests that carry the same key.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"goMS1Assignment/REST/cache"
+	"goMS1Assignment/REST/render"
+)
+
+const (
+	//Header is the request header carrying the client's key
+	Header = "Idempotency-Key"
+	//ReplayedHeader marks a response that was replayed from the store rather than produced again
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+	maxBodyBytes = 10 << 20
+)
+
+//response is what the store holds for one key once the first request has been answered
+type response struct {
+	fingerprint string
+	status      int
+	header      http.Header
+	body        []byte
+}
+
+//Store remembers the first response for each key and client
+type Store struct {
+	mu      sync.Mutex //makes claiming a key atomic
+	entries cache.Cache
+	//pending holds the fingerprint of each key whose first request is still running. It is kept apart from
+	//entries, which evicts under load, so a retry cannot run the write again while the first one is in flight.
+	pending map[string]string
+}
+
+//NewStore keeps at most size responses, each for ttl
+func NewStore(size int, ttl time.Duration) *Store {
+	return &Store{entries: cache.NewLRU(size, ttl), pending: make(map[string]string)}
+}
+
+//Middleware applies the store to POST and PATCH requests that carry an Idempotency-Key.
+//A retry with the same key and body gets the stored response, a different body under the same key gets 422
+//and a retry while the first request is still running gets 409.
+//Server errors are not stored, so a request that failed that way can be retried for real.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" || (r.Method != "POST" && r.Method != "PATCH") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			render.Status(w, r, http.StatusBadRequest, "Idempotency-Key must not be longer than 255 characters")
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			render.Status(w, r, http.StatusRequestEntityTooLarge, "Request body is too large")
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		id := storeKey(r, key)
+		fingerprint := fingerprint(r, body)
+		stored, running, claimed := s.claim(id, fingerprint)
+		switch {
+		case claimed:
+		case running != "" && running != fingerprint, stored != nil && stored.fingerprint != fingerprint:
+			render.Status(w, r, http.StatusUnprocessableEntity, "Idempotency-Key has already been used for a different request")
+			return
+		case running != "":
+			render.Status(w, r, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+			return
+		default:
+			replay(w, stored)
+			return
+		}
+
+		recorder := &recorder{ResponseWriter: w, status: http.StatusOK}
+		var answered *response
+		//the key is released even if the handler panics
+		defer func() { s.finish(id, answered) }()
+		next.ServeHTTP(recorder, r)
+		if recorder.status < 500 {
+			answered = &response{
+				fingerprint: fingerprint,
+				status:      recorder.status,
+				header:      recorder.header,
+				body:        recorder.body.Bytes(),
+			}
+		}
+	})
+}
+
+//claim returns the response stored under id or the fingerprint of the request still running for it.
+//If there is neither, id is marked as pending and claim reports true.
+func (s *Store) claim(id, fingerprint string) (stored *response, running string, claimed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if running, ok := s.pending[id]; ok {
+		return nil, running, false
+	}
+	if v, ok := s.entries.Get(id); ok {
+		return v.(*response), "", false
+	}
+	s.pending[id] = fingerprint
+	return nil, "", true
+}
+
+//finish releases a pending key and stores its response. Server errors are not stored, so they are not replayed.
+func (s *Store) finish(id string, answered *response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, id)
+	if answered != nil {
+		s.entries.Set(id, answered)
+	}
+}
+
+//storeKey scopes a key to the access key it was sent with, so clients cannot collide. The address is left out:
+//a client retrying from another address, as mobile clients and those behind NAT pools do, must still get its replay.
+func storeKey(r *http.Request, key string) string {
+	sum := sha256.Sum256([]byte(r.URL.Query().Get("key") + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+//fingerprint identifies the request a key was first used for
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "?" + r.URL.RawQuery + "\x00" + r.Header.Get("Content-Type") + "\x00"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, stored *response) {
+	for name, values := range stored.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(stored.status)
+	w.Write(stored.body)
+}
+
+//recorder passes a response through to the client while keeping a copy of it
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.wroteHeader = true
+	rec.status = status
+	rec.header = rec.ResponseWriter.Header().Clone()
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(p []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
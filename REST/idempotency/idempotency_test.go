@@ -0,0 +1,204 @@
+package idempotency
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//countingHandler creates a resource for every request it gets and says which one
+type countingHandler struct {
+	calls int32
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := atomic.AddInt32(&h.calls, 1)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, `{"Message":"created %d"}`, n)
+}
+
+func post(handler http.Handler, target, key, body string) *httptest.ResponseRecorder {
+	return postFrom(handler, "192.0.2.1:1234", target, key, body)
+}
+
+//postFrom is post sent from the client address addr
+func postFrom(handler http.Handler, addr, target, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", target, strings.NewReader(body))
+	r.RemoteAddr = addr
+	r.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		r.Header.Set(Header, key)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+//TestReplay checks a retry with the same key and body gets the first response again without running the handler
+func TestReplay(t *testing.T) {
+	h := &countingHandler{}
+	handler := NewStore(10, time.Minute).Middleware(h)
+
+	first := post(handler, "/courses/1?key=k", "abc", `{"Code":1}`)
+	second := post(handler, "/courses/1?key=k", "abc", `{"Code":1}`)
+	if h.calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", h.calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("replay %d %q, want %d %q", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get(ReplayedHeader) != "true" || first.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("%s header: first %q, replay %q", ReplayedHeader, first.Header().Get(ReplayedHeader), second.Header().Get(ReplayedHeader))
+	}
+	if second.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("replay lost the Content-Type header")
+	}
+}
+
+//TestKeyScope checks which requests share a stored response
+func TestKeyScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		addr       string
+		target     string
+		key        string
+		body       string
+		wantStatus int
+		wantCalls  int32
+	}{
+		{"same key, different body", "192.0.2.1:1234", "/courses/1?key=k", "abc", `{"Code":2}`, http.StatusUnprocessableEntity, 1},
+		{"same key, different path", "192.0.2.1:1234", "/courses/2?key=k", "abc", `{"Code":1}`, http.StatusUnprocessableEntity, 1},
+		{"same key, other access key", "192.0.2.1:1234", "/courses/1?key=other", "abc", `{"Code":1}`, http.StatusCreated, 2},
+		//a retry from a new address, such as a phone changing networks, is still the same client
+		{"same key, other address", "198.51.100.7:4321", "/courses/1?key=k", "abc", `{"Code":1}`, http.StatusCreated, 1},
+		{"same key, other port", "192.0.2.1:5678", "/courses/1?key=k", "abc", `{"Code":1}`, http.StatusCreated, 1},
+		{"other key", "192.0.2.1:1234", "/courses/1?key=k", "def", `{"Code":1}`, http.StatusCreated, 2},
+		{"no key", "192.0.2.1:1234", "/courses/1?key=k", "", `{"Code":1}`, http.StatusCreated, 2},
+	}
+	for _, tt := range tests {
+		h := &countingHandler{}
+		handler := NewStore(10, time.Minute).Middleware(h)
+		post(handler, "/courses/1?key=k", "abc", `{"Code":1}`)
+		w := postFrom(handler, tt.addr, tt.target, tt.key, tt.body)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+		if h.calls != tt.wantCalls {
+			t.Errorf("%s: handler ran %d times, want %d", tt.name, h.calls, tt.wantCalls)
+		}
+	}
+}
+
+//TestServerErrorNotStored checks a request that failed with a 5xx can be retried for real
+func TestServerErrorNotStored(t *testing.T) {
+	var calls int32
+	handler := NewStore(10, time.Minute).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	if w := post(handler, "/courses/1?key=k", "abc", `{}`); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("first: status %d", w.Code)
+	}
+	if w := post(handler, "/courses/1?key=k", "abc", `{}`); w.Code != http.StatusCreated || calls != 2 {
+		t.Errorf("retry: status %d after %d calls, want 201 after 2", w.Code, calls)
+	}
+}
+
+//blockingHandler holds every request until release is closed
+type blockingHandler struct {
+	countingHandler
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *blockingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.started <- struct{}{}
+	<-h.release
+	h.countingHandler.ServeHTTP(w, r)
+}
+
+//TestConcurrentDuplicates checks requests with the key of one still running are refused instead of run again
+func TestConcurrentDuplicates(t *testing.T) {
+	h := &blockingHandler{started: make(chan struct{}, 10), release: make(chan struct{})}
+	handler := NewStore(10, time.Minute).Middleware(h)
+
+	var first *httptest.ResponseRecorder
+	done := make(chan struct{})
+	go func() {
+		first = post(handler, "/courses/1?key=k", "abc", `{"Code":1}`)
+		close(done)
+	}()
+	<-h.started
+
+	var wg sync.WaitGroup
+	statuses := make([]int, 8)
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses[i] = post(handler, "/courses/1?key=k", "abc", `{"Code":1}`).Code
+		}(i)
+	}
+	wg.Wait()
+	for i, status := range statuses {
+		if status != http.StatusConflict {
+			t.Errorf("duplicate %d: status %d, want 409", i, status)
+		}
+	}
+	if w := post(handler, "/courses/1?key=k", "abc", `{"Code":9}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("different body while running: status %d, want 422", w.Code)
+	}
+
+	close(h.release)
+	<-done
+	if first.Code != http.StatusCreated || h.calls != 1 {
+		t.Fatalf("first: status %d after %d calls", first.Code, h.calls)
+	}
+	if w := post(handler, "/courses/1?key=k", "abc", `{"Code":1}`); w.Code != http.StatusCreated || w.Header().Get(ReplayedHeader) != "true" {
+		t.Errorf("retry after the first finished: status %d, replayed %q", w.Code, w.Header().Get(ReplayedHeader))
+	}
+}
+
+//TestPendingNotEvicted checks a running request keeps its key even when the store fills up meanwhile
+func TestPendingNotEvicted(t *testing.T) {
+	var slowCalls int32
+	started, release := make(chan struct{}), make(chan struct{})
+	counting := &countingHandler{}
+	handler := NewStore(2, time.Minute).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//only the first request for course 1 is slow
+		if r.URL.Path == "/courses/1" && atomic.AddInt32(&slowCalls, 1) == 1 {
+			close(started)
+			<-release
+		}
+		counting.ServeHTTP(w, r)
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		post(handler, "/courses/1?key=k", "running", `{"Code":1}`)
+		close(done)
+	}()
+	<-started
+
+	//other keys complete and fill the store past its size
+	for i := 0; i < 5; i++ {
+		post(handler, "/courses/2?key=k", fmt.Sprint("other", i), `{"Code":2}`)
+	}
+	if w := post(handler, "/courses/1?key=k", "running", `{"Code":1}`); w.Code != http.StatusConflict {
+		t.Errorf("retry while running: status %d, want 409", w.Code)
+	}
+	if n := atomic.LoadInt32(&slowCalls); n != 1 {
+		t.Errorf("the write ran %d times", n)
+	}
+	close(release)
+	<-done
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"goMS1Assignment/REST/database"
+	"goMS1Assignment/REST/render"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//maxImportBytes bounds the size of an uploaded import file
+const maxImportBytes = 10 << 20
+
+//importRow reports what happened to one row of an import
+type importRow struct {
+	Row    int    `json:"Row"` //1-based, not counting a CSV header
+	Code   int    `json:"Code"`
+	Status string `json:"Status"` //valid (dry run), imported, failed or skipped
+	Error  string `json:"Error,omitempty"`
+}
+
+//importReport is the response to an import request
+type importReport struct {
+	Mode     string      `json:"Mode"`
+	DryRun   bool        `json:"DryRun"`
+	Total    int         `json:"Total"`
+	Imported int         `json:"Imported"`
+	Failed   int         `json:"Failed"`
+	Rows     []importRow `json:"Rows"`
+}
+
+//errImportAborted rolls back an atomic import after a row failed to write
+var errImportAborted = errors.New("import aborted")
+
+//importCourses creates courses in bulk from a CSV file or a JSON array.
+//mode=atomic (the default) writes every row in one transaction or none at all; mode=best-effort writes each valid row on its own.
+//dry_run=true validates the rows and reports per-row errors without writing anything.
+func importCourses(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r) {
+		return
+	}
+	query := r.URL.Query()
+	report := importReport{Mode: query.Get("mode"), DryRun: query.Get("dry_run") == "true"}
+	if report.Mode == "" {
+		report.Mode = "atomic"
+	}
+	if report.Mode != "atomic" && report.Mode != "best-effort" {
+		render.Status(w, r, http.StatusBadRequest, "mode must be atomic or best-effort")
+		return
+	}
+
+	courses, rowErrors, err := decodeImport(w, r)
+	if err != nil {
+		log.Error("Error at import function, ", err.Error())
+		//MaxBytesReader says so only in the text of its error, which the decoders pass on
+		if strings.Contains(err.Error(), "request body too large") {
+			render.Status(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Import files are limited to %d MB", maxImportBytes>>20))
+			return
+		}
+		render.Status(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	//every row is validated before anything is written, including against courses that already exist
+	report.Total = len(courses)
+	report.Rows = make([]importRow, len(courses))
+	seen := make(map[int]int)
+	for i := range courses {
+		row := &report.Rows[i]
+		row.Row, row.Code = i+1, courses[i].Code
+		err := rowErrors[i]
+		if err == nil {
+			err = checkImportRow(ctx, &courses[i], seen)
+		}
+		if err != nil {
+			if isDatabaseError(err) {
+				databaseError(w, r, err)
+				return
+			}
+			row.Status, row.Error = "failed", err.Error()
+			report.Failed++
+			continue
+		}
+		seen[courses[i].Code] = row.Row
+		row.Status = "valid"
+	}
+
+	switch {
+	case report.DryRun:
+	case report.Mode == "atomic" && report.Failed > 0:
+		for i := range report.Rows {
+			if report.Rows[i].Status == "valid" {
+				report.Rows[i].Status = "skipped"
+			}
+		}
+	case report.Mode == "atomic":
+		err = repository.InTransaction(ctx, func(tx database.Repository) error {
+			for i := range courses {
+				if err := tx.InsertRecord(ctx, courses[i]); err != nil {
+					//only a course created since the row was checked fails the row; anything else is the database's
+					if !errors.Is(err, database.ErrDuplicate) {
+						return err
+					}
+					report.Rows[i].Status, report.Rows[i].Error = "failed", err.Error()
+					report.Failed++
+					return errImportAborted
+				}
+			}
+			return nil
+		})
+		for i := range report.Rows {
+			switch {
+			case err == nil:
+				report.Rows[i].Status = "imported"
+				report.Imported++
+			case report.Rows[i].Status != "failed":
+				report.Rows[i].Status = "skipped"
+			}
+		}
+		if err != nil && err != errImportAborted {
+			databaseError(w, r, err)
+			return
+		}
+	default:
+		for i := range courses {
+			if report.Rows[i].Status != "valid" {
+				continue
+			}
+			if err := repository.InsertRecord(ctx, courses[i]); err != nil {
+				if !errors.Is(err, database.ErrDuplicate) {
+					databaseError(w, r, err)
+					return
+				}
+				report.Rows[i].Status, report.Rows[i].Error = "failed", err.Error()
+				report.Failed++
+				continue
+			}
+			report.Rows[i].Status = "imported"
+			report.Imported++
+		}
+	}
+
+	status := http.StatusCreated
+	switch {
+	case report.DryRun && report.Failed == 0:
+		status = http.StatusOK
+	case report.Failed > 0 && (report.DryRun || report.Imported == 0):
+		status = http.StatusUnprocessableEntity
+	case report.Failed > 0:
+		status = http.StatusMultiStatus
+	}
+	render.Write(w, r, status, report)
+}
+
+//checkImportRow validates and sanitizes one course and makes sure it neither repeats an earlier row nor exists already
+func checkImportRow(ctx context.Context, course *database.CourseInfo, seen map[int]int) error {
+	if err := validateAndSanitize(course); err != nil {
+		return errors.New("course information in wrong format")
+	}
+	if course.Title == "" || course.Dates == "" || course.Lecturer == "" || course.Description == "" {
+		return errors.New("Title, Dates, Lecturer and Description are required")
+	}
+	if row, ok := seen[course.Code]; ok {
+		return fmt.Errorf("duplicate course ID, already in row %d", row)
+	}
+	_, exist, err := repository.FindRecord(ctx, course.Code)
+	if err != nil {
+		return databaseFailure{err}
+	}
+	if exist {
+		return errors.New("duplicate course ID, course already exists")
+	}
+	return nil
+}
+
+//databaseFailure marks an error that came from the database rather than from the data being imported
+type databaseFailure struct{ error }
+
+func isDatabaseError(err error) bool {
+	_, ok := err.(databaseFailure)
+	return ok
+}
+
+//decodeImport reads the courses from the request body according to its Content-Type.
+//Rows that cannot be read are reported in the map, keyed by their index, instead of failing the whole import.
+func decodeImport(w http.ResponseWriter, r *http.Request) ([]database.CourseInfo, map[int]error, error) {
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		var courses []database.CourseInfo
+		if err := json.NewDecoder(body).Decode(&courses); err != nil {
+			return nil, nil, fmt.Errorf("Please supply courses as a JSON array: %v", err)
+		}
+		return courses, nil, nil
+	case "text/csv":
+		return decodeCSV(body)
+	default:
+		return nil, nil, errors.New("Please supply courses as text/csv or application/json")
+	}
+}
+
+//decodeCSV reads courses from CSV whose header row names the CourseInfo fields, in any order and case
+func decodeCSV(body io.Reader) ([]database.CourseInfo, map[int]error, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1 //short rows are reported per row below
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Please supply a CSV header row: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"code", "title", "dates", "lecturer", "description"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("CSV header is missing the %s column", required)
+		}
+	}
+
+	var courses []database.CourseInfo
+	rowErrors := make(map[int]error)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return courses, rowErrors, nil
+		}
+		if _, ok := err.(*csv.ParseError); !ok && err != nil {
+			return nil, nil, err
+		}
+		if err == nil && len(record) != len(header) {
+			err = fmt.Errorf("expected %d fields, found %d", len(header), len(record))
+		}
+		if err != nil {
+			rowErrors[len(courses)] = err
+			courses = append(courses, database.CourseInfo{})
+			continue
+		}
+		code, err := strconv.Atoi(strings.TrimSpace(record[columns["code"]]))
+		if err != nil {
+			rowErrors[len(courses)] = errors.New("course code needs to be an integer value")
+		}
+		courses = append(courses, database.CourseInfo{
+			Code:        code,
+			Title:       record[columns["title"]],
+			Dates:       record[columns["dates"]],
+			Lecturer:    record[columns["lecturer"]],
+			Description: record[columns["description"]],
+		})
+	}
+}
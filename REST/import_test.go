@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"goMS1Assignment/REST/database"
+)
+
+var (
+	csvHeader  = http.Header{"Content-Type": {"text/csv"}}
+	jsonHeader = http.Header{"Content-Type": {"application/json"}}
+)
+
+//importStatuses returns the status of each row of an import report
+func importStatuses(t *testing.T, body string) []string {
+	var report importReport
+	if err := json.Unmarshal([]byte(body), &report); err != nil {
+		t.Fatalf("report %s: %v", body, err)
+	}
+	statuses := make([]string, len(report.Rows))
+	for i, row := range report.Rows {
+		statuses[i] = row.Status
+	}
+	return statuses
+}
+
+//storedCodes returns the codes of the courses in repo, in order
+func storedCodes(repo *memoryRepository) []int {
+	courses, _ := repo.GetRecords(context.Background())
+	codes := []int{}
+	for code := range courses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+const importRows = `[
+	{"Code": 10, "Title": "Go", "Dates": "Jan", "Lecturer": "Tan", "Description": "basics"},
+	{"Code": 11, "Title": "Rust", "Dates": "Feb", "Lecturer": "Lee", "Description": "ownership"},
+	{"Code": 12, "Title": "Zig", "Dates": "Mar", "Lecturer": "Ng", "Description": "comptime"}
+]`
+
+//TestImportModes checks atomic imports write every row or none, best-effort ones keep the rows that could be written,
+//and dry runs write nothing
+func TestImportModes(t *testing.T) {
+	existing := database.CourseInfo{Code: 1, Title: "Existing", Dates: "Jan", Lecturer: "Tan", Description: "kept"}
+	tests := []struct {
+		name         string
+		query        string
+		fail         map[int]error
+		wantStatus   int
+		wantStatuses []string
+		wantStored   []int
+	}{
+		{"atomic", "", nil, http.StatusCreated, []string{"imported", "imported", "imported"}, []int{1, 10, 11, 12}},
+		//a course created between the check and the write rolls back the rows written before it
+		{"atomic rollback", "&mode=atomic", map[int]error{12: database.ErrDuplicate},
+			http.StatusUnprocessableEntity, []string{"skipped", "skipped", "failed"}, []int{1}},
+		{"best effort", "&mode=best-effort", map[int]error{11: database.ErrDuplicate},
+			http.StatusMultiStatus, []string{"imported", "failed", "imported"}, []int{1, 10, 12}},
+		{"dry run", "&dry_run=true", nil, http.StatusOK, []string{"valid", "valid", "valid"}, []int{1}},
+		{"dry run of a failing write", "&dry_run=true", map[int]error{11: database.ErrDuplicate},
+			http.StatusOK, []string{"valid", "valid", "valid"}, []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMemoryRepository(existing)
+			for code, err := range tt.fail {
+				repo.fail[code] = err
+			}
+			w := serveBody(useRepository(t, repo), "POST", "/api/v1/courses/import?key=k"+tt.query, jsonHeader, importRows)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if got := importStatuses(t, w.Body.String()); !reflect.DeepEqual(got, tt.wantStatuses) {
+				t.Errorf("rows %v, want %v", got, tt.wantStatuses)
+			}
+			if got := storedCodes(repo); !reflect.DeepEqual(got, tt.wantStored) {
+				t.Errorf("stored %v, want %v", got, tt.wantStored)
+			}
+		})
+	}
+}
+
+//TestImportInvalidRows checks rows that fail validation are reported one by one, and an atomic import then writes nothing
+func TestImportInvalidRows(t *testing.T) {
+	body := `[
+		{"Code": 10, "Title": "Go", "Dates": "Jan", "Lecturer": "Tan", "Description": "basics"},
+		{"Code": 1, "Title": "Taken", "Dates": "Jan", "Lecturer": "Tan", "Description": "exists"},
+		{"Code": 10, "Title": "Again", "Dates": "Jan", "Lecturer": "Tan", "Description": "repeated"},
+		{"Code": 13, "Title": "No lecturer", "Dates": "Jan", "Description": "missing"}
+	]`
+	for mode, want := range map[string][]int{"atomic": {1}, "best-effort": {1, 10}} {
+		repo := newMemoryRepository(database.CourseInfo{Code: 1, Title: "Existing"})
+		w := serveBody(useRepository(t, repo), "POST", "/api/v1/courses/import?key=k&mode="+mode, jsonHeader, body)
+		var report importReport
+		json.Unmarshal(w.Body.Bytes(), &report)
+		if report.Failed != 3 || !strings.Contains(report.Rows[1].Error, "already exists") ||
+			!strings.Contains(report.Rows[2].Error, "row 1") || !strings.Contains(report.Rows[3].Error, "required") {
+			t.Errorf("%s: report %+v", mode, report)
+		}
+		if got := storedCodes(repo); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: stored %v, want %v", mode, got, want)
+		}
+	}
+}
+
+//TestImportDatabaseErrors checks a write failing for any reason other than a duplicate answers like every other
+//handler does, instead of being reported as a bad row
+func TestImportDatabaseErrors(t *testing.T) {
+	for _, mode := range []string{"atomic", "best-effort"} {
+		for err, want := range map[error]int{
+			errors.New("connection refused"): http.StatusInternalServerError,
+			context.DeadlineExceeded:         http.StatusGatewayTimeout,
+		} {
+			repo := newMemoryRepository()
+			repo.fail[11] = err
+			w := serveBody(useRepository(t, repo), "POST", "/api/v1/courses/import?key=k&mode="+mode, jsonHeader, importRows)
+			if w.Code != want {
+				t.Errorf("%s import, %v: status %d, want %d", mode, err, w.Code, want)
+			}
+			if mode == "atomic" && len(storedCodes(repo)) != 0 {
+				t.Errorf("atomic import, %v: stored %v", err, storedCodes(repo))
+			}
+		}
+	}
+}
+
+//TestImportCSV checks the header row maps columns by name, in any order and case, and bad rows are reported by number
+func TestImportCSV(t *testing.T) {
+	body := "description, LECTURER, Code, title, dates\n" +
+		"basics, Tan, 10, Go, Jan\n" +
+		"ownership, Lee, eleven, Rust, Feb\n" +
+		"short row, Ng\n" +
+		"comptime, Ng, 12, Zig, Mar\n"
+	repo := newMemoryRepository()
+	w := serveBody(useRepository(t, repo), "POST", "/api/v1/courses/import?key=k&mode=best-effort", csvHeader, body)
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	if got := importStatuses(t, w.Body.String()); !reflect.DeepEqual(got, []string{"imported", "failed", "failed", "imported"}) {
+		t.Errorf("rows %v", got)
+	}
+	course, _, _ := repo.FindRecord(context.Background(), 10)
+	if course.Title != "Go" || course.Lecturer != "Tan" || course.Dates != "Jan" || course.Description != "basics" {
+		t.Errorf("course 10 imported as %+v", course)
+	}
+
+	w = serveBody(useRepository(t, newMemoryRepository()), "POST", "/api/v1/courses/import?key=k", csvHeader, "code,title,dates,lecturer\n1,Go,Jan,Tan\n")
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "description") {
+		t.Errorf("header without a description column: %d %s", w.Code, w.Body.String())
+	}
+}
+
+//TestImportSizeLimit checks a body over maxImportBytes is refused as too large rather than as badly formed
+func TestImportSizeLimit(t *testing.T) {
+	row := "a long description that takes up room, Tan, 10, Go, Jan\n"
+	body := "description,lecturer,code,title,dates\n" + strings.Repeat(row, maxImportBytes/len(row)+1)
+	for header, body := range map[string]string{"text/csv": body, "application/json": `["` + strings.Repeat("x", maxImportBytes) + `"]`} {
+		w := serveBody(useRepository(t, newMemoryRepository()), "POST", "/api/v1/courses/import?key=k", http.Header{"Content-Type": {header}}, body)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s over the limit: status %d, want 413", header, w.Code)
+		}
+	}
+}
@@ -1,33 +1,48 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"os"
 	"regexp"
 	"strconv"
+	"time"
 
+	"goMS1Assignment/REST/cache"
+	"goMS1Assignment/REST/certs"
+	"goMS1Assignment/REST/config"
 	"goMS1Assignment/REST/database"
+	"goMS1Assignment/REST/events"
+	"goMS1Assignment/REST/idempotency"
+	"goMS1Assignment/REST/openapi"
+	"goMS1Assignment/REST/render"
+	"goMS1Assignment/REST/search"
+	"goMS1Assignment/REST/webhook"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
 	"github.com/microcosm-cc/bluemonday"
 	log "github.com/sirupsen/logrus"
 )
 
 var (
-	courses      map[int]database.CourseInfo //used for storing courses on the REST API
-	db           *sql.DB
-	API_key      string
-	codeRegExp   *regexp.Regexp
-	detailRegExp *regexp.Regexp
-	pol          = bluemonday.UGCPolicy() //pol for policy. Used for sanitization of input after input validation.
+	db            *sql.DB
+	repository    database.Repository //course storage used by the handlers, cached when configured
+	courseCache   *cache.Repository   //nil when caching is disabled
+	searcher      search.Searcher
+	courseIndex   *search.Index //in-process index used for fuzzy search and autocomplete
+	API_key       string
+	codeRegExp    *regexp.Regexp
+	detailRegExp  *regexp.Regexp
+	pol           = bluemonday.UGCPolicy() //pol for policy. Used for sanitization of input after input validation.
+	certReloader  *certs.Reloader
+	queryTimeout  time.Duration //upper bound for the database work of a single request
+	exportTimeout time.Duration //upper bound for streaming an export, which outlasts queryTimeout
 )
 
 func init() {
@@ -52,30 +67,17 @@ func init() {
 	detailRegExp = regexp.MustCompile(`^[\w'\-,.][^_!¡?÷?¿/\\+=$%ˆ&*(){}|~<>;:[\]]{0,250}$`) //regexp to check for Title, Dates, Lecturer and Description
 }
 
-func goDotEnvVariable(envVariable string) string {
-	//load .env file
-	err := godotenv.Load(".env")
-
-	if err != nil {
-		log.Fatalf("Error loading .env file")
-
-	}
-	return os.Getenv(envVariable)
-}
-
 func validKey(w http.ResponseWriter, r *http.Request) bool {
 	v := r.URL.Query()
 	if key, ok := v["key"]; ok {
 		if key[0] == API_key {
 			return true
 		} else { //invalid key
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("401 - Invalid key"))
+			render.Status(w, r, http.StatusNotFound, "Invalid key")
 			return false
 		}
 	} else { //key is not provided
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("401 - Please supply access key"))
+		render.Status(w, r, http.StatusNotFound, "Please supply access key")
 		return false
 	}
 }
@@ -84,20 +86,139 @@ func home(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Welcome to the REST API!")
 }
 
-//func allcourses retrieves all courses from database and JSON encodes courses for http response writer
+//health reports that the API is up together with the expiry of the TLS certificate being served
+func health(w http.ResponseWriter, r *http.Request) {
+	notAfter := certReloader.NotAfter()
+	status := map[string]interface{}{
+		"status": "ok",
+		"certificate": map[string]interface{}{
+			"loaded_at":  certReloader.LoadedAt().Format(time.RFC3339),
+			"not_after":  notAfter.Format(time.RFC3339),
+			"expires_in": time.Until(notAfter).Round(time.Second).String(),
+		},
+	}
+	if courseCache != nil {
+		status["cache"] = courseCache.Stats()
+	}
+	render.Write(w, r, http.StatusOK, status)
+}
+
+//queryContext derives the context for a request's database queries so a hung database cannot hold the handler forever
+func queryContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), queryTimeout)
+}
+
+//databaseError reports a failed query to the client, distinguishing timeouts from other failures
+func databaseError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Error("Database query timed out. ", err.Error())
+		render.Status(w, r, http.StatusGatewayTimeout, "Database did not respond in time")
+		return
+	}
+	log.Error("Database query failed. ", err.Error())
+	render.Status(w, r, http.StatusInternalServerError, "Database error")
+}
+
+//func allcourses retrieves all courses from database and JSON encodes courses for http response writer.
+//The optional lecturer and title query parameters keep only courses whose fields contain them.
 func allcourses(w http.ResponseWriter, r *http.Request) {
 	if !validKey(w, r) {
 		return
 	}
-	fmt.Fprintf(w, "List of all courses")
+	ctx, cancel := queryContext(r)
+	defer cancel()
 
-	courses = database.GetRecords(db)
-	fmt.Println(courses)
-	for _, v := range courses {
+	//unfiltered listings come from the cache, filtered ones straight from the database
+	var courses map[int]database.CourseInfo
+	var err error
+	if filter := filterParams(r); filter.IsEmpty() {
+		courses, err = repository.GetRecords(ctx)
+	} else {
+		courses = make(map[int]database.CourseInfo)
+		err = repository.EachRecord(ctx, filter, func(course database.CourseInfo) error {
+			courses[course.Code] = course
+			return nil
+		})
+	}
+	if err != nil {
+		databaseError(w, r, err)
+		return
+	}
+	for k, v := range courses {
 		validateAndSanitize(&v)
+		courses[k] = v
+	}
+	//no Last-Modified: the newest UpdatedAt does not move when a course is deleted, so If-Modified-Since
+	//would answer 304 with a list that still holds it. The ETag covers the body and does change.
+	writeConditional(w, r, time.Time{}, render.Courses(courses))
+
+}
+
+//limitParam reads the optional limit query parameter, which must lie between 1 and 100
+func limitParam(r *http.Request, fallback int) (int, error) {
+	l := r.URL.Query().Get("limit")
+	if l == "" {
+		return fallback, nil
 	}
-	json.NewEncoder(w).Encode(courses)
+	n, err := strconv.Atoi(l)
+	if err != nil || n < 1 || n > 100 {
+		return 0, errors.New("limit must be an integer between 1 and 100")
+	}
+	return n, nil
+}
+
+//suggestCourses autocompletes course titles and lecturer names from what has been typed so far
+func suggestCourses(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r) {
+		return
+	}
+	prefix := r.URL.Query().Get("prefix")
+	if len(search.Terms(prefix)) == 0 {
+		render.Status(w, r, http.StatusBadRequest, "Please supply the text typed so far with prefix")
+		return
+	}
+	limit, err := limitParam(r, 10)
+	if err != nil {
+		render.Status(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	render.Write(w, r, http.StatusOK, courseIndex.Suggest(prefix, limit))
+}
+
+//searchCourses answers free-text queries over course titles, lecturers and descriptions, best match first.
+//With fuzzy=true, titles and lecturers are matched with typo tolerance instead.
+func searchCourses(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r) {
+		return
+	}
+	q := r.URL.Query().Get("q")
+	if len(search.Terms(q)) == 0 {
+		render.Status(w, r, http.StatusBadRequest, "Please supply a search query with q")
+		return
+	}
+	limit, err := limitParam(r, 20)
+	if err != nil {
+		render.Status(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := queryContext(r)
+	defer cancel()
 
+	var results []search.Result
+	if r.URL.Query().Get("fuzzy") == "true" {
+		results, err = courseIndex.FuzzySearch(ctx, q, limit)
+	} else {
+		results, err = searcher.Search(ctx, q, limit)
+	}
+	if err != nil {
+		databaseError(w, r, err)
+		return
+	}
+	for i := range results {
+		validateAndSanitize(&results[i].Course)
+	}
+	render.Write(w, r, http.StatusOK, results)
 }
 
 //course handles the incoming console http request (Get, Post, Put, Delete) and handles the requests accordingly
@@ -113,133 +234,142 @@ func course(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		fmt.Println("Course ID is not an integer.")
 		log.Error("Error at course function, received non-integer course ID")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("400 - Course data in wrong format, needs to be integer value."))
+		render.Status(w, r, http.StatusBadRequest, "Course data in wrong format, needs to be integer value.")
 		return
 	}
 
-	course := database.GetRecord(db, code)
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	//one lookup answers both whether the course exists and what it holds
+	course, exist, err := repository.FindRecord(ctx, code)
+	if err != nil {
+		databaseError(w, r, err)
+		return
+	}
 	validateAndSanitize(&course)
 
 	if r.Method == "GET" {
-		exist := database.RowExists(db, code)
-
 		if exist {
-			json.NewEncoder(w).Encode(course)
+			writeConditional(w, r, course.UpdatedAt, course)
 		} else {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("404 - No course found"))
+			render.Status(w, r, http.StatusNotFound, "No course found")
 		}
 	}
 
 	if r.Method == "DELETE" {
-
-		exist := database.RowExists(db, code)
-
 		if exist {
-			database.DeleteRecord(db, code)
-			w.WriteHeader(http.StatusAccepted)
-			w.Write([]byte("202 - Course deleted: " + params["courseid"]))
+			if err := repository.DeleteRecord(ctx, code); err != nil {
+				databaseError(w, r, err)
+				return
+			}
+			render.Status(w, r, http.StatusAccepted, "Course deleted: "+params["courseid"])
 		} else {
-			w.WriteHeader(http.StatusNotFound)
-			w.Write([]byte("404 - No course found"))
+			render.Status(w, r, http.StatusNotFound, "No course found")
 		}
 	}
 
-	//only methods PUT and POST are with content-type "application/json"
-	if r.Header.Get("Content-type") == "application/json" {
-		//POST is for creating new course
-		if r.Method == "POST" {
-			// read the string sent to the service
-			var newCourse database.CourseInfo
-			reqBody, err := ioutil.ReadAll(r.Body)
-
-			if err == nil {
-				//convert JSON to object
-				json.Unmarshal(reqBody, &newCourse)
-				validateAndSanitize(&newCourse)
-
-				// check if course exists; add only if course does not exist
-				if _, ok := courses[code]; !ok {
-					if newCourse.Title == "" || newCourse.Dates == "" || newCourse.Lecturer == "" || newCourse.Description == "" {
-						w.WriteHeader(http.StatusUnprocessableEntity)
-						w.Write([]byte("422 - Please supply course" + "information " + "in JSON format"))
-						return
-					}
-					database.InsertRecord(db, newCourse.Code, newCourse.Title, newCourse.Dates, newCourse.Lecturer, newCourse.Description)
-					w.WriteHeader(http.StatusCreated)
-					w.Write([]byte("201 - Course added: " + params["courseid"]))
-				} else {
-					w.WriteHeader(http.StatusConflict)
-					w.Write([]byte("409 - Duplicate course ID"))
-					log.Error("Error at course function, 409 - Duplicate course ID")
-				}
-			} else {
-				w.WriteHeader(http.StatusUnprocessableEntity)
-				w.Write([]byte("422 - Please supply course information " + "in JSON format"))
-				log.Error("Error at course function, 422 - Invalid course information.")
+	if r.Method != "POST" && r.Method != "PUT" {
+		return
+	}
+	//PUT and POST carry the course in any format the API reads, named by Content-Type
+	var newCourse database.CourseInfo
+	if err := render.Decode(r, &newCourse); err != nil {
+		if err == render.ErrUnsupportedMediaType {
+			render.Status(w, r, http.StatusUnsupportedMediaType, "Please supply course information in one of "+render.Supported())
+			return
+		}
+		render.Status(w, r, http.StatusUnprocessableEntity, "Please supply course information in one of "+render.Supported())
+		log.Error("Error at course function, 422 - Invalid course information. ", err.Error())
+		return
+	}
+	validateAndSanitize(&newCourse)
+
+	//POST is for creating new course
+	if r.Method == "POST" {
+		// check if course exists; add only if course does not exist
+		if !exist {
+			if newCourse.Title == "" || newCourse.Dates == "" || newCourse.Lecturer == "" || newCourse.Description == "" {
+				render.Status(w, r, http.StatusUnprocessableEntity, "Please supply course information")
+				return
+			}
+			if err := repository.InsertRecord(ctx, newCourse); err != nil {
+				databaseError(w, r, err)
+				return
 			}
+			render.Status(w, r, http.StatusCreated, "Course added: "+params["courseid"])
+		} else {
+			render.Status(w, r, http.StatusConflict, "Duplicate course ID")
+			log.Error("Error at course function, 409 - Duplicate course ID")
 		}
+	}
 
-		//---PUT is for creating or updating existing course ---
-		if r.Method == "PUT" {
-			var newCourse database.CourseInfo
-			reqBody, err := ioutil.ReadAll(r.Body)
-
-			if err == nil {
-				//convert JSON to object
-				json.Unmarshal(reqBody, &newCourse)
-				validateAndSanitize(&newCourse)
-
-				// check if course exists; add only if course does not exist
-				if exist := database.RowExists(db, newCourse.Code); !exist {
-					if newCourse.Title == "" || newCourse.Dates == "" || newCourse.Lecturer == "" || newCourse.Description == "" {
-						w.WriteHeader(http.StatusUnprocessableEntity)
-						w.Write([]byte("422 - Please supply course" + "information " + "in JSON format"))
-						log.Error("Error at course function, 422 - Invalid course information.")
-						return
-					}
-					database.InsertRecord(db, newCourse.Code, newCourse.Title, newCourse.Dates, newCourse.Lecturer, newCourse.Description)
-					w.WriteHeader(http.StatusCreated)
-					w.Write([]byte("201 - Course added: " + params["courseid"]))
-				} else {
-					// update course
-					if newCourse.Title == "" {
-						newCourse.Title = course.Title
-					}
-					if newCourse.Dates == "" {
-						newCourse.Dates = course.Dates
-					}
-					if newCourse.Lecturer == "" {
-						newCourse.Lecturer = course.Lecturer
-					}
-					if newCourse.Description == "" {
-						newCourse.Description = course.Description
-					}
-					database.EditRecord(db, newCourse.Code, newCourse.Title, newCourse.Dates, newCourse.Lecturer, newCourse.Description)
-					w.WriteHeader(http.StatusAccepted)
-					w.Write([]byte("202 - Course updated: " + params["courseid"]))
-				}
-			} else {
-				w.WriteHeader(http.StatusUnprocessableEntity)
-				w.Write([]byte("422 - Please supply course information " + "in JSON format"))
+	//---PUT is for creating or updating existing course ---
+	if r.Method == "PUT" {
+		// check if course exists; add only if course does not exist
+		course, exist, err := repository.FindRecord(ctx, newCourse.Code)
+		if err != nil {
+			databaseError(w, r, err)
+			return
+		}
+		if !exist {
+			if newCourse.Title == "" || newCourse.Dates == "" || newCourse.Lecturer == "" || newCourse.Description == "" {
+				render.Status(w, r, http.StatusUnprocessableEntity, "Please supply course information")
 				log.Error("Error at course function, 422 - Invalid course information.")
+				return
+			}
+			if err := repository.InsertRecord(ctx, newCourse); err != nil {
+				databaseError(w, r, err)
+				return
+			}
+			render.Status(w, r, http.StatusCreated, "Course added: "+params["courseid"])
+		} else {
+			// update course
+			if newCourse.Title == "" {
+				newCourse.Title = course.Title
+			}
+			if newCourse.Dates == "" {
+				newCourse.Dates = course.Dates
+			}
+			if newCourse.Lecturer == "" {
+				newCourse.Lecturer = course.Lecturer
+			}
+			if newCourse.Description == "" {
+				newCourse.Description = course.Description
 			}
+			if err := repository.EditRecord(ctx, newCourse); err != nil {
+				databaseError(w, r, err)
+				return
+			}
+			render.Status(w, r, http.StatusAccepted, "Course updated: "+params["courseid"])
 		}
 	}
 }
 
 func main() {
 
-	//the following variables are hidden using an environment variable so as not to expose security related data.
-	API_key = goDotEnvVariable("API_KEY")
-	db_password := goDotEnvVariable("PASSWORD")
-	db_port := goDotEnvVariable("PORT")
-	db_name := goDotEnvVariable("DB_NAME")
-
-	var dataSourceName string = "root:" + db_password + "@tcp" + db_port + "/" + db_name
-	var err error
-	db, err = sql.Open("mysql", dataSourceName)
+	//settings are layered from defaults, an optional config file, environment variables (including .env) and flags
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	if err != nil {
+		log.Fatal("Error loading configuration: ", err)
+	}
+	if err = cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	cfg.Dump(os.Stdout)
+	API_key = cfg.APIKey
+
+	queryTimeout = cfg.DB.QueryTimeout
+	exportTimeout = cfg.DB.ExportTimeout
+	cacheControl = cfg.Server.CacheControl
+
+	//the database container may still be starting, so Connect keeps pinging until the connect timeout
+	pool := database.PoolSettings{
+		MaxOpenConns:    cfg.DB.MaxOpenConns,
+		MaxIdleConns:    cfg.DB.MaxIdleConns,
+		ConnMaxLifetime: cfg.DB.ConnMaxLifetime,
+	}
+	db, err = database.Connect(cfg.DB.DSN(), pool, cfg.DB.ConnectTimeout)
 
 	if err != nil {
 		log.Panic("Panic occured opening data base", err.Error())
@@ -251,18 +381,95 @@ func main() {
 		fmt.Println("Database closed")
 	}()
 
-	//instatiate courses
-	courses = make(map[int]database.CourseInfo)
+	//reads go through an in-memory LRU cache unless it is disabled with a size of 0
+	sqlRepository := database.NewSQLRepository(db)
+	//every course write also records an event in the outbox, which feeds the webhooks
+	sqlRepository.EnableOutbox()
+	repository = sqlRepository
+
+	//the in-process index is kept up to date by the writes below; it serves fuzzy search and autocomplete,
+	//and plain searches too unless they use the MySQL FULLTEXT index
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.DB.ConnectTimeout)
+	indexed, err := search.NewIndexedRepository(ctx, repository)
+	cancel()
+	if err != nil {
+		log.Panic("Panic occured building the search index", err.Error())
+	}
+	repository = indexed
+	courseIndex = indexed.Index
+	if cfg.Search.Engine == "index" {
+		searcher = indexed
+	} else {
+		searcher = search.NewFullText(sqlRepository)
+	}
+
+	if cfg.Cache.Size > 0 {
+		courseCache = cache.NewRepository(repository, cache.NewLRU(cfg.Cache.Size, cfg.Cache.TTL))
+		repository = courseCache
+	}
+
+	//writes are published to live event streams once they have committed
+	courseEvents = events.NewBroker(cfg.Events.LogSize)
+	repository = events.NewRepository(repository, courseEvents)
+
+	//the dispatcher relays outbox events to subscribed webhooks and retries failed deliveries
+	webhooks = webhook.NewSQLStore(db)
+	webhookDispatcher = webhook.NewDispatcher(webhooks, webhook.NewClient(cfg.Webhook.Timeout))
+	webhookDispatcher.MaxAttempts = cfg.Webhook.MaxAttempts
+	webhookDispatcher.Backoff = cfg.Webhook.Backoff
+	webhookDispatcher.Interval = cfg.Webhook.Poll
+	webhookDispatcher.Retention = cfg.Webhook.Retention
+	go webhookDispatcher.Run(make(chan struct{}))
 
 	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/", home)
-	router.HandleFunc("/api/v1/courses", allcourses)
-	router.HandleFunc("/api/v1/courses/{courseid}", course).Methods("GET", "PUT", "POST", "DELETE")
-	//register the methods with handler functions
+	registerRoutes(router)
+	//retried POST and PATCH requests with an Idempotency-Key get the first response instead of running again
+	router.Use(idempotency.NewStore(cfg.Idempotency.Size, cfg.Idempotency.TTL).Middleware)
 
-	fmt.Println("Listening at port 5000")
-	//log.Fatal(http.ListenAndServe(":5000", router))
-	log.Fatal(http.ListenAndServeTLS(":5000", "./server.crt", "./server.key", router))
+	//certificates are served through GetCertificate so they can be rotated without a restart
+	certReloader, err = certs.NewReloader(cfg.Server.CertFile, cfg.Server.KeyFile)
+	if err != nil {
+		log.Fatal("Error loading TLS certificate: ", err)
+	}
+	go certReloader.Watch(cfg.Server.CertReload, make(chan struct{}))
+
+	tlsConfig := &tls.Config{GetCertificate: certReloader.GetCertificate}
+	if cfg.Server.GRPCAddr != "" {
+		go serveGRPC(cfg.Server.GRPCAddr, tlsConfig)
+	}
+
+	server := &http.Server{
+		Addr:      cfg.Server.Addr,
+		Handler:   router,
+		TLSConfig: tlsConfig,
+	}
+
+	fmt.Println("Listening at " + cfg.Server.Addr)
+	log.Fatal(server.ListenAndServeTLS("", ""))
+}
+
+//registerRoutes registers the methods with handler functions. Every route is described in openapi/openapi.json,
+//which TestRoutesMatchOpenAPISpec keeps in step with this list.
+func registerRoutes(router *mux.Router) {
+	router.HandleFunc("/api/v1/", home).Methods("GET")
+	router.HandleFunc("/api/v1/health", health).Methods("GET")
+	router.HandleFunc("/api/v1/courses", allcourses).Methods("GET")
+	router.HandleFunc("/api/v1/courses/search", searchCourses).Methods("GET")
+	router.HandleFunc("/api/v1/courses/suggest", suggestCourses).Methods("GET")
+	router.HandleFunc("/api/v1/courses/import", importCourses).Methods("POST")
+	router.HandleFunc("/api/v1/courses/export", exportCourses).Methods("GET")
+	router.HandleFunc("/api/v1/courses/batch", batchCourses).Methods("POST")
+	router.HandleFunc("/api/v1/courses/events", courseEventStream).Methods("GET")
+	router.HandleFunc("/api/v1/courses/socket", courseSocket).Methods("GET")
+	router.HandleFunc("/api/v1/courses/{courseid}", course).Methods("GET", "PUT", "POST", "DELETE")
+	router.HandleFunc("/api/v1/openapi.json", openapi.ServeSpec).Methods("GET")
+	router.PathPrefix("/api/v1/docs/").Handler(openapi.Docs("/api/v1/docs/")).Methods("GET")
+	router.HandleFunc("/api/v1/graphql", graphqlHandler).Methods("GET", "POST")
+	router.HandleFunc("/api/v1/webhooks", listWebhooks).Methods("GET")
+	router.HandleFunc("/api/v1/webhooks", registerWebhook).Methods("POST")
+	router.HandleFunc("/api/v1/webhooks/{webhookid}", webhookByID).Methods("GET", "DELETE")
+	router.HandleFunc("/api/v1/webhooks/{webhookid}/deliveries", webhookDeliveries).Methods("GET")
+	router.HandleFunc("/api/v1/webhooks/{webhookid}/deliveries/{deliveryid}/redeliver", redeliverWebhook).Methods("POST")
 }
 
 func validateAndSanitize(course *database.CourseInfo) error {
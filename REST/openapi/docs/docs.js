@@ -0,0 +1,172 @@
+// Renders openapi.json as a list of operations, each with a form to try it against this server.
+(function () {
+  "use strict";
+
+  var spec;
+  var keyInput = document.getElementById("key");
+  keyInput.value = sessionStorage.getItem("apiKey") || "";
+  keyInput.addEventListener("change", function () { sessionStorage.setItem("apiKey", keyInput.value); });
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (name) { node.setAttribute(name, attrs[name]); });
+    (children || []).forEach(function (child) {
+      node.appendChild(typeof child === "string" ? document.createTextNode(child) : child);
+    });
+    return node;
+  }
+
+  function resolve(obj) {
+    var seen = 0;
+    while (obj && obj.$ref && seen++ < 10) {
+      obj = obj.$ref.replace(/^#\//, "").split("/").reduce(function (o, part) { return o[part]; }, spec);
+    }
+    return obj;
+  }
+
+  // example builds a sample value for a schema, following references
+  function example(schema, depth) {
+    schema = resolve(schema);
+    if (!schema || depth > 5) return null;
+    if (schema.allOf) {
+      return schema.allOf.reduce(function (acc, part) { return Object.assign(acc, example(part, depth + 1)); }, {});
+    }
+    if (schema.enum) return schema.enum[0];
+    switch (schema.type) {
+      case "object":
+        var obj = {};
+        Object.keys(schema.properties || {}).forEach(function (name) { obj[name] = example(schema.properties[name], depth + 1); });
+        return obj;
+      case "array": return [example(schema.items, depth + 1)];
+      case "integer": case "number": return 0;
+      case "boolean": return false;
+      default: return "";
+    }
+  }
+
+  function parametersOf(pathItem, op) {
+    return (pathItem.parameters || []).concat(op.parameters || []).map(resolve);
+  }
+
+  function parameterTable(params) {
+    var rows = params.map(function (p) {
+      return el("tr", {}, [el("td", {}, [p.name]), el("td", {}, [p.in]), el("td", {}, [p.required ? "yes" : ""]),
+        el("td", {}, [(p.schema && (p.schema.enum ? p.schema.enum.join(" | ") : p.schema.type)) || ""]),
+        el("td", {}, [p.description || ""])]);
+    });
+    return el("table", {}, [el("tr", {}, ["Name", "In", "Required", "Type", "Description"].map(function (h) { return el("th", {}, [h]); }))].concat(rows));
+  }
+
+  function responseTable(responses) {
+    var rows = Object.keys(responses).map(function (code) {
+      var r = resolve(responses[code]);
+      return el("tr", {}, [el("td", {}, [code]), el("td", {}, [r.description || ""]), el("td", {}, [Object.keys(r.content || {}).join(", ")])]);
+    });
+    return el("table", {}, [el("tr", {}, ["Status", "Description", "Media types"].map(function (h) { return el("th", {}, [h]); }))].concat(rows));
+  }
+
+  function tryForm(path, method, pathItem, op) {
+    var params = parametersOf(pathItem, op);
+    var inputs = {};
+    var form = el("form", {}, [el("h4", {}, ["Try it"])]);
+    params.forEach(function (p) {
+      inputs[p.in + ":" + p.name] = el("input", {placeholder: p.required ? "required" : ""});
+      form.appendChild(el("div", {}, [el("label", {}, [p.name + " (" + p.in + ") ", inputs[p.in + ":" + p.name]])]));
+    });
+    var body, contentType;
+    if (op.requestBody) {
+      var content = resolve(op.requestBody).content;
+      contentType = el("select", {}, Object.keys(content).map(function (type) { return el("option", {}, [type]); }));
+      var first = content[Object.keys(content)[0]];
+      body = el("textarea", {}, [JSON.stringify(example(first.schema, 0), null, 2)]);
+      form.appendChild(el("div", {}, [el("label", {}, ["Content-Type ", contentType])]));
+      form.appendChild(body);
+    }
+    var result = el("pre", {"class": "result", hidden: ""});
+    form.appendChild(el("button", {type: "submit"}, ["Send"]));
+    form.appendChild(result);
+
+    form.addEventListener("submit", function (event) {
+      event.preventDefault();
+      var url = path, query = [], headers = {};
+      params.forEach(function (p) {
+        var value = inputs[p.in + ":" + p.name].value;
+        if (value === "") return;
+        if (p.in === "path") url = url.replace("{" + p.name + "}", encodeURIComponent(value));
+        if (p.in === "query") query.push(encodeURIComponent(p.name) + "=" + encodeURIComponent(value));
+        if (p.in === "header") headers[p.name] = value;
+      });
+      var secured = (op.security || spec.security || []).length > 0;
+      if (secured && keyInput.value) query.push("key=" + encodeURIComponent(keyInput.value));
+      if (query.length) url += "?" + query.join("&");
+      var init = {method: method.toUpperCase(), headers: headers};
+      if (body) {
+        headers["Content-Type"] = contentType.value;
+        init.body = body.value;
+      }
+      result.hidden = false;
+      result.textContent = init.method + " " + url + "\n\n…";
+      fetch(url, init).then(function (response) {
+        return response.text().then(function (text) {
+          var lines = [response.status + " " + response.statusText];
+          response.headers.forEach(function (value, name) { lines.push(name + ": " + value); });
+          result.textContent = init.method + " " + url + "\n\n" + lines.join("\n") + "\n\n" + text;
+        });
+      }).catch(function (err) {
+        result.textContent = init.method + " " + url + "\n\n" + err;
+      });
+    });
+    return form;
+  }
+
+  function operation(path, method, pathItem, op) {
+    var body = el("div", {"class": "body"}, []);
+    if (op.description) body.appendChild(el("p", {}, [op.description]));
+    var params = parametersOf(pathItem, op);
+    if (params.length) body.appendChild(el("h4", {}, ["Parameters"]));
+    if (params.length) body.appendChild(parameterTable(params));
+    if (op.requestBody) {
+      var requestBody = resolve(op.requestBody);
+      body.appendChild(el("h4", {}, ["Request body: " + Object.keys(requestBody.content).join(", ")]));
+      if (requestBody.description) body.appendChild(el("p", {}, [requestBody.description]));
+    }
+    body.appendChild(el("h4", {}, ["Responses"]));
+    body.appendChild(responseTable(op.responses));
+    body.appendChild(tryForm(path, method, pathItem, op));
+    return el("details", {id: op.operationId || ""}, [
+      el("summary", {}, [el("span", {"class": "method " + method}, [method]), el("span", {"class": "path"}, [path]), " " + (op.summary || "")]),
+      body
+    ]);
+  }
+
+  function render() {
+    document.getElementById("title").textContent = spec.info.title + " " + spec.info.version;
+    document.getElementById("description").textContent = spec.info.description || "";
+    var main = document.getElementById("operations");
+    var nav = document.getElementById("nav");
+    main.textContent = "";
+    (spec.tags || []).forEach(function (tag) {
+      nav.appendChild(el("a", {href: "#tag-" + tag.name}, [tag.name]));
+      main.appendChild(el("h2", {id: "tag-" + tag.name}, [tag.name + " ", el("small", {}, [tag.description || ""])]));
+      Object.keys(spec.paths).forEach(function (path) {
+        var pathItem = spec.paths[path];
+        ["get", "post", "put", "patch", "delete"].forEach(function (method) {
+          var op = pathItem[method];
+          if (op && (op.tags || []).indexOf(tag.name) >= 0) main.appendChild(operation(path, method, pathItem, op));
+        });
+      });
+    });
+    main.appendChild(el("h2", {}, ["Schemas"]));
+    Object.keys(spec.components.schemas).forEach(function (name) {
+      main.appendChild(el("details", {}, [el("summary", {}, [name]),
+        el("pre", {}, [JSON.stringify(spec.components.schemas[name], null, 2)])]));
+    });
+  }
+
+  fetch("../openapi.json").then(function (response) { return response.json(); }).then(function (doc) {
+    spec = doc;
+    render();
+  }).catch(function (err) {
+    document.getElementById("operations").textContent = "Could not load the API description: " + err;
+  });
+})();
@@ -0,0 +1,31 @@
+//Package openapi embeds the OpenAPI 3 description of the REST API and a documentation page that renders it,
+//so the docs are served by the binary itself without fetching anything from elsewhere.
+package openapi
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//Spec is the OpenAPI document, kept in step with the routes by TestRoutesMatchOpenAPISpec
+//go:embed openapi.json
+var Spec []byte
+
+//go:embed docs
+var docs embed.FS
+
+//ServeSpec writes the OpenAPI document. It needs no access key so tools can load it.
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(Spec)
+}
+
+//Docs serves the documentation page and its assets from the path prefix they are routed under
+func Docs(prefix string) http.Handler {
+	assets, err := fs.Sub(docs, "docs")
+	if err != nil {
+		panic(err) //the directory is embedded, so this cannot happen
+	}
+	return http.StripPrefix(prefix, http.FileServer(http.FS(assets)))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"goMS1Assignment/REST/openapi"
+
+	"github.com/gorilla/mux"
+)
+
+//TestRoutesMatchOpenAPISpec fails when a route is added, removed or changes methods without openapi.json following,
+//or when a documented path parameter is missing from its path item
+func TestRoutesMatchOpenAPISpec(t *testing.T) {
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Spec, &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Fatalf("openapi.json declares version %q, want 3.x", spec.OpenAPI)
+	}
+
+	methods := map[string]bool{"get": true, "put": true, "post": true, "delete": true, "patch": true, "head": true, "options": true}
+	documented := make(map[string]bool)
+	for path, item := range spec.Paths {
+		for key := range item {
+			if methods[key] {
+				documented[strings.ToUpper(key)+" "+path] = true
+			}
+		}
+		checkPathParameters(t, path, item["parameters"])
+	}
+
+	router := mux.NewRouter()
+	registerRoutes(router)
+	routed := make(map[string]bool)
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		routeMethods, err := route.GetMethods()
+		if err != nil {
+			t.Errorf("%s accepts any method; restrict it with Methods so it can be documented", path)
+			return nil
+		}
+		for _, method := range routeMethods {
+			routed[method+" "+path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for route := range routed {
+		if !documented[route] {
+			t.Errorf("%s is routed but not described in openapi.json", route)
+		}
+	}
+	for route := range documented {
+		if !routed[route] {
+			t.Errorf("%s is described in openapi.json but not routed", route)
+		}
+	}
+}
+
+var pathParam = regexp.MustCompile(`{([^}]+)}`)
+
+func checkPathParameters(t *testing.T, path string, raw json.RawMessage) {
+	var params []struct {
+		Name string `json:"name"`
+		In   string `json:"in"`
+	}
+	if raw != nil {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			t.Errorf("%s: parameters: %v", path, err)
+			return
+		}
+	}
+	declared := make(map[string]bool)
+	for _, p := range params {
+		if p.In == "path" {
+			declared[p.Name] = true
+		}
+	}
+	for _, match := range pathParam.FindAllStringSubmatch(path, -1) {
+		if !declared[match[1]] {
+			t.Errorf("%s: path parameter %s is not declared", path, match[1])
+		}
+		delete(declared, match[1])
+	}
+	for name := range declared {
+		t.Errorf("%s: declares path parameter %s that is not in the path", path, name)
+	}
+}
@@ -0,0 +1,75 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"goMS1Assignment/REST/database"
+)
+
+var courseHeader = []string{"Code", "Title", "Dates", "Lecturer", "Description"}
+
+func courseRecord(course database.CourseInfo) []string {
+	return []string{strconv.Itoa(course.Code), course.Title, course.Dates, course.Lecturer, course.Description}
+}
+
+//encodeCSV writes a header row followed by one row per course, or the status and text of a message
+func encodeCSV(w io.Writer, v interface{}) error {
+	writer := csv.NewWriter(w)
+	switch v := v.(type) {
+	case database.CourseInfo:
+		writer.Write(courseHeader)
+		writer.Write(courseRecord(v))
+	case Courses:
+		writer.Write(courseHeader)
+		for _, course := range v.sorted() {
+			writer.Write(courseRecord(course))
+		}
+	case Message:
+		writer.Write([]string{"Status", "Message"})
+		writer.Write([]string{strconv.Itoa(v.Status), v.Text})
+	default:
+		return fmt.Errorf("cannot write %T as CSV", v)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+//decodeCSV reads a single course from a header row naming the CourseInfo fields and one data row.
+//Columns may come in any order and fields that are left out stay empty.
+func decodeCSV(data []byte, v interface{}) error {
+	course, ok := v.(*database.CourseInfo)
+	if !ok {
+		return fmt.Errorf("cannot read %T from CSV", v)
+	}
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) != 2 {
+		return errors.New("CSV needs a header row and exactly one course row")
+	}
+	for i, name := range records[0] {
+		value := records[1][i]
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "code":
+			if course.Code, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return errors.New("course code needs to be an integer value")
+			}
+		case "title":
+			course.Title = value
+		case "dates":
+			course.Dates = value
+		case "lecturer":
+			course.Lecturer = value
+		case "description":
+			course.Description = value
+		}
+	}
+	return nil
+}
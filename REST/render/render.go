@@ -0,0 +1,263 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"goMS1Assignment/REST/database"
+
+	"gopkg.in/yaml.v2"
+)
+
+//ErrUnsupportedMediaType is returned by Decode for a request body in a format the API does not read
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+//format is one representation the API can produce and read
+type format struct {
+	mediaType string
+	aliases   []string
+	encode    func(w io.Writer, v interface{}) error
+	decode    func(data []byte, v interface{}) error
+	supports  func(v interface{}) bool
+}
+
+//formats are listed in order of preference, used when the client accepts several equally
+var formats = []format{
+	{
+		mediaType: "application/json",
+		encode:    func(w io.Writer, v interface{}) error { return json.NewEncoder(w).Encode(v) },
+		decode:    json.Unmarshal,
+		supports:  func(interface{}) bool { return true },
+	},
+	{
+		mediaType: "application/xml",
+		aliases:   []string{"text/xml"},
+		encode:    encodeXML,
+		decode:    xml.Unmarshal,
+		supports:  isCourseData,
+	},
+	{
+		mediaType: "application/yaml",
+		aliases:   []string{"application/x-yaml", "text/yaml", "text/x-yaml"},
+		encode:    func(w io.Writer, v interface{}) error { return yaml.NewEncoder(w).Encode(v) },
+		decode:    yaml.Unmarshal,
+		supports:  func(interface{}) bool { return true },
+	},
+	{
+		mediaType: "text/csv",
+		encode:    encodeCSV,
+		decode:    decodeCSV,
+		supports:  isCourseData,
+	},
+}
+
+//Supported lists the media types the API produces, for error messages
+func Supported() string {
+	types := make([]string, len(formats))
+	for i, f := range formats {
+		types[i] = f.mediaType
+	}
+	return strings.Join(types, ", ")
+}
+
+func (f format) matches(mediaType string) bool {
+	if f.mediaType == mediaType {
+		return true
+	}
+	for _, alias := range f.aliases {
+		if alias == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+//mediaRange is one entry of an Accept header
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func (m mediaRange) matches(mediaType string) bool {
+	parts := strings.SplitN(mediaType, "/", 2)
+	return (m.typ == "*" || m.typ == parts[0]) && (m.subtype == "*" || m.subtype == parts[1])
+}
+
+//parseAccept returns the media ranges of an Accept header, most preferred first. A missing header accepts anything.
+func parseAccept(accept string) []mediaRange {
+	if strings.TrimSpace(accept) == "" {
+		return []mediaRange{{"*", "*", 1}}
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		typeParts := strings.SplitN(mediaType, "/", 2)
+		if len(typeParts) != 2 {
+			continue
+		}
+		ranges = append(ranges, mediaRange{typeParts[0], typeParts[1], q})
+	}
+	//higher quality first; on equal quality, specific types beat wildcards
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return strings.Count(ranges[i].typ+ranges[i].subtype, "*") < strings.Count(ranges[j].typ+ranges[j].subtype, "*")
+	})
+	return ranges
+}
+
+//negotiate picks the format for v that the client prefers, or false if it accepts none of them
+func negotiate(r *http.Request, v interface{}) (format, bool) {
+	for _, accepted := range parseAccept(r.Header.Get("Accept")) {
+		for _, f := range formats {
+			if !f.supports(v) {
+				continue
+			}
+			if accepted.matches(f.mediaType) {
+				return f, true
+			}
+			for _, alias := range f.aliases {
+				if accepted.matches(alias) {
+					return f, true
+				}
+			}
+		}
+	}
+	return format{}, false
+}
+
+//Encode renders v in the format the client asked for and returns the body and its Content-Type.
+//ok is false when the client accepts none of the formats v can be rendered in.
+func Encode(r *http.Request, v interface{}) (body []byte, contentType string, ok bool) {
+	f, ok := negotiate(r, v)
+	if !ok {
+		return nil, "", false
+	}
+	var buf bytes.Buffer
+	if err := f.encode(&buf, v); err != nil {
+		return nil, "", false
+	}
+	return buf.Bytes(), f.mediaType + "; charset=utf-8", true
+}
+
+//Write sends v with status in the format the client asked for, or 406 Not Acceptable
+func Write(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	body, contentType, ok := Encode(r, v)
+	if !ok {
+		NotAcceptable(w)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+//NotAcceptable answers a request whose Accept header rules out every format. It is sent as JSON, as nothing else was accepted.
+func NotAcceptable(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(http.StatusNotAcceptable)
+	json.NewEncoder(w).Encode(Message{Status: http.StatusNotAcceptable, Text: "Please accept one of " + Supported()})
+}
+
+//Status sends a status or error message in the format the client asked for
+func Status(w http.ResponseWriter, r *http.Request, status int, text string) {
+	Write(w, r, status, Message{Status: status, Text: text})
+}
+
+//Decode reads the request body into v according to its Content-Type
+func Decode(r *http.Request, v interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return ErrUnsupportedMediaType
+	}
+	for _, f := range formats {
+		if f.matches(mediaType) && f.supports(v) {
+			data, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				return err
+			}
+			return f.decode(data, v)
+		}
+	}
+	return ErrUnsupportedMediaType
+}
+
+//Message is a status or error reply
+type Message struct {
+	XMLName xml.Name `json:"-" yaml:"-" xml:"Message"`
+	Status  int      `json:"Status" yaml:"Status" xml:"Status"`
+	Text    string   `json:"Message" yaml:"Message" xml:"Text"`
+}
+
+//Courses is a collection of courses. JSON and YAML keep the map keyed by course code;
+//XML and CSV list the courses in code order.
+type Courses map[int]database.CourseInfo
+
+//sorted returns the courses in code order
+func (c Courses) sorted() []database.CourseInfo {
+	list := make([]database.CourseInfo, 0, len(c))
+	for _, course := range c {
+		list = append(list, course)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+//MarshalXML writes the collection as <Courses><Course>...</Course></Courses>
+func (c Courses) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name.Local = "Courses"
+	return e.EncodeElement(struct {
+		Course []database.CourseInfo
+	}{c.sorted()}, start)
+}
+
+//isCourseData reports whether v is one of the course types the XML and CSV formats know how to handle
+func isCourseData(v interface{}) bool {
+	switch v.(type) {
+	case database.CourseInfo, *database.CourseInfo, Courses, Message:
+		return true
+	}
+	return false
+}
+
+func encodeXML(w io.Writer, v interface{}) error {
+	if course, ok := v.(database.CourseInfo); ok {
+		//a single course gets the same element name it has inside a collection
+		v = struct {
+			XMLName xml.Name `xml:"Course"`
+			database.CourseInfo
+		}{CourseInfo: course}
+	}
+	io.WriteString(w, xml.Header)
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
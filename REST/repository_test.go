@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goMS1Assignment/REST/database"
+
+	"github.com/gorilla/mux"
+)
+
+//memoryRepository is a database.Repository kept in a map, for handler tests
+type memoryRepository struct {
+	mu      sync.Mutex
+	courses map[int]database.CourseInfo
+	fail    map[int]error //writes of these course codes fail with the error
+}
+
+func newMemoryRepository(courses ...database.CourseInfo) *memoryRepository {
+	m := &memoryRepository{courses: make(map[int]database.CourseInfo), fail: make(map[int]error)}
+	for _, course := range courses {
+		m.courses[course.Code] = course
+	}
+	return m
+}
+
+func (m *memoryRepository) GetRecords(ctx context.Context) (map[int]database.CourseInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	courses := make(map[int]database.CourseInfo, len(m.courses))
+	for code, course := range m.courses {
+		courses[code] = course
+	}
+	return courses, nil
+}
+
+func (m *memoryRepository) FindRecord(ctx context.Context, code int) (database.CourseInfo, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	course, ok := m.courses[code]
+	return course, ok, nil
+}
+
+func (m *memoryRepository) EachRecord(ctx context.Context, filter database.Filter, fn func(database.CourseInfo) error) error {
+	courses, _ := m.GetRecords(ctx)
+	for _, course := range courses {
+		if !strings.Contains(course.Lecturer, filter.Lecturer) || !strings.Contains(course.Title, filter.Title) {
+			continue
+		}
+		if err := fn(course); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryRepository) InsertRecord(ctx context.Context, course database.CourseInfo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fail[course.Code]; err != nil {
+		return err
+	}
+	course.UpdatedAt = time.Now()
+	m.courses[course.Code] = course
+	return nil
+}
+
+func (m *memoryRepository) EditRecord(ctx context.Context, course database.CourseInfo) error {
+	return m.InsertRecord(ctx, course)
+}
+
+func (m *memoryRepository) DeleteRecord(ctx context.Context, code int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fail[code]; err != nil {
+		return err
+	}
+	delete(m.courses, code)
+	return nil
+}
+
+//InTransaction puts the courses back as they were if fn fails
+func (m *memoryRepository) InTransaction(ctx context.Context, fn func(tx database.Repository) error) error {
+	before, _ := m.GetRecords(ctx)
+	err := fn(m)
+	if err != nil {
+		m.mu.Lock()
+		m.courses = before
+		m.mu.Unlock()
+	}
+	return err
+}
+
+//useRepository points the handlers at repo and returns a router serving every route
+func useRepository(t *testing.T, repo database.Repository) *mux.Router {
+	API_key = "k"
+	queryTimeout = time.Second
+	exportTimeout = time.Minute
+	old := repository
+	repository = repo
+	t.Cleanup(func() { repository = old })
+	router := mux.NewRouter()
+	registerRoutes(router)
+	return router
+}
+
+//serve sends one request to router and returns the recorded response
+func serve(router http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	return serveBody(router, method, target, header, "")
+}
+
+//serveBody is serve for a request with a body
+func serveBody(router http.Handler, method, target string, header http.Header, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
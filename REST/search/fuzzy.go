@@ -0,0 +1,175 @@
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+//Suggestion is an autocomplete candidate for a search box
+type Suggestion struct {
+	Text  string `json:"Text"`
+	Field string `json:"Field"`          //Title or Lecturer
+	Code  int    `json:"Code,omitempty"` //set for titles; a lecturer may teach several courses
+}
+
+//Suggest completes what has been typed so far into course titles and lecturer names.
+//Every typed word must match a word of the suggestion in order; the last one may be unfinished.
+//Small typos are tolerated, but suggestions needing fewer corrections come first.
+func (idx *Index) Suggest(prefix string, limit int) []Suggestion {
+	typed := Terms(prefix)
+	if len(typed) == 0 {
+		return []Suggestion{}
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	type candidate struct {
+		Suggestion
+		cost    int
+		atStart bool
+	}
+	var candidates []candidate
+	lecturers := make(map[string]bool)
+	for code, course := range idx.courses {
+		if cost, atStart, ok := completes(typed, Terms(course.Title)); ok {
+			candidates = append(candidates, candidate{Suggestion{course.Title, "Title", code}, cost, atStart})
+		}
+		key := strings.ToLower(course.Lecturer)
+		if lecturers[key] {
+			continue
+		}
+		if cost, atStart, ok := completes(typed, Terms(course.Lecturer)); ok {
+			lecturers[key] = true
+			candidates = append(candidates, candidate{Suggestion{course.Lecturer, "Lecturer", 0}, cost, atStart})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.cost != b.cost {
+			return a.cost < b.cost
+		}
+		if a.atStart != b.atStart {
+			return a.atStart
+		}
+		if a.Field != b.Field {
+			return a.Field == "Title"
+		}
+		if len(a.Text) != len(b.Text) {
+			return len(a.Text) < len(b.Text)
+		}
+		return a.Text < b.Text
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	suggestions := make([]Suggestion, len(candidates))
+	for i, c := range candidates {
+		suggestions[i] = c.Suggestion
+	}
+	return suggestions
+}
+
+//completes reports whether the typed words match words in order, the total number of corrections needed
+//and whether the first typed word matched the first word
+func completes(typed, words []string) (int, bool, bool) {
+	cost, next, atStart := 0, 0, false
+	for i, term := range typed {
+		last := i == len(typed)-1
+		found := false
+		for ; next < len(words); next++ {
+			var d int
+			if last {
+				d = prefixDistance(term, words[next])
+			} else {
+				d = levenshtein(term, words[next])
+			}
+			if d <= allowedEdits(term) {
+				if i == 0 && next == 0 {
+					atStart = true
+				}
+				cost += d
+				found = true
+				next++
+				break
+			}
+		}
+		if !found {
+			return 0, false, false
+		}
+	}
+	return cost, atStart, true
+}
+
+//allowedEdits is how many typos a word of this length may contain and still match
+func allowedEdits(term string) int {
+	switch n := len([]rune(term)); {
+	case n <= 3:
+		return 0
+	case n <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+//trigrams splits a word padded with $ into overlapping three letter sequences, e.g. "go" gives "$go" and "go$"
+func trigrams(term string) []string {
+	runes := []rune("$" + term + "$")
+	grams := make([]string, 0, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		grams = append(grams, string(runes[i:i+3]))
+	}
+	return grams
+}
+
+//prefixDistance is the edit distance between term and the closest beginning of word
+func prefixDistance(term, word string) int {
+	if strings.HasPrefix(word, term) {
+		return 0
+	}
+	t, w := []rune(term), []rune(word)
+	best := len(t)
+	for n := len(t) - 1; n <= len(t)+1; n++ {
+		if n < 0 || n > len(w) {
+			continue
+		}
+		if d := levenshtein(term, string(w[:n])); d < best {
+			best = d
+		}
+	}
+	return best
+}
+
+//levenshtein counts the insertions, deletions and substitutions needed to turn a into b
+func levenshtein(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	previous := make([]int, len(t)+1)
+	current := make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(t)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,198 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"goMS1Assignment/REST/database"
+)
+
+//TestLevenshtein checks the edit distance, counting letters rather than bytes
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"go", "", 2},
+		{"", "go", 2},
+		{"advanced", "advanced", 0},
+		{"advnced", "advanced", 1},
+		{"advanced", "advacned", 2},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1},
+		{"naïve", "naive", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := levenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+//TestAllowedEdits checks short words must match exactly and longer ones may hold up to two typos
+func TestAllowedEdits(t *testing.T) {
+	tests := []struct {
+		term string
+		want int
+	}{
+		{"go", 0},
+		{"web", 0},
+		{"rust", 1},
+		{"cloud", 1},
+		{"golang", 2},
+		{"microservices", 2},
+		{"café", 1},
+	}
+	for _, tt := range tests {
+		if got := allowedEdits(tt.term); got != tt.want {
+			t.Errorf("allowedEdits(%q) = %d, want %d", tt.term, got, tt.want)
+		}
+	}
+}
+
+//TestPrefixDistance checks an unfinished word is compared with the beginning of the word it may complete
+func TestPrefixDistance(t *testing.T) {
+	tests := []struct {
+		term, word string
+		want       int
+	}{
+		{"micro", "microservices", 0},
+		{"mirco", "microservices", 2},
+		{"micr0", "microservices", 1},
+		{"mcro", "microservices", 1},
+		{"microo", "microservices", 1},
+		{"gol", "go", 1},
+		{"rust", "go", 4},
+	}
+	for _, tt := range tests {
+		if got := prefixDistance(tt.term, tt.word); got != tt.want {
+			t.Errorf("prefixDistance(%q, %q) = %d, want %d", tt.term, tt.word, got, tt.want)
+		}
+	}
+}
+
+func fuzzyIndex() *Index {
+	return testIndex(
+		database.CourseInfo{Code: 1, Title: "Advanced Go", Lecturer: "Tan", Description: "concurrency"},
+		database.CourseInfo{Code: 2, Title: "Advance Python", Lecturer: "Lee", Description: "advanced typing"},
+		database.CourseInfo{Code: 3, Title: "Kubernetes", Lecturer: "Ng", Description: "clusters"},
+		database.CourseInfo{Code: 4, Title: "Web", Lecturer: "Andersen", Description: "go servers"},
+	)
+}
+
+//TestFuzzySearch checks typos within the allowed distance still find titles and lecturers,
+//with the closer match first
+func TestFuzzySearch(t *testing.T) {
+	ctx := context.Background()
+	idx := fuzzyIndex()
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"advanced", []int{1, 2}},
+		//one edit from "advanced", two from "advance"
+		{"advnced", []int{1, 2}},
+		{"kubernets", []int{3}},
+		{"kuberentes", []int{3}},
+		{"andresen", []int{4}},
+		//three typos are too many
+		{"kbrnts", []int{}},
+		//short words must be exact
+		{"ge", []int{}},
+		{"go", []int{1}},
+		//the description is not searched fuzzily
+		{"concurency", []int{}},
+	}
+	for _, tt := range tests {
+		results, err := idx.FuzzySearch(ctx, tt.query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := codes(results); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FuzzySearch(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	//the snippets mark the words matched, not the misspelt query
+	results, _ := idx.FuzzySearch(ctx, "kubernets", 10)
+	if len(results) != 1 || results[0].Snippets["Title"] != "<mark>Kubernetes</mark>" {
+		t.Errorf("snippets of a fuzzy match: %+v", results)
+	}
+	if results, _ := idx.FuzzySearch(ctx, "advanced", 1); len(results) != 1 {
+		t.Errorf("FuzzySearch with limit 1 returned %d results", len(results))
+	}
+}
+
+//TestSuggest checks completions of what has been typed, in order of corrections needed, then titles whose
+//first word matched, then titles before lecturers
+func TestSuggest(t *testing.T) {
+	idx := fuzzyIndex()
+	idx.Add(database.CourseInfo{Code: 5, Title: "Go Basics", Lecturer: "Tan"})
+	tests := []struct {
+		typed string
+		want  []Suggestion
+	}{
+		//ties go to the shorter text
+		{"adv", []Suggestion{{"Advanced Go", "Title", 1}, {"Advance Python", "Title", 2}}},
+		{"advanced g", []Suggestion{{"Advanced Go", "Title", 1}}},
+		{"kubr", []Suggestion{{"Kubernetes", "Title", 3}}},
+		{"go", []Suggestion{{"Go Basics", "Title", 5}, {"Advanced Go", "Title", 1}}},
+		//a lecturer teaching several courses is suggested once
+		{"ta", []Suggestion{{"Tan", "Lecturer", 0}}},
+		//words must match in order
+		{"go advanced", []Suggestion{}},
+		{"", []Suggestion{}},
+	}
+	for _, tt := range tests {
+		got := idx.Suggest(tt.typed, 10)
+		if len(got)+len(tt.want) > 0 && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Suggest(%q) = %+v, want %+v", tt.typed, got, tt.want)
+		}
+	}
+	if got := idx.Suggest("a", 1); len(got) != 1 {
+		t.Errorf("Suggest with limit 1 returned %d suggestions", len(got))
+	}
+}
+
+//TestFuzzyShortWords checks a six letter word with two typos still matches, although it shares no trigram
+//with the word it was meant to be
+func TestFuzzyShortWords(t *testing.T) {
+	shared := false
+	for _, gram := range trigrams("kitlen") {
+		for _, other := range trigrams("kotlin") {
+			shared = shared || gram == other
+		}
+	}
+	if shared || levenshtein("kitlen", "kotlin") != 2 {
+		t.Fatal("kitlen no longer tests a match without a shared trigram")
+	}
+
+	idx := testIndex(
+		database.CourseInfo{Code: 1, Title: "Kotlin", Lecturer: "Brandt"},
+		database.CourseInfo{Code: 2, Title: "Swift", Lecturer: "Oliver"},
+	)
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"kitlen", []int{1}},
+		{"brendy", []int{1}},
+		{"olovir", []int{2}},
+		//three typos are still too many
+		{"catlen", []int{}},
+	}
+	for _, tt := range tests {
+		results, err := idx.FuzzySearch(context.Background(), tt.query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := codes(results); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FuzzySearch(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
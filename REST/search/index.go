@@ -0,0 +1,213 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"goMS1Assignment/REST/database"
+)
+
+//fieldWeights makes a match in the title count more than one in the lecturer, and both more than the description
+var fieldWeights = []struct {
+	name   string
+	weight float64
+	fuzzy  bool //whether the field is also indexed for typo-tolerant matching
+	value  func(database.CourseInfo) string
+}{
+	{"Title", 3, true, func(c database.CourseInfo) string { return c.Title }},
+	{"Lecturer", 2, true, func(c database.CourseInfo) string { return c.Lecturer }},
+	{"Description", 1, false, func(c database.CourseInfo) string { return c.Description }},
+}
+
+//Index is an in-process inverted index over course titles, lecturers and descriptions.
+//It serves searches for repositories without a MySQL FULLTEXT index.
+type Index struct {
+	mu       sync.RWMutex
+	courses  map[int]database.CourseInfo
+	postings map[string]map[int]float64 //term -> course code -> weighted term frequency
+
+	//names and trigrams cover only the title and lecturer, for fuzzy matching
+	names    map[string]map[int]float64 //term -> course code -> weighted term frequency
+	trigrams map[string]map[string]bool //trigram -> terms in names containing it
+}
+
+//NewIndex returns an empty index
+func NewIndex() *Index {
+	return &Index{
+		courses:  make(map[int]database.CourseInfo),
+		postings: make(map[string]map[int]float64),
+		names:    make(map[string]map[int]float64),
+		trigrams: make(map[string]map[string]bool),
+	}
+}
+
+//Add indexes course, replacing any earlier version with the same code
+func (idx *Index) Add(course database.CourseInfo) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(course.Code)
+	idx.courses[course.Code] = course
+	for _, field := range fieldWeights {
+		for _, term := range Terms(field.value(course)) {
+			if idx.postings[term] == nil {
+				idx.postings[term] = make(map[int]float64)
+			}
+			idx.postings[term][course.Code] += field.weight
+			if field.fuzzy {
+				idx.addName(term, course.Code, field.weight)
+			}
+		}
+	}
+}
+
+func (idx *Index) addName(term string, code int, weight float64) {
+	if idx.names[term] == nil {
+		idx.names[term] = make(map[int]float64)
+		for _, gram := range trigrams(term) {
+			if idx.trigrams[gram] == nil {
+				idx.trigrams[gram] = make(map[string]bool)
+			}
+			idx.trigrams[gram][term] = true
+		}
+	}
+	idx.names[term][code] += weight
+}
+
+func (idx *Index) removeName(term string, code int) {
+	delete(idx.names[term], code)
+	if len(idx.names[term]) > 0 {
+		return
+	}
+	delete(idx.names, term)
+	for _, gram := range trigrams(term) {
+		delete(idx.trigrams[gram], term)
+		if len(idx.trigrams[gram]) == 0 {
+			delete(idx.trigrams, gram)
+		}
+	}
+}
+
+//Remove drops the course with code from the index
+func (idx *Index) Remove(code int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(code)
+}
+
+func (idx *Index) remove(code int) {
+	course, ok := idx.courses[code]
+	if !ok {
+		return
+	}
+	delete(idx.courses, code)
+	for _, field := range fieldWeights {
+		for _, term := range Terms(field.value(course)) {
+			delete(idx.postings[term], code)
+			if len(idx.postings[term]) == 0 {
+				delete(idx.postings, term)
+			}
+			if field.fuzzy {
+				idx.removeName(term, code)
+			}
+		}
+	}
+}
+
+//Search ranks courses by how often and where the query terms occur, weighting rarer terms higher.
+//Each query term also matches indexed words it is a prefix of, at half the weight of an exact match.
+func (idx *Index) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	terms := Terms(query)
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	scores := make(map[int]float64)
+	total := float64(len(idx.courses))
+	for _, queryTerm := range terms {
+		for term, courses := range idx.postings {
+			if !strings.HasPrefix(term, queryTerm) {
+				continue
+			}
+			weight := math.Log(1 + total/float64(len(courses)))
+			if term != queryTerm {
+				weight /= 2
+			}
+			for code, tf := range courses {
+				scores[code] += tf * weight
+			}
+		}
+	}
+	return idx.rank(scores, terms, limit), nil
+}
+
+//FuzzySearch matches query terms against title and lecturer words within a small edit distance,
+//so "Advnced" still finds "Advanced". Closer matches and rarer words score higher.
+func (idx *Index) FuzzySearch(ctx context.Context, query string, limit int) ([]Result, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	scores := make(map[int]float64)
+	var matched []string
+	total := float64(len(idx.courses))
+	for _, queryTerm := range Terms(query) {
+		for term, distance := range idx.similarTerms(queryTerm) {
+			matched = append(matched, term)
+			courses := idx.names[term]
+			weight := math.Log(1+total/float64(len(courses))) * (1 - float64(distance)/float64(len(queryTerm)+1))
+			for code, tf := range courses {
+				scores[code] += tf * weight
+			}
+		}
+	}
+	return idx.rank(scores, matched, limit), nil
+}
+
+//similarTerms returns the title and lecturer words within the allowed edit distance of term, with their distance.
+//Candidates are the words sharing at least one trigram with term, so the whole vocabulary is not scanned for
+//long words. Each edit changes up to three trigrams, so a word shorter than 3*edits+1 letters can share none with
+//a close match; those are compared with every word instead.
+func (idx *Index) similarTerms(term string) map[string]int {
+	maxDistance := allowedEdits(term)
+	candidates := make(map[string]bool)
+	if len([]rune(term)) < 3*maxDistance+1 {
+		for candidate := range idx.names {
+			candidates[candidate] = true
+		}
+	} else {
+		for _, gram := range trigrams(term) {
+			for candidate := range idx.trigrams[gram] {
+				candidates[candidate] = true
+			}
+		}
+	}
+	similar := make(map[string]int)
+	for candidate := range candidates {
+		if d := levenshtein(term, candidate); d <= maxDistance {
+			similar[candidate] = d
+		}
+	}
+	return similar
+}
+
+//rank orders the scored courses best first and cuts the list to limit
+func (idx *Index) rank(scores map[int]float64, terms []string, limit int) []Result {
+	results := make([]Result, 0, len(scores))
+	for code, score := range scores {
+		course := idx.courses[code]
+		results = append(results, Result{Course: course, Score: score, Snippets: Snippets(course, terms)})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Course.Code < results[j].Course.Code
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
@@ -0,0 +1,171 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"goMS1Assignment/REST/database"
+)
+
+func testIndex(courses ...database.CourseInfo) *Index {
+	idx := NewIndex()
+	for _, course := range courses {
+		idx.Add(course)
+	}
+	return idx
+}
+
+func codes(results []Result) []int {
+	codes := make([]int, len(results))
+	for i, result := range results {
+		codes[i] = result.Course.Code
+	}
+	return codes
+}
+
+//TestTerms checks text is split into lower-case words on anything that is not a letter or digit
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Go Microservices", []string{"go", "microservices"}},
+		{"  C++/CLI, part 2!", []string{"c", "cli", "part", "2"}},
+		{"+go -rust \"web*\"", []string{"go", "rust", "web"}},
+		{"Élan vital", []string{"élan", "vital"}},
+		{"", nil},
+		{"--- ...", nil},
+	}
+	for _, tt := range tests {
+		if got := Terms(tt.text); len(got)+len(tt.want) > 0 && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Terms(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+//TestSearchRanking checks where and how often a term occurs, and how rare it is, decide the order
+func TestSearchRanking(t *testing.T) {
+	ctx := context.Background()
+	idx := testIndex(
+		database.CourseInfo{Code: 1, Title: "Databases", Lecturer: "Tan", Description: "go drivers"},
+		database.CourseInfo{Code: 2, Title: "Go", Lecturer: "Lee", Description: "the language"},
+		database.CourseInfo{Code: 3, Title: "Web", Lecturer: "Go Ah", Description: "servers"},
+		database.CourseInfo{Code: 4, Title: "Go Go", Lecturer: "Ng", Description: "twice"},
+		database.CourseInfo{Code: 5, Title: "Sockets", Lecturer: "Lim", Description: "networks"},
+	)
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		//twice in the title, then the title, the lecturer and the description
+		{"go", []int{4, 2, 3, 1}},
+		{"GO!", []int{4, 2, 3, 1}},
+		//"sockets" occurs once in the whole index, so it outweighs the common "go"
+		{"go sockets", []int{5, 4, 2, 3, 1}},
+		{"data", []int{1}},
+		{"rust", []int{}},
+		{"", []int{}},
+	}
+	for _, tt := range tests {
+		results, err := idx.Search(ctx, tt.query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := codes(results); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+		for i := 1; i < len(results); i++ {
+			if results[i].Score > results[i-1].Score {
+				t.Errorf("Search(%q): scores not in descending order: %v", tt.query, results)
+			}
+		}
+	}
+
+	if results, _ := idx.Search(ctx, "go", 2); !reflect.DeepEqual(codes(results), []int{4, 2}) {
+		t.Errorf("Search with limit 2 = %v, want the best 2", codes(results))
+	}
+	//a word the query term only begins counts half
+	prefixed := testIndex(database.CourseInfo{Code: 1, Title: "Gophers"}, database.CourseInfo{Code: 2, Title: "Go"})
+	results, _ := prefixed.Search(ctx, "go", 10)
+	if !reflect.DeepEqual(codes(results), []int{2, 1}) || results[0].Score != 2*results[1].Score {
+		t.Errorf("prefix match: %+v, want course 1 at half the score of course 2", results)
+	}
+	//equal scores are ordered by code
+	tied := testIndex(database.CourseInfo{Code: 9, Title: "Go"}, database.CourseInfo{Code: 7, Title: "Go"})
+	if results, _ := tied.Search(ctx, "go", 10); !reflect.DeepEqual(codes(results), []int{7, 9}) {
+		t.Errorf("tied results = %v, want [7 9]", codes(results))
+	}
+}
+
+//TestIndexUpdates checks replacing and removing a course leaves no trace of the old version in the index
+func TestIndexUpdates(t *testing.T) {
+	ctx := context.Background()
+	idx := testIndex(database.CourseInfo{Code: 1, Title: "Rust", Lecturer: "Tan"}, database.CourseInfo{Code: 2, Title: "Go", Lecturer: "Lee"})
+
+	idx.Add(database.CourseInfo{Code: 1, Title: "Zig", Lecturer: "Tan"})
+	if results, _ := idx.Search(ctx, "rust", 10); len(results) != 0 {
+		t.Errorf("old title still found after the course was replaced: %v", codes(results))
+	}
+	if results, _ := idx.Search(ctx, "zig", 10); !reflect.DeepEqual(codes(results), []int{1}) {
+		t.Errorf("new title: %v, want [1]", codes(results))
+	}
+
+	idx.Remove(1)
+	idx.Remove(1)
+	for _, query := range []string{"zig", "tan"} {
+		if results, _ := idx.Search(ctx, query, 10); len(results) != 0 {
+			t.Errorf("%q still found after the course was removed: %v", query, codes(results))
+		}
+	}
+	if len(idx.postings) != 2 || len(idx.names) != 2 {
+		t.Errorf("index keeps %d postings and %d names, want only the 2 words of course 2", len(idx.postings), len(idx.names))
+	}
+	for gram, terms := range idx.trigrams {
+		for term := range terms {
+			if term != "go" && term != "lee" {
+				t.Errorf("trigram %q still points at removed word %q", gram, term)
+			}
+		}
+	}
+}
+
+//TestSnippets checks matched words are marked in every field they occur in, whatever their case
+func TestSnippets(t *testing.T) {
+	course := database.CourseInfo{Title: "Go Microservices", Lecturer: "Gopher Tan", Description: "Build services, in Go."}
+	got := Snippets(course, []string{"go", "serv"})
+	want := map[string]string{
+		"Title":       "<mark>Go</mark> Microservices",
+		"Lecturer":    "<mark>Gopher</mark> Tan",
+		"Description": "Build <mark>services</mark>, in <mark>Go</mark>.",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Snippets = %q, want %q", got, want)
+	}
+	if got := Snippets(course, []string{"rust"}); len(got) != 0 {
+		t.Errorf("Snippets without a match = %q, want none", got)
+	}
+}
+
+//TestSnippetWindow checks a long field is cut to a window around its first match
+func TestSnippetWindow(t *testing.T) {
+	filler := strings.Repeat("lorem ", 40)
+	course := database.CourseInfo{Description: filler + "kubernetes " + filler}
+	snippet := Snippets(course, []string{"kube"})["Description"]
+	if !strings.Contains(snippet, "<mark>kubernetes</mark>") {
+		t.Fatalf("snippet %q lost the match", snippet)
+	}
+	if !strings.HasPrefix(snippet, "…") || !strings.HasSuffix(snippet, "…") {
+		t.Errorf("snippet %q is not marked as cut on both sides", snippet)
+	}
+	if plain := strings.NewReplacer("<mark>", "", "</mark>", "", "…", "").Replace(snippet); len([]rune(plain)) > snippetLength {
+		t.Errorf("snippet holds %d characters, want at most %d", len([]rune(plain)), snippetLength)
+	}
+
+	//a match near the start is kept with the beginning of the field
+	course.Description = "kubernetes " + filler
+	if snippet := Snippets(course, []string{"kube"})["Description"]; !strings.HasPrefix(snippet, "<mark>kubernetes</mark>") {
+		t.Errorf("snippet %q does not start at the beginning of the field", snippet)
+	}
+}
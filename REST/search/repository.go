@@ -0,0 +1,73 @@
+package search
+
+import (
+	"context"
+
+	"goMS1Assignment/REST/database"
+)
+
+//IndexedRepository keeps an Index in step with the writes made through it
+type IndexedRepository struct {
+	database.Repository
+	*Index
+}
+
+//NewIndexedRepository loads every course from next into a new index and wraps next so later writes update it
+func NewIndexedRepository(ctx context.Context, next database.Repository) (*IndexedRepository, error) {
+	courses, err := next.GetRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idx := NewIndex()
+	for _, course := range courses {
+		idx.Add(course)
+	}
+	return &IndexedRepository{Repository: next, Index: idx}, nil
+}
+
+//InsertRecord creates the course and indexes it
+func (r *IndexedRepository) InsertRecord(ctx context.Context, course database.CourseInfo) error {
+	if err := r.Repository.InsertRecord(ctx, course); err != nil {
+		return err
+	}
+	r.Add(course)
+	return nil
+}
+
+//EditRecord updates the course and re-indexes it
+func (r *IndexedRepository) EditRecord(ctx context.Context, course database.CourseInfo) error {
+	if err := r.Repository.EditRecord(ctx, course); err != nil {
+		return err
+	}
+	r.Add(course)
+	return nil
+}
+
+//DeleteRecord deletes the course and drops it from the index
+func (r *IndexedRepository) DeleteRecord(ctx context.Context, code int) error {
+	if err := r.Repository.DeleteRecord(ctx, code); err != nil {
+		return err
+	}
+	r.Remove(code)
+	return nil
+}
+
+//InTransaction runs fn in a transaction of the wrapped repository and updates the index once it has committed
+func (r *IndexedRepository) InTransaction(ctx context.Context, fn func(tx database.Repository) error) error {
+	recorder := &database.Recorder{}
+	err := r.Repository.InTransaction(ctx, func(tx database.Repository) error {
+		recorder.Repository = tx
+		return fn(recorder)
+	})
+	if err != nil {
+		return err
+	}
+	for _, change := range recorder.Changes {
+		if change.Deleted {
+			r.Remove(change.Course.Code)
+		} else {
+			r.Add(change.Course)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package search
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"goMS1Assignment/REST/database"
+)
+
+//Result is one matching course with its relevance score and highlighted snippets of the fields that matched
+type Result struct {
+	Course   database.CourseInfo `json:"Course"`
+	Score    float64             `json:"Score"`
+	Snippets map[string]string   `json:"Snippets"`
+}
+
+//Searcher finds courses matching a free-text query, best match first
+type Searcher interface {
+	Search(ctx context.Context, query string, limit int) ([]Result, error)
+}
+
+//Terms splits text into lower-case words, dropping punctuation
+func Terms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+//FullText searches with the MySQL FULLTEXT index on Title, Description and Lecturer
+type FullText struct {
+	repo *database.SQLRepository
+}
+
+//NewFullText returns a searcher that runs its queries in MySQL
+func NewFullText(repo *database.SQLRepository) *FullText {
+	return &FullText{repo: repo}
+}
+
+//Search matches every query term as a prefix, e.g. "micro" finds "microservices"
+func (f *FullText) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return []Result{}, nil
+	}
+	//Terms strips the boolean mode operators, so the only operator left is the prefix wildcard added here
+	boolean := make([]string, len(terms))
+	for i, term := range terms {
+		boolean[i] = term + "*"
+	}
+
+	hits, err := f.repo.SearchRecords(ctx, strings.Join(boolean, " "), limit)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, len(hits))
+	for i, hit := range hits {
+		results[i] = Result{Course: hit.CourseInfo, Score: hit.Score, Snippets: Snippets(hit.CourseInfo, terms)}
+	}
+	return results, nil
+}
+
+//snippetLength is the longest snippet returned for a field before it is cut down around the first match
+const snippetLength = 120
+
+//Snippets returns the fields of course that contain a word starting with one of terms, with those words marked
+func Snippets(course database.CourseInfo, terms []string) map[string]string {
+	snippets := make(map[string]string)
+	fields := map[string]string{"Title": course.Title, "Lecturer": course.Lecturer, "Description": course.Description}
+	for name, text := range fields {
+		if snippet, ok := highlight(text, terms); ok {
+			snippets[name] = snippet
+		}
+	}
+	return snippets
+}
+
+//highlight wraps every word of text that starts with one of terms in <mark> tags
+func highlight(text string, terms []string) (string, bool) {
+	var out strings.Builder
+	first := -1
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			out.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		if matchesAny(strings.ToLower(word), terms) {
+			if first < 0 {
+				first = i
+			}
+			out.WriteString("<mark>" + word + "</mark>")
+		} else {
+			out.WriteString(word)
+		}
+		i = j
+	}
+	if first < 0 {
+		return "", false
+	}
+	if len(runes) <= snippetLength {
+		return out.String(), true
+	}
+	//long fields are cut to a window starting a little before the first match
+	start := first - snippetLength/4
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+	snippet, _ := highlight(string(runes[start:end]), terms)
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet, true
+}
+
+func matchesAny(word string, terms []string) bool {
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
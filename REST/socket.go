@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"goMS1Assignment/REST/database"
+	"goMS1Assignment/REST/events"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	socketWriteWait  = 10 * time.Second //time allowed to write one message
+	socketPongWait   = 60 * time.Second //a client that answers no ping for this long is disconnected
+	socketPingPeriod = socketPongWait / 2
+	socketMaxMessage = 4096
+	socketMaxFilters = 200 //codes and lecturers one connection can follow, as every event is checked against them
+)
+
+//errTooManyFilters refuses a subscription that would follow more than socketMaxFilters codes and lecturers
+var errTooManyFilters = fmt.Errorf("Subscriptions are limited to %d codes and lecturers", socketMaxFilters)
+
+var upgrader = websocket.Upgrader{}
+
+//socketRequest is a message from the client. Type is subscribe or unsubscribe;
+//a course matches a subscription if its code or its lecturer is listed.
+type socketRequest struct {
+	Type      string   `json:"Type"`
+	Codes     []int    `json:"Codes"`
+	Lecturers []string `json:"Lecturers"`
+}
+
+//socketMessage is a message to the client: an event, the current subscription after a request, or an error
+type socketMessage struct {
+	Type      string               `json:"Type"` //event, subscribed or error
+	ID        uint64               `json:"ID,omitempty"`
+	Event     string               `json:"Event,omitempty"`
+	Course    *database.CourseInfo `json:"Course,omitempty"`
+	Codes     []int                `json:"Codes,omitempty"`
+	Lecturers []string             `json:"Lecturers,omitempty"`
+	Message   string               `json:"Message,omitempty"`
+}
+
+//subscription is the set of course codes and lecturers one connection wants to hear about
+type subscription struct {
+	mu        sync.Mutex
+	codes     map[int]bool
+	lecturers map[string]bool //lower case
+}
+
+//apply adds or removes the codes and lecturers of request. A subscription that would follow more than
+//socketMaxFilters of them is refused with errTooManyFilters and left as it was.
+func (s *subscription) apply(request socketRequest) (socketMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscribe := request.Type == "subscribe"
+	if subscribe {
+		codes, lecturers := make(map[int]bool), make(map[string]bool)
+		for _, code := range request.Codes {
+			if !s.codes[code] {
+				codes[code] = true
+			}
+		}
+		for _, lecturer := range request.Lecturers {
+			if lecturer = strings.ToLower(strings.TrimSpace(lecturer)); !s.lecturers[lecturer] {
+				lecturers[lecturer] = true
+			}
+		}
+		if len(s.codes)+len(s.lecturers)+len(codes)+len(lecturers) > socketMaxFilters {
+			return socketMessage{}, errTooManyFilters
+		}
+	}
+	for _, code := range request.Codes {
+		if subscribe {
+			s.codes[code] = true
+		} else {
+			delete(s.codes, code)
+		}
+	}
+	for _, lecturer := range request.Lecturers {
+		lecturer = strings.ToLower(strings.TrimSpace(lecturer))
+		if subscribe {
+			s.lecturers[lecturer] = true
+		} else {
+			delete(s.lecturers, lecturer)
+		}
+	}
+
+	reply := socketMessage{Type: "subscribed", Codes: []int{}, Lecturers: []string{}}
+	for code := range s.codes {
+		reply.Codes = append(reply.Codes, code)
+	}
+	for lecturer := range s.lecturers {
+		reply.Lecturers = append(reply.Lecturers, lecturer)
+	}
+	return reply, nil
+}
+
+func (s *subscription) matches(course database.CourseInfo) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.codes[course.Code] || s.lecturers[strings.ToLower(strings.TrimSpace(course.Lecturer))]
+}
+
+//courseSocket upgrades to a WebSocket that pushes the course changes the client subscribed to.
+//The access key is checked before the handshake. The server pings every 30 seconds and drops clients that stop answering;
+//a client that cannot keep up with the changes is disconnected with close code 1013 (try again later),
+//and one that subscribes to more than socketMaxFilters codes and lecturers with 1008 (policy violation).
+func courseSocket(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r) {
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error("Error at course socket function, handshake failed. ", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	_, _, updates, cancel := courseEvents.Subscribe(0)
+	defer cancel()
+
+	sub := &subscription{codes: make(map[int]bool), lecturers: make(map[string]bool)}
+	replies := make(chan socketMessage, 8)
+	done, stop := make(chan struct{}), make(chan struct{})
+	defer close(stop)
+	go readSocket(conn, sub, replies, done, stop)
+
+	ping := time.NewTicker(socketPingPeriod)
+	defer ping.Stop()
+	for {
+		var err error
+		select {
+		case <-done:
+			return
+		case reply := <-replies:
+			err = writeSocket(conn, reply)
+		case event, ok := <-updates:
+			if !ok {
+				log.Warning("Course socket client too slow, closing connection")
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"), time.Now().Add(socketWriteWait))
+				return
+			}
+			if sub.matches(event.Course) {
+				err = writeSocket(conn, eventMessage(event))
+			}
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+//readSocket applies the client's subscription requests until the connection closes, then closes done.
+//It stops early once stop is closed by the writing side.
+func readSocket(conn *websocket.Conn, sub *subscription, replies chan<- socketMessage, done, stop chan struct{}) {
+	defer close(done)
+	conn.SetReadLimit(socketMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var request socketRequest
+		reply := socketMessage{Type: "error", Message: "Please send JSON with Type subscribe or unsubscribe"}
+		if json.Unmarshal(data, &request) == nil && (request.Type == "subscribe" || request.Type == "unsubscribe") {
+			if reply, err = sub.apply(request); err != nil {
+				//WriteControl may be called alongside the writing side
+				conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, err.Error()), time.Now().Add(socketWriteWait))
+				return
+			}
+		}
+		select {
+		case replies <- reply:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func writeSocket(conn *websocket.Conn, message socketMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+	return conn.WriteJSON(message)
+}
+
+func eventMessage(event events.Event) socketMessage {
+	course := event.Course
+	validateAndSanitize(&course)
+	return socketMessage{Type: "event", ID: event.ID, Event: event.Type, Course: &course}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"goMS1Assignment/REST/database"
+	"goMS1Assignment/REST/events"
+
+	"github.com/gorilla/websocket"
+)
+
+//dialSocket opens the course socket of a test server with the key given, publishing through a fresh broker
+func dialSocket(t *testing.T, key string) (*websocket.Conn, *http.Response, *events.Broker, error) {
+	router := useRepository(t, newMemoryRepository())
+	old := courseEvents
+	courseEvents = events.NewBroker(16)
+	t.Cleanup(func() { courseEvents = old })
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/courses/socket?key=" + key
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Cleanup(func() { conn.Close() })
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	}
+	return conn, resp, courseEvents, err
+}
+
+//request sends a subscription request and returns the reply
+func request(t *testing.T, conn *websocket.Conn, req socketRequest) socketMessage {
+	if err := conn.WriteJSON(req); err != nil {
+		t.Fatal(err)
+	}
+	var reply socketMessage
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+//TestSocketKey checks the access key is checked before the handshake
+func TestSocketKey(t *testing.T) {
+	_, resp, _, err := dialSocket(t, "wrong")
+	if err == nil || resp == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("handshake with a wrong key: %v, %v", resp, err)
+	}
+}
+
+//TestSocketEvents checks a connection gets the changes to the codes and lecturers it follows, and no others
+func TestSocketEvents(t *testing.T) {
+	conn, _, broker, err := dialSocket(t, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := request(t, conn, socketRequest{Type: "subscribe", Codes: []int{1}, Lecturers: []string{" Ben Low "}})
+	if reply.Type != "subscribed" || len(reply.Codes) != 1 || len(reply.Lecturers) != 1 || reply.Lecturers[0] != "ben low" {
+		t.Errorf("reply to subscribe: %+v", reply)
+	}
+
+	broker.Publish(events.Updated, database.CourseInfo{Code: 2, Lecturer: "Tan"})
+	broker.Publish(events.Created, database.CourseInfo{Code: 3, Lecturer: "BEN LOW"})
+	broker.Publish(events.Deleted, database.CourseInfo{Code: 1, Lecturer: "Tan"})
+	for _, want := range []int{3, 1} {
+		var message socketMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatal(err)
+		}
+		if message.Type != "event" || message.Course == nil || message.Course.Code != want {
+			t.Errorf("got %+v, want the event for course %d", message, want)
+		}
+	}
+
+	reply = request(t, conn, socketRequest{Type: "unsubscribe", Codes: []int{1}})
+	if len(reply.Codes) != 0 || len(reply.Lecturers) != 1 {
+		t.Errorf("reply to unsubscribe: %+v", reply)
+	}
+	if reply := request(t, conn, socketRequest{Type: "follow"}); reply.Type != "error" {
+		t.Errorf("reply to an unknown request: %+v", reply)
+	}
+}
+
+//TestSocketFilterLimit checks a connection following too many codes and lecturers is closed with 1008
+func TestSocketFilterLimit(t *testing.T) {
+	conn, _, _, err := dialSocket(t, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes := make([]int, socketMaxFilters-1)
+	for i := range codes {
+		codes[i] = i + 1
+	}
+	if reply := request(t, conn, socketRequest{Type: "subscribe", Codes: codes, Lecturers: []string{"Tan"}}); len(reply.Codes)+len(reply.Lecturers) != socketMaxFilters {
+		t.Fatalf("subscribing up to the limit: %d codes and %d lecturers", len(reply.Codes), len(reply.Lecturers))
+	}
+	conn.WriteJSON(socketRequest{Type: "subscribe", Lecturers: []string{"Lee"}})
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+		t.Errorf("subscribing over the limit: %v, want close code 1008", err)
+	}
+}
+
+//TestSubscriptionLimit checks only codes and lecturers not followed yet count towards the limit,
+//and a refused request changes nothing
+func TestSubscriptionLimit(t *testing.T) {
+	sub := &subscription{codes: make(map[int]bool), lecturers: make(map[string]bool)}
+	codes := make([]int, socketMaxFilters)
+	for i := range codes {
+		codes[i] = i + 1
+	}
+	if _, err := sub.apply(socketRequest{Type: "subscribe", Codes: codes}); err != nil {
+		t.Fatal(err)
+	}
+	//already followed, so not more
+	if _, err := sub.apply(socketRequest{Type: "subscribe", Codes: codes[:10]}); err != nil {
+		t.Errorf("subscribing again to followed codes: %v", err)
+	}
+	if _, err := sub.apply(socketRequest{Type: "subscribe", Codes: []int{1}, Lecturers: []string{"Tan"}}); err != errTooManyFilters {
+		t.Errorf("subscribing over the limit: %v", err)
+	}
+	if sub.lecturers["tan"] {
+		t.Error("a refused subscription was applied")
+	}
+	//repeats within one request count once
+	sub.apply(socketRequest{Type: "unsubscribe", Codes: codes[:1]})
+	if _, err := sub.apply(socketRequest{Type: "subscribe", Lecturers: []string{"Tan", "tan", " TAN"}}); err != nil {
+		t.Errorf("one lecturer written three ways: %v", err)
+	}
+}
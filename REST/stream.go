@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"goMS1Assignment/REST/events"
+	"goMS1Assignment/REST/render"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//heartbeatInterval keeps idle streams from being closed by proxies
+const heartbeatInterval = 15 * time.Second
+
+var courseEvents *events.Broker
+
+//courseEventStream streams course changes as Server-Sent Events: created, updated and deleted events whose data is
+//the course as JSON. A client reconnecting with Last-Event-ID (or the lastEventId query parameter) first gets
+//the events it missed; if some of them are no longer in the event log it gets a reset event and should reload.
+func courseEventStream(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		render.Status(w, r, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	var resumeFrom uint64
+	if lastID != "" {
+		var err error
+		if resumeFrom, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			render.Status(w, r, http.StatusBadRequest, "Last-Event-ID needs to be an event ID")
+			return
+		}
+	}
+
+	backlog, complete, updates, cancel := courseEvents.Subscribe(resumeFrom)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") //stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range backlog {
+		writeEvent(w, event)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-updates:
+			if !ok {
+				//the client fell too far behind; it reconnects and resumes from the log
+				log.Warning("Event stream subscriber too slow, closing stream")
+				return
+			}
+			writeEvent(w, event)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event events.Event) {
+	course := event.Course
+	validateAndSanitize(&course)
+	data, _ := json.Marshal(course)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+//Headers sent with every delivery
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	//SignatureHeader is "sha256=" followed by the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with the webhook secret
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+const batchSize = 100
+
+//pruneInterval is how often old deliveries and outbox events are deleted
+const pruneInterval = time.Hour
+
+//Dispatcher relays the outbox into deliveries and sends the deliveries that are due
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+
+	MaxAttempts int           //attempts before a delivery is marked failed
+	Backoff     time.Duration //wait before the first retry, doubled for each one after
+	MaxBackoff  time.Duration
+	Interval    time.Duration //how often the outbox and due deliveries are checked
+	//Retention is how long finished deliveries and relayed outbox events are kept; 0 keeps them forever
+	Retention time.Duration
+
+	wake      chan struct{}
+	lastPrune time.Time
+}
+
+//NewDispatcher sends deliveries from store with client, whose Timeout bounds each attempt
+func NewDispatcher(store Store, client *http.Client) *Dispatcher {
+	return &Dispatcher{
+		store:       store,
+		client:      client,
+		MaxAttempts: 8,
+		Backoff:     30 * time.Second,
+		MaxBackoff:  time.Hour,
+		Interval:    time.Second,
+		Retention:   7 * 24 * time.Hour,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+//Notify asks the dispatcher to check for work now rather than at its next interval, e.g. after a redelivery.
+//Course writes are not notified: their outbox events are picked up within Interval.
+func (d *Dispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+//Run checks for work every Interval, or when notified, until stop is closed
+func (d *Dispatcher) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+		d.RunOnce(context.Background())
+	}
+}
+
+//RunOnce relays pending outbox events and sends every delivery that is due, pruning old rows once every pruneInterval
+func (d *Dispatcher) RunOnce(ctx context.Context) {
+	d.prune(ctx)
+	for {
+		n, err := d.store.RelayOutbox(ctx, batchSize)
+		if err != nil {
+			log.Error("Error relaying webhook outbox. ", err.Error())
+			break
+		}
+		if n < batchSize {
+			break
+		}
+	}
+
+	//deliveries are claimed one at a time, so the lease only has to outlast the one attempt it was taken for;
+	//a batch claimed at once would fall due again while the earlier ones were still being sent
+	lease := 2 * d.client.Timeout
+	if lease <= 0 {
+		lease = time.Minute
+	}
+	for {
+		deliveries, err := d.store.ClaimDueDeliveries(ctx, time.Now(), lease, 1)
+		if err != nil {
+			log.Error("Error loading webhook deliveries. ", err.Error())
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+		d.attempt(ctx, deliveries[0])
+	}
+}
+
+//prune deletes the finished deliveries and relayed outbox events older than Retention
+func (d *Dispatcher) prune(ctx context.Context) {
+	if d.Retention <= 0 || time.Since(d.lastPrune) < pruneInterval {
+		return
+	}
+	d.lastPrune = time.Now()
+	n, err := d.store.Prune(ctx, d.lastPrune.Add(-d.Retention))
+	if err != nil {
+		log.Error("Error pruning webhook deliveries. ", err.Error())
+		return
+	}
+	if n > 0 {
+		fmt.Println("Pruned", n, "webhook deliveries and outbox events older than", d.Retention)
+	}
+}
+
+//attempt sends one delivery and records the outcome, scheduling a retry if it failed
+func (d *Dispatcher) attempt(ctx context.Context, delivery Delivery) {
+	hook, err := d.store.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		log.Error("Error loading webhook for delivery ", delivery.ID, ". ", err.Error())
+		return
+	}
+
+	delivery.Attempts++
+	delivery.LastStatusCode, err = d.send(ctx, hook, delivery)
+	delivery.LastError = ""
+	switch {
+	case err == nil:
+		delivery.Status = StatusSucceeded
+		fmt.Println("Webhook delivery", delivery.ID, "of", delivery.Event, "to", hook.URL, "succeeded")
+	case delivery.Attempts >= d.MaxAttempts:
+		delivery.Status, delivery.LastError = StatusFailed, err.Error()
+		log.Error("Webhook delivery ", delivery.ID, " to ", hook.URL, " failed for good. ", err.Error())
+	default:
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = time.Now().Add(d.backoff(delivery.Attempts))
+		log.Warning("Webhook delivery ", delivery.ID, " to ", hook.URL, " failed, retrying at ", delivery.NextAttemptAt.Format(time.RFC3339), ". ", err.Error())
+	}
+	if err := d.store.UpdateDelivery(ctx, delivery); err != nil {
+		log.Error("Error saving webhook delivery ", delivery.ID, ". ", err.Error())
+	}
+}
+
+//send posts the payload and treats anything but a 2xx answer as a failure
+func (d *Dispatcher) send(ctx context.Context, hook Webhook, delivery Delivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, delivery.Event)
+	request.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, Sign(hook.Secret, timestamp, delivery.Payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, 1<<16))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("endpoint answered %s", response.Status)
+	}
+	return response.StatusCode, nil
+}
+
+//backoff doubles the wait with every attempt, up to MaxBackoff, with up to a fifth of jitter
+//so endpoints coming back up are not hit by every retry at once
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.Backoff
+	for i := 1; i < attempts && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.MaxBackoff {
+		wait = d.MaxBackoff
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait)/5+1))
+}
+
+//Sign returns the signature header value for body sent at timestamp. Receivers recompute it to verify a delivery.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
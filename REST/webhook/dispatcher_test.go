@@ -0,0 +1,297 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+//memoryStore keeps webhooks and deliveries in memory, claiming deliveries the way SQLStore does
+type memoryStore struct {
+	Store
+	mu         sync.Mutex
+	hooks      map[int64]Webhook
+	deliveries []Delivery
+	limits     []int //the limit of every claim
+	prunes     []time.Time
+}
+
+func newMemoryStore(hook Webhook, deliveries int) *memoryStore {
+	s := &memoryStore{hooks: map[int64]Webhook{hook.ID: hook}}
+	for i := 1; i <= deliveries; i++ {
+		s.deliveries = append(s.deliveries, Delivery{ID: int64(i), WebhookID: hook.ID, EventID: int64(i), Event: "course.created",
+			Payload: []byte(`{"EventID":` + strconv.Itoa(i) + `}`), Status: StatusPending})
+	}
+	return s
+}
+
+func (s *memoryStore) RelayOutbox(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+func (s *memoryStore) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	hook, ok := s.hooks[id]
+	if !ok {
+		return Webhook{}, ErrNotFound
+	}
+	return hook, nil
+}
+
+func (s *memoryStore) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = append(s.limits, limit)
+	var claimed []Delivery
+	for i := range s.deliveries {
+		delivery := &s.deliveries[i]
+		if len(claimed) == limit || delivery.Status != StatusPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		delivery.NextAttemptAt = now.Add(lease)
+		claimed = append(claimed, *delivery)
+	}
+	return claimed, nil
+}
+
+func (s *memoryStore) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.deliveries {
+		if s.deliveries[i].ID == delivery.ID {
+			s.deliveries[i] = delivery
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	s.prunes = append(s.prunes, before)
+	return 0, nil
+}
+
+func (s *memoryStore) delivery(id int64) Delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.deliveries[id-1]
+}
+
+//endpoint is a webhook receiver that answers status and counts the deliveries it gets by ID
+func endpoint(t *testing.T, status int, delay time.Duration) (*httptest.Server, func(id string) int) {
+	var mu sync.Mutex
+	received := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received[r.Header.Get(DeliveryHeader)]++
+		mu.Unlock()
+		time.Sleep(delay)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func(id string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return received[id]
+	}
+}
+
+//testDispatcher sends with a plain client, since the test endpoints are on loopback
+func testDispatcher(store Store, timeout time.Duration) *Dispatcher {
+	d := NewDispatcher(store, &http.Client{Timeout: timeout})
+	d.Retention = 0
+	return d
+}
+
+//TestSign checks the signature header is sha256= and the hex HMAC of the timestamp, a dot and the body
+func TestSign(t *testing.T) {
+	body := []byte(`{"EventID":1}`)
+	signature := Sign("secret", "1700000000", body)
+	if !regexp.MustCompile(`^sha256=[0-9a-f]{64}$`).MatchString(signature) {
+		t.Fatalf("signature %q is not sha256= and 64 hex digits", signature)
+	}
+	//what a receiver computes to verify it
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("signature %q, want %q", signature, want)
+	}
+	for name, other := range map[string]string{
+		"secret":    Sign("other", "1700000000", body),
+		"timestamp": Sign("secret", "1700000001", body),
+		"body":      Sign("secret", "1700000000", []byte(`{"EventID":2}`)),
+	} {
+		if other == signature {
+			t.Errorf("a different %s gives the same signature", name)
+		}
+	}
+}
+
+//TestDeliveryHeaders checks a delivery is posted with its event, ID, timestamp and a signature that verifies
+func TestDeliveryHeaders(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	store := newMemoryStore(Webhook{ID: 1, URL: srv.URL, Secret: "secret"}, 1)
+	testDispatcher(store, time.Second).RunOnce(context.Background())
+
+	if got == nil {
+		t.Fatal("nothing was delivered")
+	}
+	timestamp := got.Header.Get(TimestampHeader)
+	if got.Header.Get(EventHeader) != "course.created" || got.Header.Get(DeliveryHeader) != "1" || got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("headers %v", got.Header)
+	}
+	if got.Header.Get(SignatureHeader) != Sign("secret", timestamp, body) || string(body) != `{"EventID":1}` {
+		t.Errorf("signature %q does not verify for timestamp %s and body %s", got.Header.Get(SignatureHeader), timestamp, body)
+	}
+	if delivery := store.delivery(1); delivery.Status != StatusSucceeded || delivery.Attempts != 1 || delivery.LastStatusCode != 200 {
+		t.Errorf("delivery after a 200: %+v", delivery)
+	}
+}
+
+//TestRetryUntilMaxAttempts checks a failing delivery stays pending with a later next attempt, and is marked failed
+//at MaxAttempts
+func TestRetryUntilMaxAttempts(t *testing.T) {
+	srv, received := endpoint(t, http.StatusInternalServerError, 0)
+	store := newMemoryStore(Webhook{ID: 1, URL: srv.URL}, 1)
+	d := testDispatcher(store, time.Second)
+	d.MaxAttempts = 3
+	d.Backoff = 20 * time.Millisecond
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		before := time.Now()
+		d.RunOnce(context.Background())
+		delivery := store.delivery(1)
+		if delivery.Attempts != attempt || delivery.LastStatusCode != 500 || delivery.LastError == "" {
+			t.Fatalf("attempt %d: %+v", attempt, delivery)
+		}
+		if attempt < 3 {
+			if delivery.Status != StatusPending || delivery.NextAttemptAt.Before(before.Add(d.backoff(attempt)*5/6)) {
+				t.Fatalf("attempt %d: status %s, next attempt in %v, want pending after the backoff", attempt, delivery.Status, delivery.NextAttemptAt.Sub(before))
+			}
+			//not due yet, so not sent again
+			d.RunOnce(context.Background())
+			if received("1") != attempt {
+				t.Fatalf("sent %d times before the backoff was over, want %d", received("1"), attempt)
+			}
+			time.Sleep(delivery.NextAttemptAt.Sub(time.Now()))
+		} else if delivery.Status != StatusFailed {
+			t.Errorf("status %s after %d attempts, want failed", delivery.Status, attempt)
+		}
+	}
+	d.RunOnce(context.Background())
+	if received("1") != 3 {
+		t.Errorf("a failed delivery was sent again: %d attempts", received("1"))
+	}
+}
+
+//TestRedirectNotFollowed checks an endpoint answering 3xx gets a failed attempt, not a delivery to the redirect target
+func TestRedirectNotFollowed(t *testing.T) {
+	target, received := endpoint(t, http.StatusOK, 0)
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirect.Close()
+	store := newMemoryStore(Webhook{ID: 1, URL: redirect.URL}, 1)
+	client := NewClient(time.Second)
+	//the dial guard is tested on its own; this test is about redirects between loopback servers
+	client.Transport = http.DefaultTransport
+	NewDispatcher(store, client).RunOnce(context.Background())
+
+	if received("1") != 0 {
+		t.Error("the redirect was followed")
+	}
+	if delivery := store.delivery(1); delivery.Status != StatusPending || delivery.LastStatusCode != http.StatusTemporaryRedirect {
+		t.Errorf("delivery after a redirect: %+v", delivery)
+	}
+}
+
+//TestBackoffGrowth checks each retry waits twice as long as the one before, up to MaxBackoff, plus at most a fifth
+func TestBackoffGrowth(t *testing.T) {
+	d := NewDispatcher(&memoryStore{}, &http.Client{})
+	d.Backoff, d.MaxBackoff = time.Second, 10*time.Second
+	for attempts, base := range []time.Duration{1, 1, 2, 4, 8, 10, 10} {
+		if attempts == 0 {
+			continue
+		}
+		base *= time.Second
+		for i := 0; i < 100; i++ {
+			if wait := d.backoff(attempts); wait < base || wait > base+base/5 {
+				t.Fatalf("backoff after %d attempts = %v, want between %v and %v", attempts, wait, base, base+base/5)
+			}
+		}
+	}
+}
+
+//TestClaimOneAtATime checks a delivery is only claimed when it is about to be sent, so a second dispatcher
+//never sends again what the first claimed while a slow endpoint held it up
+func TestClaimOneAtATime(t *testing.T) {
+	srv, received := endpoint(t, http.StatusOK, 30*time.Millisecond)
+	store := newMemoryStore(Webhook{ID: 1, URL: srv.URL}, 6)
+	//the lease covers one attempt, 2*40ms, but not the 6*30ms the whole outbox takes
+	first, second := testDispatcher(store, 40*time.Millisecond), testDispatcher(store, 40*time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		first.RunOnce(context.Background())
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	second.RunOnce(context.Background())
+	<-done
+
+	for id := 1; id <= 6; id++ {
+		if n := received(strconv.Itoa(id)); n != 1 {
+			t.Errorf("delivery %d sent %d times", id, n)
+		}
+	}
+	for _, limit := range store.limits {
+		if limit != 1 {
+			t.Fatalf("claimed %d deliveries at once", limit)
+		}
+	}
+}
+
+//TestPruneRetention checks old rows are pruned once every pruneInterval, keeping the last Retention,
+//and never when Retention is 0
+func TestPruneRetention(t *testing.T) {
+	store := &memoryStore{}
+	d := NewDispatcher(store, NewClient(time.Second))
+	d.Retention = 48 * time.Hour
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		d.RunOnce(context.Background())
+	}
+	if len(store.prunes) != 1 {
+		t.Fatalf("pruned %d times in a row, want once per interval", len(store.prunes))
+	}
+	if kept := start.Sub(store.prunes[0]); kept < d.Retention-time.Second || kept > d.Retention+time.Second {
+		t.Errorf("pruned rows older than %v, want %v", kept, d.Retention)
+	}
+
+	//once the interval is over it prunes again
+	d.lastPrune = d.lastPrune.Add(-pruneInterval)
+	d.RunOnce(context.Background())
+	if len(store.prunes) != 2 {
+		t.Errorf("pruned %d times after the interval, want 2", len(store.prunes))
+	}
+
+	store.prunes = nil
+	d = NewDispatcher(store, NewClient(time.Second))
+	d.Retention = 0
+	d.RunOnce(context.Background())
+	if len(store.prunes) != 0 {
+		t.Error("pruned with a retention of 0")
+	}
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+//ErrPrivateAddress is returned for a webhook URL that points into the server's own network
+var ErrPrivateAddress = errors.New("webhook URL points at a loopback, link-local or private address")
+
+//blockedNetworks are the address ranges webhooks may not reach besides loopback, link-local, multicast and unspecified,
+//so a registered URL cannot be used to probe the server's own network
+var blockedNetworks = parseCIDRs(
+	"0.0.0.0/8",      //"this" network
+	"10.0.0.0/8",     //private
+	"100.64.0.0/10",  //carrier-grade NAT
+	"172.16.0.0/12",  //private
+	"192.0.0.0/24",   //IETF protocol assignments
+	"192.168.0.0/16", //private
+	"198.18.0.0/15",  //benchmarking
+	"240.0.0.0/4",    //reserved
+	"fc00::/7",       //unique local
+	"64:ff9b::/96",   //NAT64, which can reach any IPv4 address
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, networks[i], _ = net.ParseCIDR(cidr)
+	}
+	return networks
+}
+
+//PublicIP reports whether ip may be sent deliveries
+func PublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+//CheckURL refuses a webhook URL whose host is, or resolves to, an address that is not public.
+//A host that does not resolve yet is accepted; the dialer of NewClient checks it again at every delivery.
+func CheckURL(ctx context.Context, endpoint *url.URL) error {
+	host := endpoint.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !PublicIP(ip) {
+			return ErrPrivateAddress
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !PublicIP(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+//NewClient returns the client deliveries are sent with. Its dialer refuses addresses that are not public,
+//so a host that resolves to one after registration is not reached either. Redirects are not followed:
+//a 3xx answer counts as a failed attempt, so a signed payload never leaves the registered https URL.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	//a proxy would make the dial the proxy's, and the proxy could reach what the guard refuses
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
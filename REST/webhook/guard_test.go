@@ -0,0 +1,114 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+//TestPublicIP checks the address ranges of the server's own network are refused and the rest allowed
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"127.0.0.1", false},
+		{"127.8.8.8", false},
+		{"::1", false},
+		{"169.254.169.254", false}, //cloud metadata
+		{"fe80::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"fd00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+		{"224.0.0.1", false},
+		{"8.8.8.8", true},
+		{"172.32.0.1", true},
+		{"2001:4860:4860::8888", true},
+	}
+	for _, tt := range tests {
+		if got := PublicIP(net.ParseIP(tt.ip)); got != tt.public {
+			t.Errorf("PublicIP(%s) = %v, want %v", tt.ip, got, tt.public)
+		}
+	}
+}
+
+//TestCheckURL checks registration refuses URLs whose host is or resolves to an address that is not public
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		refused bool
+	}{
+		{"https://127.0.0.1/hook", true},
+		{"https://[::1]:8443/hook", true},
+		{"https://169.254.169.254/latest/meta-data", true},
+		{"https://192.168.0.10/hook", true},
+		{"https://localhost/hook", true},
+		{"https://93.184.216.34/hook", false},
+		//hosts that do not resolve are left to the dialer
+		{"https://unresolvable.invalid/hook", false},
+	}
+	for _, tt := range tests {
+		endpoint, _ := url.Parse(tt.url)
+		err := CheckURL(context.Background(), endpoint)
+		if refused := errors.Is(err, ErrPrivateAddress); refused != tt.refused {
+			t.Errorf("CheckURL(%s) = %v, want refused %v", tt.url, err, tt.refused)
+		}
+	}
+}
+
+//TestClientRefusesPrivateAddresses checks deliveries never connect to a private address, whatever the URL registered
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	var reached bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(time.Second).Post(srv.URL, "application/json", nil)
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("post to %s: %v, want ErrPrivateAddress", srv.URL, err)
+	}
+	if reached {
+		t.Error("the request reached the loopback server")
+	}
+}
+
+//TestClientDoesNotFollowRedirects checks a 3xx answer is returned as it is, so a signed payload is never sent on
+//to another URL, such as a plain http one
+func TestClientDoesNotFollowRedirects(t *testing.T) {
+	var reached bool
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer target.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	defer redirect.Close()
+
+	client := NewClient(time.Second)
+	//the dial guard is tested above; both servers here are on loopback
+	client.Transport = http.DefaultTransport
+	resp, err := client.Post(redirect.URL, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("status %d, want the %d itself", resp.StatusCode, http.StatusFound)
+	}
+	if reached {
+		t.Error("the redirect was followed")
+	}
+}
@@ -0,0 +1,279 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"goMS1Assignment/REST/database"
+)
+
+//SQLStore keeps webhooks and deliveries in the MySQL Webhooks and WebhookDeliveries tables
+type SQLStore struct {
+	db *sql.DB
+}
+
+//NewSQLStore returns a store backed by db
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+func (s *SQLStore) CreateWebhook(ctx context.Context, hook *Webhook) error {
+	result, err := s.db.ExecContext(ctx, "INSERT INTO Webhooks (URL, Events, Secret) VALUES (?, ?, ?)",
+		hook.URL, strings.Join(hook.Events, ","), hook.Secret)
+	if err != nil {
+		return err
+	}
+	hook.ID, err = result.LastInsertId()
+	hook.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	return err
+}
+
+func (s *SQLStore) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT ID, URL, Events, Secret, CreatedAt FROM Webhooks ORDER BY ID")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []Webhook
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hook.Secret = ""
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+func (s *SQLStore) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT ID, URL, Events, Secret, CreatedAt FROM Webhooks WHERE ID = ?", id)
+	hook, err := scanWebhook(row)
+	if err == sql.ErrNoRows {
+		return Webhook{}, ErrNotFound
+	}
+	return hook, err
+}
+
+//DeleteWebhook removes the webhook; its deliveries go with it
+func (s *SQLStore) DeleteWebhook(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM Webhooks WHERE ID = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) RelayOutbox(ctx context.Context, limit int) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	events, err := database.ClaimOutboxEvents(ctx, tx, limit)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT ID, URL, Events, Secret, CreatedAt FROM Webhooks")
+	if err != nil {
+		return 0, err
+	}
+	var hooks []Webhook
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		hooks = append(hooks, hook)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	for _, event := range events {
+		payload, err := json.Marshal(Payload{EventID: event.ID, Event: event.Type, CreatedAt: event.CreatedAt, Course: event.Course})
+		if err != nil {
+			return 0, err
+		}
+		for _, hook := range hooks {
+			if !hook.Subscribes(event.Type) {
+				continue
+			}
+			_, err := tx.ExecContext(ctx, "INSERT INTO WebhookDeliveries (WebhookID, EventID, Event, Payload, Status, NextAttemptAt) VALUES (?, ?, ?, ?, ?, ?)",
+				hook.ID, event.ID, event.Type, payload, StatusPending, now)
+			if err != nil {
+				return 0, err
+			}
+		}
+		if err := database.MarkOutboxEventProcessed(ctx, tx, event.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(events), tx.Commit()
+}
+
+func (s *SQLStore) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT "+deliveryColumns+" FROM WebhookDeliveries WHERE Status = ? AND NextAttemptAt <= ? ORDER BY NextAttemptAt LIMIT ? FOR UPDATE SKIP LOCKED",
+		StatusPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	var deliveries []Delivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, delivery := range deliveries {
+		if _, err := tx.ExecContext(ctx, "UPDATE WebhookDeliveries SET NextAttemptAt = ? WHERE ID = ?", now.Add(lease).UTC(), delivery.ID); err != nil {
+			return nil, err
+		}
+	}
+	return deliveries, tx.Commit()
+}
+
+func (s *SQLStore) UpdateDelivery(ctx context.Context, delivery Delivery) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE WebhookDeliveries SET Status = ?, Attempts = ?, NextAttemptAt = ?, LastStatusCode = ?, LastError = ? WHERE ID = ?",
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt.UTC(), delivery.LastStatusCode, truncate(delivery.LastError, 500), delivery.ID)
+	return err
+}
+
+//ListDeliveries returns the most recent deliveries of a webhook first
+func (s *SQLStore) ListDeliveries(ctx context.Context, webhookID int64, limit int) ([]Delivery, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+deliveryColumns+" FROM WebhookDeliveries WHERE WebhookID = ? ORDER BY ID DESC LIMIT ?", webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+//Redeliver adds a new pending delivery with the same event and payload, leaving the original in the log
+func (s *SQLStore) Redeliver(ctx context.Context, webhookID, deliveryID int64) (Delivery, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+deliveryColumns+" FROM WebhookDeliveries WHERE ID = ? AND WebhookID = ?", deliveryID, webhookID)
+	original, err := scanDelivery(row)
+	if err == sql.ErrNoRows {
+		return Delivery{}, ErrNotFound
+	}
+	if err != nil {
+		return Delivery{}, err
+	}
+	now := time.Now().UTC()
+	result, err := s.db.ExecContext(ctx, "INSERT INTO WebhookDeliveries (WebhookID, EventID, Event, Payload, Status, NextAttemptAt) VALUES (?, ?, ?, ?, ?, ?)",
+		original.WebhookID, original.EventID, original.Event, []byte(original.Payload), StatusPending, now)
+	if err != nil {
+		return Delivery{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return Delivery{}, err
+	}
+	return Delivery{
+		ID:            id,
+		WebhookID:     original.WebhookID,
+		EventID:       original.EventID,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        StatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now.Truncate(time.Second),
+	}, nil
+}
+
+//Prune deletes the finished deliveries created before before, and the outbox events relayed before it.
+//Rows go in batches so the tables are never locked for long.
+func (s *SQLStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	var total int64
+	for _, prune := range []func() (int64, error){
+		func() (int64, error) {
+			result, err := s.db.ExecContext(ctx, "DELETE FROM WebhookDeliveries WHERE Status <> ? AND CreatedAt < ? LIMIT ?", StatusPending, before.UTC(), pruneBatch)
+			if err != nil {
+				return 0, err
+			}
+			return result.RowsAffected()
+		},
+		func() (int64, error) {
+			return database.PruneOutboxEvents(ctx, s.db, before, pruneBatch)
+		},
+	} {
+		for {
+			n, err := prune()
+			total += n
+			if err != nil {
+				return total, err
+			}
+			if n < pruneBatch {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+const pruneBatch = 1000
+
+const deliveryColumns = "ID, WebhookID, EventID, Event, Payload, Status, Attempts, NextAttemptAt, LastStatusCode, LastError, CreatedAt"
+
+//scanner is satisfied by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(row scanner) (Webhook, error) {
+	var hook Webhook
+	var events string
+	err := row.Scan(&hook.ID, &hook.URL, &events, &hook.Secret, &hook.CreatedAt)
+	if events != "" {
+		hook.Events = strings.Split(events, ",")
+	}
+	return hook, err
+}
+
+func scanDelivery(row scanner) (Delivery, error) {
+	var delivery Delivery
+	var payload []byte
+	err := row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.Event, &payload, &delivery.Status,
+		&delivery.Attempts, &delivery.NextAttemptAt, &delivery.LastStatusCode, &delivery.LastError, &delivery.CreatedAt)
+	delivery.Payload = payload
+	return delivery, err
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+//TestRedeliver checks a redelivery is a new pending row with the original's event and payload,
+//leaving the original as it was
+func TestRedeliver(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	created := time.Now().Add(-time.Hour)
+	mock.ExpectQuery("SELECT (.+) FROM WebhookDeliveries WHERE ID = \\? AND WebhookID = \\?").
+		WithArgs(7, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"ID", "WebhookID", "EventID", "Event", "Payload", "Status", "Attempts", "NextAttemptAt", "LastStatusCode", "LastError", "CreatedAt"}).
+			AddRow(7, 3, 42, "course.updated", []byte(`{"EventID":42}`), StatusFailed, 8, created, 500, "server error", created))
+	mock.ExpectExec("INSERT INTO WebhookDeliveries").
+		WithArgs(3, 42, "course.updated", []byte(`{"EventID":42}`), StatusPending, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(8, 1))
+
+	delivery, err := NewSQLStore(db).Redeliver(context.Background(), 3, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.ID != 8 || delivery.WebhookID != 3 || delivery.EventID != 42 || delivery.Event != "course.updated" ||
+		string(delivery.Payload) != `{"EventID":42}` || delivery.Status != StatusPending || delivery.Attempts != 0 || delivery.LastError != "" {
+		t.Errorf("redelivery %+v", delivery)
+	}
+	//the original is only read, never updated
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+//TestRedeliverNotFound checks a delivery of another webhook, or none at all, is not redelivered
+func TestRedeliverNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery("SELECT (.+) FROM WebhookDeliveries").WithArgs(7, 4).WillReturnError(sql.ErrNoRows)
+
+	if _, err := NewSQLStore(db).Redeliver(context.Background(), 4, 7); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Redeliver of a missing delivery: %v, want ErrNotFound", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,90 @@
+//Package webhook notifies registered HTTPS endpoints of course lifecycle events.
+//Events are read from the transactional outbox, turned into one delivery per subscribed webhook
+//and sent HMAC-signed, with retries and exponential backoff. Every delivery is kept in a log and can be sent again.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"goMS1Assignment/REST/database"
+)
+
+//Delivery statuses
+const (
+	StatusPending   = "pending"   //waiting for its next attempt
+	StatusSucceeded = "succeeded" //the endpoint answered 2xx
+	StatusFailed    = "failed"    //every attempt failed
+)
+
+//ErrNotFound is returned for a webhook or delivery that does not exist
+var ErrNotFound = errors.New("not found")
+
+//Webhook is an endpoint subscribed to some course events
+type Webhook struct {
+	ID     int64    `json:"ID" yaml:"ID"`
+	URL    string   `json:"URL" yaml:"URL"`
+	Events []string `json:"Events" yaml:"Events"`
+	//Secret signs deliveries. It is only shown when the webhook is registered.
+	Secret    string    `json:"Secret,omitempty" yaml:"Secret,omitempty"`
+	CreatedAt time.Time `json:"CreatedAt" yaml:"CreatedAt"`
+}
+
+//Subscribes reports whether the webhook wants events of type event
+func (h Webhook) Subscribes(event string) bool {
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+//Delivery is one event sent, or to be sent, to one webhook
+type Delivery struct {
+	ID             int64           `json:"ID" yaml:"ID"`
+	WebhookID      int64           `json:"WebhookID" yaml:"WebhookID"`
+	EventID        int64           `json:"EventID" yaml:"EventID"`
+	Event          string          `json:"Event" yaml:"Event"`
+	Payload        json.RawMessage `json:"Payload" yaml:"-"`
+	Status         string          `json:"Status" yaml:"Status"`
+	Attempts       int             `json:"Attempts" yaml:"Attempts"`
+	NextAttemptAt  time.Time       `json:"NextAttemptAt" yaml:"NextAttemptAt"`
+	LastStatusCode int             `json:"LastStatusCode,omitempty" yaml:"LastStatusCode,omitempty"`
+	LastError      string          `json:"LastError,omitempty" yaml:"LastError,omitempty"`
+	CreatedAt      time.Time       `json:"CreatedAt" yaml:"CreatedAt"`
+}
+
+//Payload is the JSON body posted to a webhook
+type Payload struct {
+	EventID   int64               `json:"EventID"`
+	Event     string              `json:"Event"`
+	CreatedAt time.Time           `json:"CreatedAt"`
+	Course    database.CourseInfo `json:"Course"`
+}
+
+//Store keeps webhooks, relays the outbox into deliveries and logs every delivery attempt
+type Store interface {
+	CreateWebhook(ctx context.Context, hook *Webhook) error
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	//GetWebhook returns the webhook including its secret
+	GetWebhook(ctx context.Context, id int64) (Webhook, error)
+	DeleteWebhook(ctx context.Context, id int64) error
+
+	//RelayOutbox turns up to limit outbox events into pending deliveries for the webhooks subscribed to them,
+	//marking the events processed in the same transaction. It returns how many events it relayed.
+	RelayOutbox(ctx context.Context, limit int) (int, error)
+	//ClaimDueDeliveries returns up to limit pending deliveries whose next attempt is due at now.
+	//Their next attempt is pushed back by lease so no other server sends them meanwhile.
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	//UpdateDelivery saves the outcome of an attempt
+	UpdateDelivery(ctx context.Context, delivery Delivery) error
+	ListDeliveries(ctx context.Context, webhookID int64, limit int) ([]Delivery, error)
+	//Redeliver queues a delivery of webhookID to be sent again straight away, whatever its status
+	Redeliver(ctx context.Context, webhookID, deliveryID int64) (Delivery, error)
+	//Prune deletes the succeeded and failed deliveries created before before, and the outbox events relayed
+	//before it, returning how many rows it deleted
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"goMS1Assignment/REST/database"
+	"goMS1Assignment/REST/render"
+	"goMS1Assignment/REST/webhook"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	webhooks          webhook.Store
+	webhookDispatcher *webhook.Dispatcher
+)
+
+//registerWebhook subscribes an HTTPS endpoint to some course events. The response holds the secret
+//deliveries are signed with; it is not shown again. Endpoints on loopback, link-local or private addresses are refused.
+func registerWebhook(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r) {
+		return
+	}
+	var hook webhook.Webhook
+	if err := render.Decode(r, &hook); err != nil {
+		if err == render.ErrUnsupportedMediaType {
+			render.Status(w, r, http.StatusUnsupportedMediaType, "Please supply the webhook as application/json or application/yaml")
+			return
+		}
+		render.Status(w, r, http.StatusUnprocessableEntity, "Please supply the webhook URL and Events")
+		return
+	}
+	endpoint, err := url.Parse(hook.URL)
+	if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+		render.Status(w, r, http.StatusUnprocessableEntity, "URL must be an absolute https URL")
+		return
+	}
+	ctx, cancel := queryContext(r)
+	defer cancel()
+	if err := webhook.CheckURL(ctx, endpoint); err != nil {
+		render.Status(w, r, http.StatusUnprocessableEntity, "URL must not point at a loopback, link-local or private address")
+		return
+	}
+	events, ok := webhookEvents(hook.Events)
+	if !ok {
+		render.Status(w, r, http.StatusUnprocessableEntity, "Events must list one or more of course.created, course.updated and course.deleted")
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Error("Error at registerWebhook function, generating secret. ", err.Error())
+		render.Status(w, r, http.StatusInternalServerError, "Could not generate a webhook secret")
+		return
+	}
+	hook = webhook.Webhook{URL: endpoint.String(), Events: events, Secret: hex.EncodeToString(secret)}
+
+	if err := webhooks.CreateWebhook(ctx, &hook); err != nil {
+		databaseError(w, r, err)
+		return
+	}
+	fmt.Println("Webhook", hook.ID, "registered for", hook.URL)
+	render.Write(w, r, http.StatusCreated, hook)
+}
+
+//webhookEvents checks that events only names course lifecycle events, dropping repeats
+func webhookEvents(events []string) ([]string, bool) {
+	var valid []string
+	for _, event := range events {
+		known := false
+		for _, e := range database.Events {
+			known = known || e == event
+		}
+		if !known {
+			return nil, false
+		}
+		repeated := false
+		for _, e := range valid {
+			repeated = repeated || e == event
+		}
+		if !repeated {
+			valid = append(valid, event)
+		}
+	}
+	return valid, len(valid) > 0
+}
+
+//listWebhooks returns every registered webhook without its secret
+func listWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r) {
+		return
+	}
+	ctx, cancel := queryContext(r)
+	defer cancel()
+	hooks, err := webhooks.ListWebhooks(ctx)
+	if err != nil {
+		databaseError(w, r, err)
+		return
+	}
+	if hooks == nil {
+		hooks = []webhook.Webhook{}
+	}
+	render.Write(w, r, http.StatusOK, hooks)
+}
+
+//webhookByID handles GET and DELETE of a single webhook
+func webhookByID(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r) {
+		return
+	}
+	id, ok := idParam(w, r, "webhookid")
+	if !ok {
+		return
+	}
+	ctx, cancel := queryContext(r)
+	defer cancel()
+
+	if r.Method == "DELETE" {
+		err := webhooks.DeleteWebhook(ctx, id)
+		switch {
+		case err == webhook.ErrNotFound:
+			render.Status(w, r, http.StatusNotFound, "No webhook found")
+		case err != nil:
+			databaseError(w, r, err)
+		default:
+			render.Status(w, r, http.StatusAccepted, "Webhook deleted: "+mux.Vars(r)["webhookid"])
+		}
+		return
+	}
+
+	hook, err := webhooks.GetWebhook(ctx, id)
+	switch {
+	case err == webhook.ErrNotFound:
+		render.Status(w, r, http.StatusNotFound, "No webhook found")
+	case err != nil:
+		databaseError(w, r, err)
+	default:
+		hook.Secret = ""
+		render.Write(w, r, http.StatusOK, hook)
+	}
+}
+
+//webhookDeliveries returns the delivery log of a webhook, most recent first
+func webhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r) {
+		return
+	}
+	id, ok := idParam(w, r, "webhookid")
+	if !ok {
+		return
+	}
+	limit, err := limitParam(r, 20)
+	if err != nil {
+		render.Status(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx, cancel := queryContext(r)
+	defer cancel()
+	if _, err := webhooks.GetWebhook(ctx, id); err != nil {
+		if err == webhook.ErrNotFound {
+			render.Status(w, r, http.StatusNotFound, "No webhook found")
+			return
+		}
+		databaseError(w, r, err)
+		return
+	}
+	deliveries, err := webhooks.ListDeliveries(ctx, id, limit)
+	if err != nil {
+		databaseError(w, r, err)
+		return
+	}
+	render.Write(w, r, http.StatusOK, deliveries)
+}
+
+//redeliverWebhook sends a logged delivery again as a new delivery
+func redeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	if !validKey(w, r) {
+		return
+	}
+	id, ok := idParam(w, r, "webhookid")
+	if !ok {
+		return
+	}
+	deliveryID, ok := idParam(w, r, "deliveryid")
+	if !ok {
+		return
+	}
+	ctx, cancel := queryContext(r)
+	defer cancel()
+	delivery, err := webhooks.Redeliver(ctx, id, deliveryID)
+	switch {
+	case err == webhook.ErrNotFound:
+		render.Status(w, r, http.StatusNotFound, "No delivery found")
+	case err != nil:
+		databaseError(w, r, err)
+	default:
+		webhookDispatcher.Notify()
+		render.Write(w, r, http.StatusAccepted, delivery)
+	}
+}
+
+//idParam reads an integer ID from the route, answering 400 if it is not one
+func idParam(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)[name], 10, 64)
+	if err != nil {
+		render.Status(w, r, http.StatusBadRequest, name+" needs to be an integer value")
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//TestRegisterWebhookRefusesPrivateURLs checks webhooks cannot be pointed into the server's own network
+func TestRegisterWebhookRefusesPrivateURLs(t *testing.T) {
+	router := useRepository(t, newMemoryRepository())
+	for _, endpoint := range []string{"https://127.0.0.1/hook", "https://localhost:8443/hook", "https://169.254.169.254/", "https://10.0.0.5/hook", "https://[::1]/hook"} {
+		r := httptest.NewRequest("POST", "/api/v1/webhooks?key=k", strings.NewReader(`{"URL": "`+endpoint+`", "Events": ["course.created"]}`))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "private address") {
+			t.Errorf("register %s: %d %s, want 422", endpoint, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+//ErrCircuitOpen is returned, without sending anything, while the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit open: the API keeps failing, requests are paused for a while")
+
+//Breaker makes requests fail fast while the API is down. After Failures requests in a row fail in transit or
+//get a 5xx reply the circuit opens and requests get ErrCircuitOpen straight away. Once Cooldown has passed one
+//request is let through as a trial: the circuit closes again if it succeeds and stays open for another
+//Cooldown if it fails. A nil *Breaker lets everything through.
+type Breaker struct {
+	Failures int
+	Cooldown time.Duration
+
+	mu       sync.Mutex
+	failed   int       //requests in a row that failed
+	openedAt time.Time //zero while the circuit is closed
+	trial    bool      //a trial request is in flight
+}
+
+//NewBreaker returns a closed breaker
+func NewBreaker(failures int, cooldown time.Duration) *Breaker {
+	return &Breaker{Failures: failures, Cooldown: cooldown}
+}
+
+//Open reports whether requests are currently failing fast
+func (b *Breaker) Open() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.openedAt.IsZero()
+}
+
+//allow returns ErrCircuitOpen if a request must not be sent now
+func (b *Breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openedAt.IsZero() {
+		return nil
+	}
+	if b.trial || time.Since(b.openedAt) < b.Cooldown {
+		return ErrCircuitOpen
+	}
+	b.trial = true
+	return nil
+}
+
+//success records a request the server answered without a 5xx, which closes the circuit
+func (b *Breaker) success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failed, b.openedAt, b.trial = 0, time.Time{}, false
+}
+
+//failure records a request that failed in transit or got a 5xx reply
+func (b *Breaker) failure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failed++
+	if b.trial || (b.Failures > 0 && b.failed >= b.Failures) {
+		b.openedAt = time.Now()
+	}
+	b.trial = false
+}
+
+//abandon records a request the caller gave up on, which says nothing about the API
+func (b *Breaker) abandon() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+//TestBreakerTransitions walks the breaker from closed to open, through a trial request and back
+func TestBreakerTransitions(t *testing.T) {
+	cooldown := 30 * time.Millisecond
+	b := NewBreaker(3, cooldown)
+
+	//failures that are not in a row do not open it
+	b.failure()
+	b.failure()
+	b.success()
+	b.failure()
+	b.failure()
+	if b.Open() || b.allow() != nil {
+		t.Fatal("opened before 3 failures in a row")
+	}
+	b.failure()
+	if !b.Open() {
+		t.Fatal("still closed after 3 failures in a row")
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allowed a request during the cooldown: %v", err)
+	}
+
+	//after the cooldown one trial goes through, and only one
+	time.Sleep(cooldown)
+	if err := b.allow(); err != nil {
+		t.Fatalf("trial refused after the cooldown: %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second request allowed while the trial is in flight: %v", err)
+	}
+
+	//a failed trial opens it for another cooldown straight away
+	b.failure()
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allowed a request right after the trial failed: %v", err)
+	}
+
+	//a trial the caller gave up on lets another trial through
+	time.Sleep(cooldown)
+	if err := b.allow(); err != nil {
+		t.Fatalf("trial refused: %v", err)
+	}
+	b.abandon()
+	if err := b.allow(); err != nil {
+		t.Fatalf("trial refused after the previous one was abandoned: %v", err)
+	}
+
+	//a successful trial closes it
+	b.success()
+	if b.Open() {
+		t.Fatal("still open after a successful trial")
+	}
+	for i := 0; i < 3; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("request %d refused after the circuit closed: %v", i, err)
+		}
+	}
+	//and the count starts over
+	b.failure()
+	b.failure()
+	if b.Open() {
+		t.Fatal("opened on failures counted before it closed")
+	}
+}
+
+//TestNilBreaker checks a nil breaker lets everything through
+func TestNilBreaker(t *testing.T) {
+	var b *Breaker
+	for i := 0; i < 10; i++ {
+		b.failure()
+	}
+	if b.Open() || b.allow() != nil {
+		t.Error("nil breaker refused a request")
+	}
+	b.success()
+	b.abandon()
+}
@@ -0,0 +1,255 @@
+//Package client is a Go client for the course REST API. A Client carries the base URL, access key, TLS settings,
+//timeout and retry policy; its methods take a context and return courses and typed errors instead of raw bodies.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+//CourseInfo is a course as the API sends and receives it
+type CourseInfo struct {
+	Code        int    `json:"Code"`
+	Title       string `json:"Title"`
+	Dates       string `json:"Dates"`
+	Lecturer    string `json:"Lecturer"`
+	Description string `json:"Description"`
+}
+
+//Client talks to one course API server. Change its fields before first use.
+type Client struct {
+	BaseURL string //scheme and host of the server, such as https://localhost:5000
+	APIKey  string
+	HTTP    *http.Client
+	//Timeout bounds each attempt of a request, including reading the reply. Watch streams are not bounded.
+	Timeout time.Duration
+	//Retries is how many more times a request that failed in transit, timed out or got 502, 503 or 504 is sent.
+	//Only requests that are safe to repeat are retried: GET, PUT, DELETE and POST with an Idempotency-Key.
+	Retries int
+	//RetryBackoff is the wait before the first retry, doubled for each one after up to MaxRetryBackoff.
+	//Up to a fifth is added at random, so clients that failed together do not all retry at once.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	//Breaker fails requests fast while the API is down; nil turns it off
+	Breaker *Breaker
+
+	mu      sync.Mutex
+	fetched map[string]cachedResponse //last reply per URL, revalidated with conditional requests
+}
+
+//cachedResponse is a previously fetched body together with the validators the server sent for it
+type cachedResponse struct {
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+//New returns a client for the server at baseURL that verifies it with tlsConfig
+func New(baseURL, apiKey string, tlsConfig *tls.Config) *Client {
+	return &Client{
+		BaseURL:         strings.TrimRight(baseURL, "/"),
+		APIKey:          apiKey,
+		HTTP:            &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}},
+		Timeout:         30 * time.Second,
+		Retries:         2,
+		RetryBackoff:    500 * time.Millisecond,
+		MaxRetryBackoff: 10 * time.Second,
+		Breaker:         NewBreaker(5, 30*time.Second),
+		fetched:         make(map[string]cachedResponse),
+	}
+}
+
+//APIError is a reply with an error status. It matches the Err values below with errors.Is by status code.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+//Is reports whether target is an APIError with the same status code
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.StatusCode == e.StatusCode
+}
+
+//Errors to compare with errors.Is. The server also answers 404 for a missing or wrong access key.
+var (
+	ErrBadRequest    = &APIError{StatusCode: http.StatusBadRequest}
+	ErrNotFound      = &APIError{StatusCode: http.StatusNotFound}
+	ErrConflict      = &APIError{StatusCode: http.StatusConflict}
+	ErrUnprocessable = &APIError{StatusCode: http.StatusUnprocessableEntity}
+)
+
+//request describes one API call
+type request struct {
+	method string
+	path   string //below /api/v1
+	query  url.Values
+	body   interface{} //sent as JSON when set
+	header http.Header
+}
+
+//reply is a response read in full, so the attempt's timeout can end before it is used
+type reply struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+//url returns the address of path below /api/v1 with the access key and query parameters
+func (c *Client) url(path string, query url.Values) string {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("key", c.APIKey)
+	return c.BaseURL + "/api/v1" + path + "?" + q.Encode()
+}
+
+//retryable reports whether sending req twice does no more than sending it once
+func (r request) retryable() bool {
+	switch r.method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return r.header.Get("Idempotency-Key") != ""
+	}
+	return false
+}
+
+//send makes the call, retrying it when that is safe, and returns an *APIError for statuses of 400 and above
+func (c *Client) send(ctx context.Context, r request) (reply, error) {
+	var body []byte
+	if r.body != nil {
+		var err error
+		if body, err = json.Marshal(r.body); err != nil {
+			return reply{}, err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, r, body)
+		retry := err != nil || resp.status == http.StatusBadGateway || resp.status == http.StatusServiceUnavailable || resp.status == http.StatusGatewayTimeout
+		if !retry || attempt >= c.Retries || !r.retryable() || ctx.Err() != nil || errors.Is(err, ErrCircuitOpen) {
+			if err != nil {
+				return resp, err
+			}
+			if resp.status >= 400 {
+				return resp, apiError(resp)
+			}
+			return resp, nil
+		}
+		select {
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		case <-time.After(c.backoff(attempt)):
+		}
+	}
+}
+
+//backoff returns the wait before retry number attempt+1
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.RetryBackoff
+	for i := 0; i < attempt && (c.MaxRetryBackoff <= 0 || wait < c.MaxRetryBackoff); i++ {
+		wait *= 2
+	}
+	if c.MaxRetryBackoff > 0 && wait > c.MaxRetryBackoff {
+		wait = c.MaxRetryBackoff
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait)/5+1))
+}
+
+//attempt sends the request once, within the Timeout, and tells the breaker how it went
+func (c *Client) attempt(ctx context.Context, r request, body []byte) (reply, error) {
+	if err := c.Breaker.allow(); err != nil {
+		return reply{}, err
+	}
+	resp, err := c.exchange(ctx, r, body)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		c.Breaker.abandon()
+	case err != nil || resp.status >= 500:
+		c.Breaker.failure()
+	default:
+		c.Breaker.success()
+	}
+	return resp, err
+}
+
+func (c *Client) exchange(ctx context.Context, r request, body []byte) (reply, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, r.method, c.url(r.path, r.query), bytes.NewReader(body))
+	if err != nil {
+		return reply{}, err
+	}
+	for k, v := range r.header {
+		httpRequest.Header[k] = v
+	}
+	httpRequest.Header.Set("Accept", "application/json")
+	if body != nil {
+		httpRequest.Header.Set("Content-Type", "application/json")
+	}
+	response, err := c.HTTP.Do(httpRequest)
+	if err != nil {
+		return reply{}, redact(err)
+	}
+	defer response.Body.Close()
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return reply{}, err
+	}
+	return reply{status: response.StatusCode, header: response.Header, body: data}, nil
+}
+
+//redact removes the access key from the URL in a transport error, so the error can be shown and logged
+func redact(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+			q := u.Query()
+			q.Del("key")
+			u.RawQuery = q.Encode()
+			urlErr.URL = u.String()
+		}
+	}
+	return err
+}
+
+//apiError reads the server's status message from an error reply
+func apiError(resp reply) error {
+	var message struct {
+		Message string `json:"Message"`
+	}
+	if json.Unmarshal(resp.body, &message) != nil || message.Message == "" {
+		message.Message = strings.TrimSpace(string(resp.body))
+	}
+	return &APIError{StatusCode: resp.status, Message: message.Message}
+}
+
+//decode reads a JSON reply into v
+func decode(resp reply, v interface{}) error {
+	if err := json.Unmarshal(resp.body, v); err != nil {
+		return errors.New("reading reply: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//failingServer answers the first failures requests with status and the rest with 200, counting them all
+func failingServer(t *testing.T, failures int32, status int) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func testClient(baseURL string) *Client {
+	c := New(baseURL, "k", nil)
+	c.Retries = 3
+	c.RetryBackoff = time.Millisecond
+	c.Breaker = nil
+	return c
+}
+
+//TestRetryOnlyWhenSafe checks which requests are sent again after a 503, in particular that a POST is only
+//repeated when it carries an Idempotency-Key
+func TestRetryOnlyWhenSafe(t *testing.T) {
+	withKey := http.Header{}
+	withKey.Set("Idempotency-Key", "abc")
+	tests := []struct {
+		name      string
+		request   request
+		wantCalls int32
+		wantErr   bool
+	}{
+		{"GET", request{method: http.MethodGet, path: "/courses"}, 3, false},
+		{"PUT", request{method: http.MethodPut, path: "/courses/1", body: CourseInfo{Code: 1}}, 3, false},
+		{"DELETE", request{method: http.MethodDelete, path: "/courses/1"}, 3, false},
+		{"POST with Idempotency-Key", request{method: http.MethodPost, path: "/courses/1", body: CourseInfo{Code: 1}, header: withKey}, 3, false},
+		{"POST without Idempotency-Key", request{method: http.MethodPost, path: "/courses/1", body: CourseInfo{Code: 1}}, 1, true},
+		{"PATCH", request{method: http.MethodPatch, path: "/courses/1"}, 1, true},
+	}
+	for _, tt := range tests {
+		srv, calls := failingServer(t, 2, http.StatusServiceUnavailable)
+		_, err := testClient(srv.URL).send(context.Background(), tt.request)
+		if got := atomic.LoadInt32(calls); got != tt.wantCalls {
+			t.Errorf("%s: sent %d times, want %d", tt.name, got, tt.wantCalls)
+		}
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+		if tt.wantErr && !errors.Is(err, &APIError{StatusCode: http.StatusServiceUnavailable}) {
+			t.Errorf("%s: error %v, want the 503", tt.name, err)
+		}
+	}
+}
+
+//TestRetryStatuses checks only gateway errors and failures in transit are retried, and that Retries bounds the attempts
+func TestRetryStatuses(t *testing.T) {
+	tests := []struct {
+		status    int
+		wantCalls int32
+	}{
+		{http.StatusBadGateway, 3},
+		{http.StatusServiceUnavailable, 3},
+		{http.StatusGatewayTimeout, 3},
+		{http.StatusInternalServerError, 1},
+		{http.StatusConflict, 1},
+		{http.StatusNotFound, 1},
+	}
+	for _, tt := range tests {
+		srv, calls := failingServer(t, 2, tt.status)
+		testClient(srv.URL).send(context.Background(), request{method: http.MethodGet, path: "/courses"})
+		if got := atomic.LoadInt32(calls); got != tt.wantCalls {
+			t.Errorf("%d: sent %d times, want %d", tt.status, got, tt.wantCalls)
+		}
+	}
+
+	srv, calls := failingServer(t, 10, http.StatusServiceUnavailable)
+	c := testClient(srv.URL)
+	c.Retries = 2
+	if _, err := c.send(context.Background(), request{method: http.MethodGet, path: "/courses"}); err == nil {
+		t.Error("no error after every attempt failed")
+	}
+	if got := atomic.LoadInt32(calls); got != 3 {
+		t.Errorf("sent %d times with 2 retries, want 3", got)
+	}
+}
+
+//TestBackoffBounds checks each wait doubles up to MaxRetryBackoff, with at most a fifth added as jitter
+func TestBackoffBounds(t *testing.T) {
+	c := &Client{RetryBackoff: 100 * time.Millisecond, MaxRetryBackoff: time.Second}
+	bases := []time.Duration{100, 200, 400, 800, 1000, 1000, 1000}
+	for attempt, base := range bases {
+		base *= time.Millisecond
+		for i := 0; i < 200; i++ {
+			wait := c.backoff(attempt)
+			if wait < base || wait > base+base/5 {
+				t.Fatalf("attempt %d waits %v, want between %v and %v", attempt, wait, base, base+base/5)
+			}
+		}
+	}
+
+	//without a maximum the wait keeps doubling
+	c.MaxRetryBackoff = 0
+	if wait := c.backoff(5); wait < 3200*time.Millisecond {
+		t.Errorf("attempt 5 without a maximum waits %v, want at least 3.2s", wait)
+	}
+}
+
+//TestBreakerStopsRequests checks an open circuit fails requests without sending them, and closes after a good trial
+func TestBreakerStopsRequests(t *testing.T) {
+	srv, calls := failingServer(t, 2, http.StatusInternalServerError)
+	c := testClient(srv.URL)
+	cooldown := 30 * time.Millisecond
+	c.Breaker = NewBreaker(2, cooldown)
+	get := func() error {
+		_, err := c.send(context.Background(), request{method: http.MethodGet, path: "/courses"})
+		return err
+	}
+
+	get()
+	get()
+	if err := get(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("third request: %v, want ErrCircuitOpen", err)
+	}
+	if got := atomic.LoadInt32(calls); got != 2 {
+		t.Fatalf("%d requests reached the server, want 2", got)
+	}
+
+	time.Sleep(cooldown)
+	if err := get(); err != nil {
+		t.Fatalf("trial after the cooldown: %v", err)
+	}
+	if c.Breaker.Open() {
+		t.Fatal("circuit still open after a successful trial")
+	}
+	if err := get(); err != nil {
+		t.Errorf("request after the circuit closed: %v", err)
+	}
+}
+
+//TestTimeoutPerAttempt checks Timeout bounds each attempt and that a timed out attempt is retried
+func TestTimeoutPerAttempt(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	c := testClient(srv.URL)
+	c.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	if _, err := c.send(context.Background(), request{method: http.MethodGet, path: "/courses"}); err != nil {
+		t.Fatalf("retry after a timed out attempt failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("took %v, the first attempt was not cut off", elapsed)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("sent %d times, want 2", got)
+	}
+}
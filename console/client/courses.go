@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+//Filter narrows down a listing to courses whose fields contain the given text; empty fields match everything
+type Filter struct {
+	Lecturer string
+	Title    string
+}
+
+func (f Filter) query() url.Values {
+	q := url.Values{}
+	if f.Lecturer != "" {
+		q.Set("lecturer", f.Lecturer)
+	}
+	if f.Title != "" {
+		q.Set("title", f.Title)
+	}
+	return q
+}
+
+//SearchResult is one search match, with the matching text of each field and the matches marked by <mark>
+type SearchResult struct {
+	Course   CourseInfo        `json:"Course"`
+	Score    float64           `json:"Score"`
+	Snippets map[string]string `json:"Snippets"`
+}
+
+//getCached fetches path, answering from the last reply when the server says it has not changed, and returns its ETag
+func (c *Client) getCached(ctx context.Context, path string, query url.Values, v interface{}) (string, error) {
+	key := c.url(path, query)
+	c.mu.Lock()
+	cached, ok := c.fetched[key]
+	c.mu.Unlock()
+
+	header := http.Header{}
+	if ok {
+		if cached.etag != "" {
+			header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: path, query: query, header: header})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		delete(c.fetched, key)
+		return "", err
+	}
+	if resp.status == http.StatusNotModified && ok {
+		resp.body = cached.body
+	} else {
+		cached = cachedResponse{
+			etag:         resp.header.Get("ETag"),
+			lastModified: resp.header.Get("Last-Modified"),
+			body:         resp.body,
+		}
+		c.fetched[key] = cached
+	}
+	return cached.etag, decode(resp, v)
+}
+
+//Get returns one course. A course that does not exist is reported as ErrNotFound.
+func (c *Client) Get(ctx context.Context, code int) (CourseInfo, error) {
+	course, _, err := c.GetWithETag(ctx, code)
+	return course, err
+}
+
+//GetWithETag returns one course and the ETag of the version the server sent, which changes whenever the course does
+func (c *Client) GetWithETag(ctx context.Context, code int) (CourseInfo, string, error) {
+	var course CourseInfo
+	etag, err := c.getCached(ctx, "/courses/"+strconv.Itoa(code), nil, &course)
+	return course, etag, err
+}
+
+//List returns the courses matching filter in code order, all in one reply. Use Courses to page through them.
+func (c *Client) List(ctx context.Context, filter Filter) ([]CourseInfo, error) {
+	var byCode map[int]CourseInfo
+	if _, err := c.getCached(ctx, "/courses", filter.query(), &byCode); err != nil {
+		return nil, err
+	}
+	courses := make([]CourseInfo, 0, len(byCode))
+	for _, course := range byCode {
+		courses = append(courses, course)
+	}
+	sort.Slice(courses, func(i, j int) bool { return courses[i].Code < courses[j].Code })
+	return courses, nil
+}
+
+//Search returns up to limit courses matching query, best match first. fuzzy tolerates typos in titles and lecturers.
+func (c *Client) Search(ctx context.Context, query string, limit int, fuzzy bool) ([]SearchResult, error) {
+	q := url.Values{"q": {query}, "limit": {strconv.Itoa(limit)}}
+	if fuzzy {
+		q.Set("fuzzy", "true")
+	}
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/courses/search", query: q})
+	if err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	return results, decode(resp, &results)
+}
+
+//Create adds a course with every field set. It is sent with an Idempotency-Key, so a retry after a lost reply
+//does not create it twice. An existing course is reported as ErrConflict.
+func (c *Client) Create(ctx context.Context, course CourseInfo) error {
+	return c.CreateWithKey(ctx, course, NewIdempotencyKey())
+}
+
+//CreateWithKey is Create with the caller's Idempotency-Key, for a create that may be sent again later,
+//such as one queued while offline. Sending it again with the same key answers like the first time.
+func (c *Client) CreateWithKey(ctx context.Context, course CourseInfo, idempotencyKey string) error {
+	header := http.Header{}
+	header.Set("Idempotency-Key", idempotencyKey)
+	_, err := c.send(ctx, request{method: http.MethodPost, path: "/courses/" + strconv.Itoa(course.Code), body: course, header: header})
+	return err
+}
+
+//Update changes a course; fields left empty keep their value. A course that does not exist yet is created,
+//which needs every field, and created reports whether that happened.
+func (c *Client) Update(ctx context.Context, course CourseInfo) (created bool, err error) {
+	resp, err := c.send(ctx, request{method: http.MethodPut, path: "/courses/" + strconv.Itoa(course.Code), body: course})
+	return resp.status == http.StatusCreated, err
+}
+
+//Delete removes a course. A course that does not exist is reported as ErrNotFound.
+func (c *Client) Delete(ctx context.Context, code int) error {
+	_, err := c.send(ctx, request{method: http.MethodDelete, path: "/courses/" + strconv.Itoa(code)})
+	return err
+}
+
+//NewIdempotencyKey returns a random key identifying one logical write across retries
+func NewIdempotencyKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
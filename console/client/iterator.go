@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+//coursesQuery fetches one page of courses through the GraphQL endpoint, which pages by offset and limit
+const coursesQuery = `query($filter: CourseFilter, $offset: Int, $limit: Int) {
+  courses(filter: $filter, offset: $offset, limit: $limit) {
+    total hasMore items { code title dates lecturer description }
+  }
+}`
+
+//CourseIterator pages through the courses matching a filter in code order:
+//
+//	it := c.Courses(ctx, client.Filter{}, 50)
+//	for it.Next() {
+//		course := it.Course()
+//	}
+//	if err := it.Err(); err != nil {
+//
+//Courses added or removed while iterating can shift later pages by as many places.
+type CourseIterator struct {
+	client   *Client
+	ctx      context.Context
+	filter   Filter
+	pageSize int
+
+	page    []CourseInfo
+	index   int //of the current course in page
+	offset  int //of the first course of the next page
+	total   int
+	hasMore bool
+	err     error
+}
+
+//Courses returns an iterator over the courses matching filter, fetched pageSize at a time (at most 100)
+func (c *Client) Courses(ctx context.Context, filter Filter, pageSize int) *CourseIterator {
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 100
+	}
+	return &CourseIterator{client: c, ctx: ctx, filter: filter, pageSize: pageSize, index: -1, hasMore: true}
+}
+
+//Next moves to the next course, fetching the next page when needed. It returns false at the end or on error.
+func (it *CourseIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.index+1 < len(it.page) {
+		it.index++
+		return true
+	}
+	if !it.hasMore {
+		return false
+	}
+	if it.err = it.fetch(); it.err != nil || len(it.page) == 0 {
+		return false
+	}
+	it.index = 0
+	return true
+}
+
+//Course is the course Next moved to
+func (it *CourseIterator) Course() CourseInfo {
+	return it.page[it.index]
+}
+
+//Total is how many courses matched when the last page was fetched
+func (it *CourseIterator) Total() int {
+	return it.total
+}
+
+//Err is the error that ended the iteration, if any
+func (it *CourseIterator) Err() error {
+	return it.err
+}
+
+func (it *CourseIterator) fetch() error {
+	variables, _ := json.Marshal(map[string]interface{}{
+		"filter": map[string]string{"lecturer": it.filter.Lecturer, "title": it.filter.Title},
+		"offset": it.offset,
+		"limit":  it.pageSize,
+	})
+	query := url.Values{"query": {coursesQuery}, "variables": {string(variables)}}
+	resp, err := it.client.send(it.ctx, request{method: http.MethodGet, path: "/graphql", query: query})
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Data struct {
+			Courses struct {
+				Total   int          `json:"total"`
+				HasMore bool         `json:"hasMore"`
+				Items   []CourseInfo `json:"items"`
+			} `json:"courses"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := decode(resp, &result); err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		return errors.New("listing courses: " + result.Errors[0].Message)
+	}
+	courses := result.Data.Courses
+	it.page, it.total, it.hasMore = courses.Items, courses.Total, courses.HasMore
+	it.offset += len(courses.Items)
+	return nil
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+//ImportOptions choose how an import is run
+type ImportOptions struct {
+	//BestEffort writes each valid course on its own; otherwise all are written in one transaction or none are
+	BestEffort bool
+	//DryRun only validates the courses and reports what would happen
+	DryRun bool
+}
+
+//ImportRow reports what happened to one course of an import
+type ImportRow struct {
+	Row    int    `json:"Row"` //1-based
+	Code   int    `json:"Code"`
+	Status string `json:"Status"` //valid (dry run), imported, failed or skipped
+	Error  string `json:"Error"`
+}
+
+//ImportReport is the server's answer to an import
+type ImportReport struct {
+	Mode     string      `json:"Mode"`
+	DryRun   bool        `json:"DryRun"`
+	Total    int         `json:"Total"`
+	Imported int         `json:"Imported"`
+	Failed   int         `json:"Failed"`
+	Rows     []ImportRow `json:"Rows"`
+}
+
+//Import creates many courses at once. When some of them are rejected the report is returned together with
+//an ErrUnprocessable error, or with no error if the others were imported; check Failed in that case.
+func (c *Client) Import(ctx context.Context, courses []CourseInfo, options ImportOptions) (ImportReport, error) {
+	query := url.Values{}
+	if options.BestEffort {
+		query.Set("mode", "best-effort")
+	}
+	if options.DryRun {
+		query.Set("dry_run", "true")
+	}
+	header := http.Header{}
+	header.Set("Idempotency-Key", NewIdempotencyKey())
+	resp, err := c.send(ctx, request{method: http.MethodPost, path: "/courses/import", query: query, body: courses, header: header})
+
+	var report ImportReport
+	if err != nil && !errors.Is(err, ErrUnprocessable) {
+		return report, err
+	}
+	//a 422 carries the report too, unless the request itself was malformed
+	if decodeErr := decode(resp, &report); decodeErr != nil && err == nil {
+		return report, decodeErr
+	}
+	return report, err
+}
+
+//Export writes the courses matching filter to w as csv, excel (CSV for spreadsheet programs) or ndjson.
+//The file is read in full before anything is written, so a failed export leaves w untouched.
+func (c *Client) Export(ctx context.Context, w io.Writer, format string, filter Filter) error {
+	query := filter.query()
+	query.Set("format", format)
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/courses/export", query: query})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(resp.body); err != nil {
+		return errors.New("writing export: " + err.Error())
+	}
+	return nil
+}
+
+//String describes the row on one line
+func (r ImportRow) String() string {
+	s := "row " + strconv.Itoa(r.Row) + " (code " + strconv.Itoa(r.Code) + "): " + r.Status
+	if r.Error != "" {
+		s += ", " + r.Error
+	}
+	return s
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Event types passed to a Watch function
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted" //Course is the course as it was
+	//EventReset means some changes were missed, for example after a server restart; reload the courses
+	EventReset = "reset"
+	//EventReconnect means the stream was lost and is being reopened; no changes are missed unless a reset follows
+	EventReconnect = "reconnect"
+)
+
+//Event is one change from the server's event stream
+type Event struct {
+	ID     string
+	Type   string
+	Course CourseInfo
+}
+
+//Watch calls fn with every course change until ctx is done, and then returns ctx.Err().
+//A lost stream is reopened from the last event seen, after the delay the server asks for.
+//It only returns early, with an *APIError, if the server refuses the stream.
+func (c *Client) Watch(ctx context.Context, fn func(Event)) error {
+	lastEventID := ""
+	delay := 3 * time.Second
+	for {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url("/courses/events", nil), nil)
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Accept", "text/event-stream")
+		if lastEventID != "" {
+			request.Header.Set("Last-Event-ID", lastEventID)
+		}
+		response, err := c.HTTP.Do(request)
+		if err == nil {
+			if response.StatusCode != http.StatusOK {
+				data, _ := ioutil.ReadAll(response.Body)
+				response.Body.Close()
+				return apiError(reply{status: response.StatusCode, body: data})
+			}
+			lastEventID, delay = readEvents(response.Body, lastEventID, delay, fn)
+			response.Body.Close()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fn(Event{ID: lastEventID, Type: EventReconnect})
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+//readEvents passes each Server-Sent Event in body to fn and returns the ID of the last one and the reconnect delay
+func readEvents(body io.Reader, lastEventID string, delay time.Duration, fn func(Event)) (string, time.Duration) {
+	var event, data string
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id:"):
+			lastEventID = strings.TrimSpace(line[3:])
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(line[6:])
+		case strings.HasPrefix(line, "data:"):
+			data = strings.TrimSpace(line[5:])
+		case strings.HasPrefix(line, "retry:"):
+			if ms, err := strconv.Atoi(strings.TrimSpace(line[6:])); err == nil {
+				delay = time.Duration(ms) * time.Millisecond
+			}
+		case line == "" && event != "":
+			e := Event{ID: lastEventID, Type: event}
+			if event != EventReset {
+				json.Unmarshal([]byte(data), &e.Course)
+			}
+			fn(e)
+			event, data = "", ""
+		}
+	}
+	return lastEventID, delay
+}
@@ -0,0 +1,625 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"goMS1Assignment/console/client"
+	"goMS1Assignment/console/config"
+	"goMS1Assignment/console/offline"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/term"
+)
+
+//Exit statuses of the commands, so scripts can tell what went wrong
+const (
+	exitOK          = 0
+	exitFailed      = 1 //the server could not be reached, or another error
+	exitUsage       = 2 //wrong command, flags or input
+	exitNotFound    = 3 //404
+	exitConflict    = 4 //409
+	exitRejected    = 5 //400, 422 and other 4xx, and imports with rows that failed
+	exitServerError = 6 //5xx
+	exitQueued      = 7 //the server could not be reached, so the edit is queued until it can
+)
+
+//command is one subcommand of the console
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) int
+}
+
+var commands = []command{
+	{"list", "list courses, optionally filtered by -title and -lecturer", listCommand},
+	{"get", "show one course: get <code>", getCommand},
+	{"create", "add a course from flags or a JSON file", createCommand},
+	{"update", "change a course from flags or a JSON file; fields left out keep their value", updateCommand},
+	{"delete", "delete a course: delete <code>", deleteCommand},
+	{"import", "add many courses from a JSON array", importCommand},
+	{"export", "download the courses as csv, excel or ndjson", exportCommand},
+	{"tui", "browse, search and edit courses full screen", tuiCommand},
+	{"shell", "start the interactive menu", shellCommand},
+	{"sync", "send the edits made offline", syncCommand},
+	{"outbox", "show the edits made offline that are not sent yet", outboxCommand},
+	{"login", "save the access key of the chosen profile in the credentials file", loginCommand},
+}
+
+//runCommand runs the command named by the first argument and returns the exit status
+func runCommand(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return exitUsage
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(os.Stdout)
+		return exitOK
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(context.Background(), args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+	usage(os.Stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: courses [settings] <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-7s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Settings (-profile, -config, -api-key, -base-url, -ca-file and others) go before the command.")
+	fmt.Fprintln(w, `Run "courses <command> -h" for the flags of a command.`)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Exit status: 0 success, 1 request failed, 2 usage error, 3 not found, 4 conflict,")
+	fmt.Fprintln(w, "5 rejected by the server, 6 server error, 7 server unreachable and the edit queued offline.")
+}
+
+//newFlagSet returns a flag set for a command that reports its errors instead of exiting
+func newFlagSet(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: courses %s %s\n", name, arguments)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+//parseFlags parses a command's flags. It returns false, with the exit status, if the command should not run.
+func parseFlags(fs *flag.FlagSet, args []string) (bool, int) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return false, exitOK
+		}
+		return false, exitUsage
+	}
+	return true, exitOK
+}
+
+//usageError reports wrong input to a command
+func usageError(fs *flag.FlagSet, err error) int {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", fs.Name(), err)
+	return exitUsage
+}
+
+//exitStatus reports the error of a request, if any, and returns the exit status for it
+func exitStatus(function string, err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var apiErr *client.APIError
+	if !errors.As(err, &apiErr) {
+		log.Error("Error at "+function+" command, ", err.Error())
+		return exitFailed
+	}
+	fmt.Fprintln(os.Stderr, apiErr)
+	switch {
+	case apiErr.StatusCode == http.StatusNotFound:
+		return exitNotFound
+	case apiErr.StatusCode == http.StatusConflict:
+		return exitConflict
+	case apiErr.StatusCode >= 500:
+		return exitServerError
+	}
+	return exitRejected
+}
+
+//codeArgument reads a course code given either as the -code flag or as the only argument
+func codeArgument(fs *flag.FlagSet, code int) (int, error) {
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		if !codeRegExp.MatchString(fs.Arg(0)) {
+			return 0, fmt.Errorf("course code %q is not a number", fs.Arg(0))
+		}
+		code, _ = strconv.Atoi(fs.Arg(0))
+	default:
+		return 0, errors.New("too many arguments")
+	}
+	if code <= 0 {
+		return 0, errors.New("a course code is required")
+	}
+	return code, nil
+}
+
+//readJSON decodes the JSON in a file, or in standard input when path is -, into v
+func readJSON(path string, v interface{}) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	return nil
+}
+
+//courseFlags adds a flag for every course field and for a JSON file to start from
+func courseFlags(fs *flag.FlagSet) (*client.CourseInfo, *string) {
+	course := &client.CourseInfo{}
+	fs.IntVar(&course.Code, "code", 0, "course code")
+	fs.StringVar(&course.Title, "title", "", "course title")
+	fs.StringVar(&course.Dates, "dates", "", "course dates")
+	fs.StringVar(&course.Lecturer, "lecturer", "", "lecturer name")
+	fs.StringVar(&course.Description, "description", "", "course description")
+	file := fs.String("file", "", "JSON `file` with the course, or - for standard input; flags override its fields")
+	return course, file
+}
+
+//courseInput builds the course from the JSON file, if any, with the fields given as flags on top
+func courseInput(fs *flag.FlagSet, flags *client.CourseInfo, file string) (client.CourseInfo, error) {
+	var course client.CourseInfo
+	if fs.NArg() > 0 {
+		return course, errors.New("unexpected argument " + fs.Arg(0))
+	}
+	if file != "" {
+		if err := readJSON(file, &course); err != nil {
+			return course, err
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "code":
+			course.Code = flags.Code
+		case "title":
+			course.Title = flags.Title
+		case "dates":
+			course.Dates = flags.Dates
+		case "lecturer":
+			course.Lecturer = flags.Lecturer
+		case "description":
+			course.Description = flags.Description
+		}
+	})
+	return course, nil
+}
+
+//checkCourse validates a course like the interactive menu does and sanitizes its fields.
+//Every field is required unless partial is set, in which case empty fields are left alone.
+func checkCourse(course *client.CourseInfo, partial bool) error {
+	var problems []string
+	if course.Code <= 0 {
+		problems = append(problems, "a course code is required")
+	}
+	fields := []struct {
+		name  string
+		value *string
+	}{
+		{"title", &course.Title},
+		{"dates", &course.Dates},
+		{"lecturer", &course.Lecturer},
+		{"description", &course.Description},
+	}
+	for _, f := range fields {
+		switch {
+		case *f.value == "" && !partial:
+			problems = append(problems, f.name+" is required")
+		case *f.value != "" && !detailRegExp.MatchString(*f.value):
+			problems = append(problems, f.name+" is in the wrong format")
+		default:
+			*f.value = pol.Sanitize(*f.value)
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func listCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("list", "[-title text] [-lecturer text] [-output format] [-columns list] [-sort list]")
+	var filter client.Filter
+	fs.StringVar(&filter.Title, "title", "", "only courses whose title contains this text")
+	fs.StringVar(&filter.Lecturer, "lecturer", "", "only courses whose lecturer contains this text")
+	output := outputFlags(fs)
+	if ok, status := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, errors.New("unexpected argument "+fs.Arg(0)))
+	}
+	if err := output.check(); err != nil {
+		return usageError(fs, err)
+	}
+	courses, freshness, err := courseAPI.List(ctx, filter)
+	if err != nil {
+		return exitStatus("list", err)
+	}
+	staleWarning(freshness)
+	return printCourses(output, courses, false)
+}
+
+func getCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("get", "[-output format] [-columns list] <code>")
+	code := fs.Int("code", 0, "course code")
+	output := outputFlags(fs)
+	if ok, status := parseFlags(fs, args); !ok {
+		return status
+	}
+	n, err := codeArgument(fs, *code)
+	if err == nil {
+		err = output.check()
+	}
+	if err != nil {
+		return usageError(fs, err)
+	}
+	course, freshness, err := courseAPI.Get(ctx, n)
+	if err != nil {
+		return exitStatus("get", err)
+	}
+	staleWarning(freshness)
+	return printCourses(output, []client.CourseInfo{course}, true)
+}
+
+//printCourses writes courses to standard output in the chosen format
+func printCourses(output *outputOptions, courses []client.CourseInfo, single bool) int {
+	if err := output.write(os.Stdout, courses, single); err != nil {
+		log.Error("Error writing output, ", err.Error())
+		return exitFailed
+	}
+	return exitOK
+}
+
+func createCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("create", "-code n -title text -dates text -lecturer text -description text | -file path")
+	flags, file := courseFlags(fs)
+	if ok, status := parseFlags(fs, args); !ok {
+		return status
+	}
+	course, err := courseInput(fs, flags, *file)
+	if err == nil {
+		err = checkCourse(&course, false)
+	}
+	if err != nil {
+		return usageError(fs, err)
+	}
+	queued, err := courseAPI.Create(ctx, course)
+	if err != nil {
+		return exitStatus("create", err)
+	}
+	if queued {
+		return queuedNotice("added", course.Code)
+	}
+	fmt.Println("Course added:", course.Code)
+	return exitOK
+}
+
+func updateCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("update", "-code n [-title text] [-dates text] [-lecturer text] [-description text] | -file path")
+	flags, file := courseFlags(fs)
+	if ok, status := parseFlags(fs, args); !ok {
+		return status
+	}
+	course, err := courseInput(fs, flags, *file)
+	if err == nil {
+		err = checkCourse(&course, true)
+	}
+	if err != nil {
+		return usageError(fs, err)
+	}
+	created, queued, err := courseAPI.Update(ctx, course)
+	if err != nil {
+		return exitStatus("update", err)
+	}
+	if queued {
+		return queuedNotice("updated", course.Code)
+	}
+	if created {
+		fmt.Println("Course added:", course.Code)
+	} else {
+		fmt.Println("Course updated:", course.Code)
+	}
+	return exitOK
+}
+
+func deleteCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("delete", "<code>")
+	code := fs.Int("code", 0, "course code")
+	if ok, status := parseFlags(fs, args); !ok {
+		return status
+	}
+	n, err := codeArgument(fs, *code)
+	if err != nil {
+		return usageError(fs, err)
+	}
+	queued, err := courseAPI.Delete(ctx, n)
+	if err != nil {
+		return exitStatus("delete", err)
+	}
+	if queued {
+		return queuedNotice("deleted", n)
+	}
+	fmt.Println("Course deleted:", n)
+	return exitOK
+}
+
+//importCommand sends a JSON array of courses to the import endpoint and prints the rows that failed
+func importCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("import", "[-file path] [-best-effort] [-dry-run]")
+	file := fs.String("file", "-", "JSON `file` with an array of courses, or - for standard input")
+	var options client.ImportOptions
+	fs.BoolVar(&options.BestEffort, "best-effort", false, "import the valid courses even if others fail")
+	fs.BoolVar(&options.DryRun, "dry-run", false, "only check the courses")
+	if ok, status := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, errors.New("unexpected argument "+fs.Arg(0)))
+	}
+	var courses []client.CourseInfo
+	if err := readJSON(*file, &courses); err != nil {
+		return usageError(fs, err)
+	}
+	report, err := api.Import(ctx, courses, options)
+	if err != nil && report.Total == 0 {
+		return exitStatus("import", err)
+	}
+	for _, row := range report.Rows {
+		if row.Status == "failed" {
+			fmt.Fprintln(os.Stderr, row)
+		}
+	}
+	if report.DryRun {
+		fmt.Printf("Checked %d courses, %d failed\n", report.Total, report.Failed)
+	} else {
+		fmt.Printf("Imported %d of %d courses, %d failed\n", report.Imported, report.Total, report.Failed)
+	}
+	if report.Failed > 0 {
+		return exitRejected
+	}
+	return exitOK
+}
+
+//exportCommand writes the courses to standard output or to a file, which is only replaced if the export succeeds
+func exportCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("export", "[-format csv|excel|ndjson] [-out path] [-title text] [-lecturer text]")
+	format := fs.String("format", "csv", "file format: csv, excel or ndjson")
+	out := fs.String("out", "", "`file` to write instead of standard output")
+	var filter client.Filter
+	fs.StringVar(&filter.Title, "title", "", "only courses whose title contains this text")
+	fs.StringVar(&filter.Lecturer, "lecturer", "", "only courses whose lecturer contains this text")
+	if ok, status := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, errors.New("unexpected argument "+fs.Arg(0)))
+	}
+	var file bytes.Buffer
+	if err := api.Export(ctx, &file, *format, filter); err != nil {
+		return exitStatus("export", err)
+	}
+	var err error
+	if *out == "" {
+		_, err = file.WriteTo(os.Stdout)
+	} else {
+		err = ioutil.WriteFile(*out, file.Bytes(), 0644)
+	}
+	if err != nil {
+		log.Error("Error at export command, ", err.Error())
+		return exitFailed
+	}
+	return exitOK
+}
+
+//staleWarning tells on standard error that the courses shown come from the local copy, and how old it is
+func staleWarning(freshness offline.Freshness) {
+	if !freshness.Stale {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "The server could not be reached (%s), showing courses as fetched %s\n", freshness.Err, fetchedAt(freshness.FetchedAt))
+}
+
+//fetchedAt describes when a local copy was fetched; courses created offline were never fetched
+func fetchedAt(t time.Time) string {
+	if t.IsZero() {
+		return "never, made offline"
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+//queuedNotice reports an edit that waits in the outbox and returns exitQueued
+func queuedNotice(done string, code int) int {
+	fmt.Fprintf(os.Stderr, "The server could not be reached, course %d will be %s once it can: the edit is queued offline\n", code, done)
+	return exitQueued
+}
+
+//syncOutbox sends the queued edits and reports what happened on standard error. Unless retry is set, edits a
+//previous sync could not send are left alone, so every command does not report the same conflict again.
+func syncOutbox(ctx context.Context, opts offline.SyncOptions) (offline.SyncReport, error) {
+	report, err := courseAPI.Sync(ctx, opts)
+	if err != nil {
+		log.Error("Error syncing offline edits, ", err.Error())
+		return report, err
+	}
+	if len(report.Sent) > 0 {
+		fmt.Fprintf(os.Stderr, "Offline edits sent: %d\n", len(report.Sent))
+	}
+	for _, edit := range append(report.Conflicts, report.Rejected...) {
+		fmt.Fprintf(os.Stderr, "Offline %s of course %d not sent: %s\n", edit.Op, edit.Course.Code, edit.Problem)
+	}
+	if len(report.Conflicts) > 0 || len(report.Rejected) > 0 {
+		fmt.Fprintln(os.Stderr, `Run "courses outbox" to review them, then "courses sync -force" or "courses outbox -discard <code>".`)
+	}
+	if report.Left > 0 {
+		fmt.Fprintf(os.Stderr, "Offline edits waiting for the server: %d\n", report.Left)
+	}
+	return report, nil
+}
+
+//syncCommand sends every queued edit, including those a previous sync could not send
+func syncCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("sync", "[-force]")
+	force := fs.Bool("force", false, "send edits even if the course changed on the server since, overwriting that change")
+	if ok, status := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, errors.New("unexpected argument "+fs.Arg(0)))
+	}
+	if courseAPI.Store == nil {
+		return usageError(fs, errors.New("offline mode is off, set a cache file with -cache-file"))
+	}
+	report, err := syncOutbox(ctx, offline.SyncOptions{Force: *force, Retry: true})
+	switch {
+	case err != nil || report.Left > 0:
+		return exitFailed
+	case len(report.Conflicts) > 0:
+		return exitConflict
+	case len(report.Rejected) > 0:
+		return exitRejected
+	}
+	if report.Empty() {
+		fmt.Println("Nothing to sync")
+	}
+	return exitOK
+}
+
+//outboxCommand lists the queued edits, with the server's version of courses whose edit conflicts with it
+func outboxCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("outbox", "[-discard code]")
+	discard := fs.Int("discard", 0, "drop the queued edit of this course `code` instead")
+	if ok, status := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, errors.New("unexpected argument "+fs.Arg(0)))
+	}
+	if courseAPI.Store == nil {
+		return usageError(fs, errors.New("offline mode is off, set a cache file with -cache-file"))
+	}
+	if *discard > 0 {
+		if err := courseAPI.Discard(*discard); err != nil {
+			if errors.Is(err, client.ErrNotFound) {
+				fmt.Fprintln(os.Stderr, "No queued edit of course", *discard)
+				return exitNotFound
+			}
+			log.Error("Error at outbox command, ", err.Error())
+			return exitFailed
+		}
+		fmt.Println("Queued edit discarded:", *discard)
+		return exitOK
+	}
+	edits, err := courseAPI.Outbox()
+	if err != nil {
+		log.Error("Error at outbox command, ", err.Error())
+		return exitFailed
+	}
+	if len(edits) == 0 {
+		fmt.Println("No edits waiting")
+	}
+	for _, edit := range edits {
+		fmt.Printf("%s %s ", edit.QueuedAt.Format("2006-01-02 15:04:05"), edit.Op)
+		printCourse(edit.Course)
+		if edit.Problem != "" {
+			fmt.Println("    not sent:", edit.Problem)
+		}
+		if edit.Server != nil {
+			fmt.Print("    server has: ")
+			printCourse(*edit.Server)
+		}
+	}
+	return exitOK
+}
+
+func shellCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("shell", "")
+	if ok, status := parseFlags(fs, args); !ok {
+		return status
+	}
+	cfg.Dump(os.Stdout)
+	menu()
+	return exitOK
+}
+
+//loginCommand saves an access key for the chosen profile, so it does not have to be kept in .env or typed in flags
+func loginCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("login", "")
+	if ok, status := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, errors.New("unexpected argument "+fs.Arg(0)))
+	}
+	if cfg.Profile == "" {
+		return usageError(fs, errors.New("choose a profile with -profile or set a default in the profiles file"))
+	}
+	if cfg.CredentialsFile == "" {
+		return usageError(fs, errors.New("no credentials file, set one with -credentials-file"))
+	}
+	key, err := readSecret("Access key for profile " + cfg.Profile + ": ")
+	if err != nil {
+		log.Error("Error at login command, ", err.Error())
+		return exitFailed
+	}
+	if key == "" {
+		return usageError(fs, errors.New("no access key given"))
+	}
+	if err := config.SaveCredential(cfg.CredentialsFile, cfg.Profile, key); err != nil {
+		log.Error("Error at login command, ", err.Error())
+		return exitFailed
+	}
+	fmt.Println("Access key saved for profile", cfg.Profile, "in", cfg.CredentialsFile)
+	return exitOK
+}
+
+//readSecret reads one line from standard input, without echoing it when that is a terminal
+func readSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		secret, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return strings.TrimSpace(string(secret)), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goMS1Assignment/console/client"
+)
+
+//TestExitStatus checks every outcome of a command maps to its documented exit status
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		got  func() int
+		want int
+	}{
+		{"success", func() int { return exitStatus("get", nil) }, 0},
+		{"unreachable", func() int { return exitStatus("get", errors.New("dial tcp: connection refused")) }, 1},
+		{"unknown command", func() int { return runCommand([]string{"frobnicate"}) }, 2},
+		{"no command", func() int { return runCommand(nil) }, 2},
+		{"unknown flag", func() int { return createCommand(nil, []string{"-colour", "red"}) }, 2},
+		{"incomplete course", func() int { return createCommand(nil, []string{"-code", "1", "-title", "Go"}) }, 2},
+		{"code that is not a number", func() int { return getCommand(nil, []string{"abc"}) }, 2},
+		{"help", func() int { return createCommand(nil, []string{"-h"}) }, 0},
+		{"404", func() int { return exitStatus("get", &client.APIError{StatusCode: 404}) }, 3},
+		{"409", func() int { return exitStatus("create", &client.APIError{StatusCode: 409}) }, 4},
+		{"400", func() int { return exitStatus("create", &client.APIError{StatusCode: 400}) }, 5},
+		{"422", func() int { return exitStatus("create", &client.APIError{StatusCode: 422}) }, 5},
+		{"429", func() int { return exitStatus("create", &client.APIError{StatusCode: 429}) }, 5},
+		{"500", func() int { return exitStatus("get", &client.APIError{StatusCode: 500}) }, 6},
+		{"503", func() int { return exitStatus("get", &client.APIError{StatusCode: 503}) }, 6},
+		{"wrapped", func() int { return exitStatus("get", fmt.Errorf("get: %w", &client.APIError{StatusCode: 404})) }, 3},
+		{"queued offline", func() int { return queuedNotice("added", 1) }, 7},
+	}
+	for _, tt := range tests {
+		if got := tt.got(); got != tt.want {
+			t.Errorf("%s: exit status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+//TestCodeArgument checks a course code is taken from the argument or the -code flag, but not both wrong
+func TestCodeArgument(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{[]string{"12"}, 12, false},
+		{[]string{"-code", "12"}, 12, false},
+		//the argument wins over the flag
+		{[]string{"-code", "12", "13"}, 13, false},
+		{[]string{}, 0, true},
+		{[]string{"-code", "0"}, 0, true},
+		{[]string{"-1"}, 0, true},
+		{[]string{"1x"}, 0, true},
+		{[]string{"1", "2"}, 0, true},
+	}
+	for _, tt := range tests {
+		fs := newFlagSet("get", "<code>")
+		fs.SetOutput(ioutil.Discard)
+		code := fs.Int("code", 0, "course code")
+		if err := fs.Parse(tt.args); err != nil {
+			if !tt.wantErr {
+				t.Errorf("%q: %v", tt.args, err)
+			}
+			continue
+		}
+		got, err := codeArgument(fs, *code)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%q: code %d, error %v, want %d, error %v", tt.args, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+//TestCourseInput checks a course is read from a JSON file or standard input, with the flags given on top of it
+func TestCourseInput(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "course.json")
+	json := `{"Code": 1, "Title": "Go", "Dates": "Jan", "Lecturer": "Tan", "Description": "basics"}`
+	if err := ioutil.WriteFile(file, []byte(json), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "unknown.json"), []byte(`{"Code": 1, "Room": "A"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+
+	tests := []struct {
+		name    string
+		args    []string
+		stdin   string
+		want    client.CourseInfo
+		wantErr bool
+	}{
+		{"flags only", []string{"-code", "2", "-title", "Rust"}, "", client.CourseInfo{Code: 2, Title: "Rust"}, false},
+		{"file only", []string{"-file", file}, "", client.CourseInfo{Code: 1, Title: "Go", Dates: "Jan", Lecturer: "Tan", Description: "basics"}, false},
+		{"flags override the file", []string{"-file", file, "-title", "Advanced Go", "-code", "3"}, "",
+			client.CourseInfo{Code: 3, Title: "Advanced Go", Dates: "Jan", Lecturer: "Tan", Description: "basics"}, false},
+		{"flags override the file whatever their order", []string{"-lecturer", "Lee", "-file", file}, "",
+			client.CourseInfo{Code: 1, Title: "Go", Dates: "Jan", Lecturer: "Lee", Description: "basics"}, false},
+		//a flag given empty clears the field
+		{"empty flag", []string{"-file", file, "-description", ""}, "", client.CourseInfo{Code: 1, Title: "Go", Dates: "Jan", Lecturer: "Tan"}, false},
+		{"standard input", []string{"-file", "-", "-dates", "Feb"}, json,
+			client.CourseInfo{Code: 1, Title: "Go", Dates: "Feb", Lecturer: "Tan", Description: "basics"}, false},
+		{"missing file", []string{"-file", filepath.Join(dir, "missing.json")}, "", client.CourseInfo{}, true},
+		{"unknown field", []string{"-file", filepath.Join(dir, "unknown.json")}, "", client.CourseInfo{}, true},
+		{"bad JSON on standard input", []string{"-file", "-"}, "{", client.CourseInfo{}, true},
+		{"argument", []string{"-code", "1", "extra"}, "", client.CourseInfo{}, true},
+	}
+	for _, tt := range tests {
+		if tt.stdin != "" || tt.args[len(tt.args)-1] == "-" {
+			in := filepath.Join(dir, "stdin")
+			if err := ioutil.WriteFile(in, []byte(tt.stdin), 0600); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			os.Stdin = f
+		}
+		fs := newFlagSet("create", "")
+		flags, path := courseFlags(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := courseInput(fs, flags, *path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error %v, want an error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("%s: course %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+//TestCheckCourse checks new courses need every field, updates only the code, and fields are validated and sanitized
+func TestCheckCourse(t *testing.T) {
+	complete := client.CourseInfo{Code: 1, Title: "Go", Dates: "Jan", Lecturer: "Tan", Description: "basics"}
+	tests := []struct {
+		name    string
+		course  client.CourseInfo
+		partial bool
+		wantErr string
+	}{
+		{"complete", complete, false, ""},
+		{"missing fields", client.CourseInfo{Code: 1, Title: "Go"}, false, "dates is required; lecturer is required; description is required"},
+		{"partial update", client.CourseInfo{Code: 1, Title: "Go"}, true, ""},
+		{"no code", client.CourseInfo{Title: "Go"}, true, "a course code is required"},
+		{"wrong format", client.CourseInfo{Code: 1, Title: "Go {}", Lecturer: "<Tan>"}, true, "title is in the wrong format; lecturer is in the wrong format"},
+	}
+	for _, tt := range tests {
+		course := tt.course
+		err := checkCourse(&course, tt.partial)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+			t.Errorf("%s: %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+
+	course := client.CourseInfo{Code: 1, Title: "Go", Dates: "Jan", Lecturer: "O'Brien", Description: "basics"}
+	if err := checkCourse(&course, false); err != nil || course.Lecturer != "O&#39;Brien" {
+		t.Errorf("sanitized lecturer %q, %v", course.Lecturer, err)
+	}
+}
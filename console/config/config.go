@@ -0,0 +1,266 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v2"
+)
+
+//Config holds every setting the console client needs at startup
+type Config struct {
+	APIKey  string `yaml:"api_key" toml:"api_key"`
+	BaseURL string `yaml:"base_url" toml:"base_url"`
+	CAFile  string `yaml:"ca_file" toml:"ca_file"`
+	//ClientCert and ClientKey are a certificate and key presented to servers that ask for one
+	ClientCert string `yaml:"client_cert" toml:"client_cert"`
+	ClientKey  string `yaml:"client_key" toml:"client_key"`
+
+	//Profile names the entry of the profiles file to use; its access key comes from the credentials file
+	Profile         string `yaml:"profile" toml:"profile"`
+	ProfilesFile    string `yaml:"profiles_file" toml:"profiles_file"`
+	CredentialsFile string `yaml:"credentials_file" toml:"credentials_file"`
+
+	//Timeout bounds each attempt of a request
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+	//Retries is how many more times a request that is safe to repeat is sent after it failed in transit
+	Retries      int           `yaml:"retries" toml:"retries"`
+	RetryBackoff time.Duration `yaml:"retry_backoff" toml:"retry_backoff"` //before the first retry, doubled for each one after
+	//BreakerFailures failed requests in a row make requests fail fast for BreakerCooldown; 0 disables the breaker
+	BreakerFailures int           `yaml:"breaker_failures" toml:"breaker_failures"`
+	BreakerCooldown time.Duration `yaml:"breaker_cooldown" toml:"breaker_cooldown"`
+
+	//CacheFile keeps fetched courses and offline edits, so the console still works while the API is down; empty disables it
+	CacheFile string `yaml:"cache_file" toml:"cache_file"`
+}
+
+//field binds one setting to its flag name, environment variable and struct field.
+//Exactly one of str, num and dur is set.
+type field struct {
+	flag    string
+	env     string
+	usage   string
+	secret  bool
+	profile bool //chooses the profile, so it is read before the profile is applied
+	str     *string
+	num     *int
+	dur     *time.Duration
+}
+
+//Default returns the configuration used when nothing else is supplied
+func Default() Config {
+	return Config{
+		BaseURL:         "https://localhost:5000",
+		CAFile:          "cert/ca.crt",
+		ProfilesFile:    defaultFile("profiles.yaml"),
+		CredentialsFile: defaultFile("credentials.yaml"),
+		Timeout:         30 * time.Second,
+		Retries:         2,
+		RetryBackoff:    500 * time.Millisecond,
+		BreakerFailures: 5,
+		BreakerCooldown: 30 * time.Second,
+		CacheFile:       defaultFile("offline.db"),
+	}
+}
+
+func (c *Config) fields() []field {
+	return []field{
+		{flag: "api-key", env: "API_KEY", usage: "access key for the REST API", secret: true, str: &c.APIKey},
+		{flag: "base-url", env: "BASE_URL", usage: "base URL of the REST API", str: &c.BaseURL},
+		{flag: "ca-file", env: "CA_FILE", usage: "CA certificate used to verify the server", str: &c.CAFile},
+		{flag: "client-cert", env: "CLIENT_CERT", usage: "client certificate presented to the server", str: &c.ClientCert},
+		{flag: "client-key", env: "CLIENT_KEY", usage: "private key of the client certificate", str: &c.ClientKey},
+		{flag: "profile", env: "COURSES_PROFILE", usage: "profile to use from the profiles file", profile: true, str: &c.Profile},
+		{flag: "profiles-file", env: "PROFILES_FILE", usage: "YAML or TOML file of named profiles", profile: true, str: &c.ProfilesFile},
+		{flag: "credentials-file", env: "CREDENTIALS_FILE", usage: "file with the access key of each profile, readable by its owner only", profile: true, str: &c.CredentialsFile},
+		{flag: "timeout", env: "REQUEST_TIMEOUT", usage: "timeout for each attempt of a request", dur: &c.Timeout},
+		{flag: "retries", env: "RETRIES", usage: "retries of a request that failed in transit, only if it is safe to repeat", num: &c.Retries},
+		{flag: "retry-backoff", env: "RETRY_BACKOFF", usage: "wait before the first retry, doubled for each one after", dur: &c.RetryBackoff},
+		{flag: "breaker-failures", env: "BREAKER_FAILURES", usage: "failed requests in a row before requests fail fast, 0 to disable", num: &c.BreakerFailures},
+		{flag: "breaker-cooldown", env: "BREAKER_COOLDOWN", usage: "how long requests fail fast before one is tried again", dur: &c.BreakerCooldown},
+		{flag: "cache-file", env: "CACHE_FILE", usage: "file keeping courses and edits for offline use, empty to disable", str: &c.CacheFile},
+	}
+}
+
+func (f field) set(value string) error {
+	if f.num != nil {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", f.flag, value)
+		}
+		*f.num = n
+		return nil
+	}
+	if f.dur != nil {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a duration", f.flag, value)
+		}
+		*f.dur = d
+		return nil
+	}
+	*f.str = value
+	return nil
+}
+
+func (f field) value() string {
+	if f.num != nil {
+		return strconv.Itoa(*f.num)
+	}
+	if f.dur != nil {
+		return f.dur.String()
+	}
+	return *f.str
+}
+
+//Load builds the configuration by layering defaults, the config file, .env, the chosen profile, environment variables
+//and flags, in that order. The config file is taken from the -config flag or the CONFIG_FILE environment variable.
+//Arguments left over after the flags are returned unparsed.
+func Load(name string, args []string) (Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", "", "path to a YAML or TOML config file")
+	for _, f := range cfg.fields() {
+		fs.String(f.flag, "", f.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	//.env is optional. It is kept apart from the environment, as it sits below the profile: an API_KEY left in it
+	//from before profiles existed must not replace the key stored for every profile.
+	dotenv, _ := godotenv.Read(".env")
+	lookupEnv := func(name string) (string, bool) {
+		if v, ok := os.LookupEnv(name); ok {
+			return v, true
+		}
+		v, ok := dotenv[name]
+		return v, ok
+	}
+
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return cfg, nil, err
+		}
+	}
+
+	given := make(map[string]string)
+	fs.Visit(func(fl *flag.Flag) {
+		given[fl.Name] = fl.Value.String()
+	})
+	override := func(f field, lookup func(string) (string, bool)) error {
+		if v, ok := lookup(f.env); ok {
+			if err := f.set(v); err != nil {
+				return err
+			}
+		}
+		if v, ok := given[f.flag]; ok {
+			return f.set(v)
+		}
+		return nil
+	}
+
+	//the profile sits between .env and the environment, so environment variables and flags still win over it
+	fields := cfg.fields()
+	for _, f := range fields {
+		if f.profile {
+			if err := override(f, lookupEnv); err != nil {
+				return cfg, nil, err
+			}
+		} else if v, ok := dotenv[f.env]; ok {
+			if err := f.set(v); err != nil {
+				return cfg, nil, err
+			}
+		}
+	}
+	if err := cfg.applyProfile(); err != nil {
+		return cfg, nil, err
+	}
+	for _, f := range fields {
+		if err := override(f, os.LookupEnv); err != nil {
+			return cfg, nil, err
+		}
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	return cfg, fs.Args(), nil
+}
+
+//loadFile reads a YAML or TOML file, chosen by its extension, into v
+func loadFile(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, v)
+	case ".toml":
+		_, err = toml.Decode(string(data), v)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+//Validate reports every problem with the configuration at once
+func (c Config) Validate() error {
+	var problems []string
+	if c.APIKey == "" {
+		problems = append(problems, "api key is required, save one for the profile with the login command")
+	}
+	if u, err := url.Parse(c.BaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("base URL %q is not an absolute URL", c.BaseURL))
+	}
+	if _, err := os.Stat(c.CAFile); err != nil {
+		problems = append(problems, fmt.Sprintf("CA file %q: %v", c.CAFile, err))
+	}
+	if (c.ClientCert == "") != (c.ClientKey == "") {
+		problems = append(problems, "client certificate and key must be given together")
+	}
+	for _, file := range []string{c.ClientCert, c.ClientKey} {
+		if _, err := os.Stat(file); file != "" && err != nil {
+			problems = append(problems, fmt.Sprintf("client certificate file %q: %v", file, err))
+		}
+	}
+	if c.Timeout <= 0 || c.RetryBackoff <= 0 || c.BreakerCooldown <= 0 {
+		problems = append(problems, "timeout, retry backoff and breaker cooldown must be positive")
+	}
+	if c.Retries < 0 || c.BreakerFailures < 0 {
+		problems = append(problems, "retries and breaker failures must not be negative")
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+//Dump writes the effective configuration with secrets redacted
+func (c Config) Dump(w io.Writer) {
+	fmt.Fprintln(w, "Effective configuration:")
+	for _, f := range c.fields() {
+		value := f.value()
+		if f.secret && value != "" {
+			value = "********"
+		}
+		fmt.Fprintf(w, "  %-16s = %s\n", f.flag, value)
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//TestLoadFileDurations checks the duration settings can be written as "10s" in both config file formats
+func TestLoadFileDurations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"config.toml", "timeout = \"10s\"\nretries = 4\nretry_backoff = \"250ms\"\nbreaker_cooldown = \"1m\"\n"},
+		{"config.yaml", "timeout: 10s\nretries: 4\nretry_backoff: 250ms\nbreaker_cooldown: 1m\n"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		cfg := Default()
+		if err := loadFile(path, &cfg); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if cfg.Timeout != 10*time.Second || cfg.Retries != 4 || cfg.RetryBackoff != 250*time.Millisecond || cfg.BreakerCooldown != time.Minute {
+			t.Errorf("%s: got timeout %v, retries %d, retry backoff %v, breaker cooldown %v",
+				tt.name, cfg.Timeout, cfg.Retries, cfg.RetryBackoff, cfg.BreakerCooldown)
+		}
+		if cfg.BaseURL != Default().BaseURL {
+			t.Errorf("%s: base URL %q not left at its default", tt.name, cfg.BaseURL)
+		}
+	}
+}
+
+//TestLoadTOMLConfigFlag loads a TOML config file given with -config, as the console does at startup
+func TestLoadTOMLConfigFlag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "courses.toml")
+	content := "base_url = \"https://courses.example.com/\"\ntimeout = \"5s\"\nbreaker_cooldown = \"45s\"\n" +
+		"profiles_file = \"\"\ncredentials_file = \"\"\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, args, err := Load("courses", []string{"-config", path, "-retry-backoff", "2s", "list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 1 || args[0] != "list" {
+		t.Errorf("left over arguments %q, want [list]", args)
+	}
+	if cfg.Timeout != 5*time.Second || cfg.BreakerCooldown != 45*time.Second || cfg.RetryBackoff != 2*time.Second {
+		t.Errorf("got timeout %v, breaker cooldown %v, retry backoff %v", cfg.Timeout, cfg.BreakerCooldown, cfg.RetryBackoff)
+	}
+	if cfg.BaseURL != "https://courses.example.com" {
+		t.Errorf("base URL %q, want the trailing slash trimmed", cfg.BaseURL)
+	}
+}
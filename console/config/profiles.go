@@ -0,0 +1,171 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"gopkg.in/yaml.v2"
+)
+
+//Profile is one named environment, such as dev, staging or prod, in the profiles file.
+//Relative paths are taken from the directory of the profiles file.
+type Profile struct {
+	BaseURL    string `yaml:"base_url" toml:"base_url"`
+	CAFile     string `yaml:"ca_file" toml:"ca_file"`
+	ClientCert string `yaml:"client_cert" toml:"client_cert"`
+	ClientKey  string `yaml:"client_key" toml:"client_key"`
+}
+
+//Profiles is the content of the profiles file
+type Profiles struct {
+	//Default is the profile used when none is chosen with -profile
+	Default  string             `yaml:"default" toml:"default"`
+	Profiles map[string]Profile `yaml:"profiles" toml:"profiles"`
+}
+
+//Credential holds the secrets of one profile. Credentials are kept apart from the profiles, in a file only the owner can read.
+type Credential struct {
+	APIKey string `yaml:"api_key"`
+}
+
+//configDir is where the profiles and credentials files are kept by default, or empty if there is no such directory
+func configDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "courses")
+}
+
+//defaultFile returns the path of a file in configDir, or empty if there is none
+func defaultFile(name string) string {
+	if dir := configDir(); dir != "" {
+		return filepath.Join(dir, name)
+	}
+	return ""
+}
+
+//applyProfile copies the chosen profile and its access key into the configuration.
+//Nothing happens if no profile is chosen and the profiles file names no default.
+func (c *Config) applyProfile() error {
+	var profiles Profiles
+	if c.ProfilesFile != "" {
+		//the file is only needed once a profile is chosen
+		err := loadFile(c.ProfilesFile, &profiles)
+		if err != nil && (c.Profile != "" || !errors.Is(err, os.ErrNotExist)) {
+			return err
+		}
+	}
+	name := c.Profile
+	if name == "" {
+		name = profiles.Default
+	}
+	if name == "" {
+		return nil
+	}
+	profile, ok := profiles.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile %q is not in the profiles file %s", name, c.ProfilesFile)
+	}
+	c.Profile = name
+
+	dir := filepath.Dir(c.ProfilesFile)
+	settings := []struct {
+		value string
+		to    *string
+		path  bool
+	}{
+		{profile.BaseURL, &c.BaseURL, false},
+		{profile.CAFile, &c.CAFile, true},
+		{profile.ClientCert, &c.ClientCert, true},
+		{profile.ClientKey, &c.ClientKey, true},
+	}
+	for _, s := range settings {
+		if s.value == "" {
+			continue
+		}
+		*s.to = s.value
+		if s.path && !filepath.IsAbs(s.value) {
+			*s.to = filepath.Join(dir, s.value)
+		}
+	}
+
+	credentials, err := LoadCredentials(c.CredentialsFile)
+	if err != nil {
+		return err
+	}
+	if key := credentials[name].APIKey; key != "" {
+		c.APIKey = key
+	}
+	return nil
+}
+
+//LoadCredentials reads the credentials file. A file that does not exist holds no credentials;
+//one that others may read or write is refused, as its secrets may no longer be secret.
+func LoadCredentials(path string) (map[string]Credential, error) {
+	if path == "" {
+		return nil, nil
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("credentials file: %w", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("credentials file %s can be read by other users, restrict it with chmod 600", path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("credentials file: %w", err)
+	}
+	credentials := make(map[string]Credential)
+	if err := yaml.UnmarshalStrict(data, &credentials); err != nil {
+		return nil, fmt.Errorf("parsing credentials file %s: %w", path, err)
+	}
+	return credentials, nil
+}
+
+//SaveCredential stores the access key of a profile in the credentials file, which only the owner can read.
+//The file is replaced in one step, so a failed write leaves the old one in place.
+func SaveCredential(path, profile, apiKey string) error {
+	credentials, err := LoadCredentials(path)
+	if err != nil {
+		return err
+	}
+	if credentials == nil {
+		credentials = make(map[string]Credential)
+	}
+	credentials[profile] = Credential{APIKey: apiKey}
+	data, err := yaml.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("credentials file: %w", err)
+	}
+	file, err := ioutil.TempFile(dir, ".credentials-*")
+	if err != nil {
+		return fmt.Errorf("credentials file: %w", err)
+	}
+	defer os.Remove(file.Name())
+	//TempFile creates the file readable by the owner only
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("credentials file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("credentials file: %w", err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("credentials file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+//writeFile writes content to name in dir with the given permissions and returns its path
+func writeFile(t *testing.T, dir, name, content string, perm os.FileMode) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), perm); err != nil {
+		t.Fatal(err)
+	}
+	//WriteFile leaves the umask applied
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const testProfiles = `default: dev
+profiles:
+  dev:
+    base_url: https://localhost:5000
+    ca_file: cert/ca.crt
+  prod:
+    base_url: https://courses.example.com
+    ca_file: /etc/ssl/certs/ca-bundle.crt
+    client_cert: prod/client.crt
+    client_key: prod/client.key
+`
+
+//TestApplyProfile checks the chosen profile, or the default one, is copied in with relative paths taken from the
+//directory of the profiles file, and its saved key used
+func TestApplyProfile(t *testing.T) {
+	dir := t.TempDir()
+	profiles := writeFile(t, dir, "profiles.yaml", testProfiles, 0600)
+	credentials := writeFile(t, dir, "credentials.yaml", "dev:\n  api_key: dev-key\nprod:\n  api_key: prod-key\n", 0600)
+
+	tests := []struct {
+		profile string
+		want    Config
+	}{
+		{"", Config{Profile: "dev", BaseURL: "https://localhost:5000", CAFile: filepath.Join(dir, "cert/ca.crt"), APIKey: "dev-key"}},
+		{"prod", Config{Profile: "prod", BaseURL: "https://courses.example.com", CAFile: "/etc/ssl/certs/ca-bundle.crt",
+			ClientCert: filepath.Join(dir, "prod/client.crt"), ClientKey: filepath.Join(dir, "prod/client.key"), APIKey: "prod-key"}},
+	}
+	for _, tt := range tests {
+		cfg := Config{Profile: tt.profile, ProfilesFile: profiles, CredentialsFile: credentials, APIKey: "from the config file"}
+		if err := cfg.applyProfile(); err != nil {
+			t.Fatalf("profile %q: %v", tt.profile, err)
+		}
+		tt.want.ProfilesFile, tt.want.CredentialsFile = profiles, credentials
+		if cfg != tt.want {
+			t.Errorf("profile %q:\n got %+v\nwant %+v", tt.profile, cfg, tt.want)
+		}
+	}
+
+	cfg := Config{Profile: "staging", ProfilesFile: profiles}
+	if err := cfg.applyProfile(); err == nil || !strings.Contains(err.Error(), "staging") {
+		t.Errorf("unknown profile: %v", err)
+	}
+	//a missing profiles file only matters once a profile is chosen
+	cfg = Config{ProfilesFile: filepath.Join(dir, "missing.yaml"), BaseURL: "https://keep"}
+	if err := cfg.applyProfile(); err != nil || cfg.BaseURL != "https://keep" {
+		t.Errorf("no profile chosen and no profiles file: %v, base URL %q", err, cfg.BaseURL)
+	}
+	cfg.Profile = "dev"
+	if err := cfg.applyProfile(); err == nil {
+		t.Error("a chosen profile without a profiles file was accepted")
+	}
+}
+
+//TestLoadCredentialsPermissions checks a credentials file other users may read or write is refused
+func TestLoadCredentialsPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not checked on Windows")
+	}
+	dir := t.TempDir()
+	for _, perm := range []os.FileMode{0644, 0640, 0604, 0620} {
+		path := writeFile(t, dir, "credentials.yaml", "dev:\n  api_key: k\n", perm)
+		if _, err := LoadCredentials(path); err == nil || !strings.Contains(err.Error(), "chmod 600") {
+			t.Errorf("mode %o: %v, want it refused", perm, err)
+		}
+	}
+	path := writeFile(t, dir, "credentials.yaml", "dev:\n  api_key: k\n", 0600)
+	if credentials, err := LoadCredentials(path); err != nil || credentials["dev"].APIKey != "k" {
+		t.Errorf("mode 600: %v, %v", credentials, err)
+	}
+	if credentials, err := LoadCredentials(filepath.Join(dir, "missing.yaml")); err != nil || len(credentials) != 0 {
+		t.Errorf("missing file: %v, %v", credentials, err)
+	}
+}
+
+//TestSaveCredential checks keys are saved per profile, in a new directory if need be, in a file only the owner can read
+func TestSaveCredential(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "courses", "credentials.yaml")
+	for _, save := range [][2]string{{"dev", "first"}, {"prod", "prod-key"}, {"dev", "dev-key"}} {
+		if err := SaveCredential(path, save[0], save[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	credentials, err := LoadCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(credentials) != 2 || credentials["dev"].APIKey != "dev-key" || credentials["prod"].APIKey != "prod-key" {
+		t.Errorf("credentials %v", credentials)
+	}
+	if info, err := os.Stat(path); err != nil || runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("credentials file mode %v, %v, want 600", info.Mode(), err)
+	}
+	//no temporary file is left behind
+	if files, _ := ioutil.ReadDir(filepath.Dir(path)); len(files) != 1 {
+		t.Errorf("%d files next to the credentials", len(files))
+	}
+}
+
+//TestProfileKeyBeatsDotEnv checks an API_KEY left in .env does not replace the profile's saved key,
+//while one set in the environment or given as a flag still does
+func TestProfileKeyBeatsDotEnv(t *testing.T) {
+	dir := t.TempDir()
+	profiles := writeFile(t, dir, "profiles.yaml", testProfiles, 0600)
+	credentials := writeFile(t, dir, "credentials.yaml", "dev:\n  api_key: dev-key\n", 0600)
+	writeFile(t, dir, ".env", "API_KEY=legacy-key\nRETRIES=5\n", 0600)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	for _, name := range []string{"API_KEY", "RETRIES", "COURSES_PROFILE", "CONFIG_FILE"} {
+		if old, ok := os.LookupEnv(name); ok {
+			os.Unsetenv(name)
+			defer os.Setenv(name, old)
+		}
+	}
+
+	args := []string{"-profiles-file", profiles, "-credentials-file", credentials}
+	cfg, _, err := Load("test", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIKey != "dev-key" {
+		t.Errorf("api key %q with a key in .env, want the profile's", cfg.APIKey)
+	}
+	//the rest of .env is still read
+	if cfg.Retries != 5 {
+		t.Errorf("retries %d, want 5 from .env", cfg.Retries)
+	}
+
+	os.Setenv("API_KEY", "env-key")
+	defer os.Unsetenv("API_KEY")
+	if cfg, _, _ := Load("test", args); cfg.APIKey != "env-key" {
+		t.Errorf("api key %q with API_KEY set, want the environment's", cfg.APIKey)
+	}
+	if cfg, _, _ := Load("test", append(args, "-api-key", "flag-key")); cfg.APIKey != "flag-key" {
+		t.Errorf("api key %q with -api-key, want the flag's", cfg.APIKey)
+	}
+	//without a profile .env still supplies the key
+	os.Unsetenv("API_KEY")
+	if cfg, _, _ := Load("test", []string{"-profiles-file", "", "-credentials-file", ""}); cfg.APIKey != "legacy-key" {
+		t.Errorf("api key %q without a profile, want the one in .env", cfg.APIKey)
+	}
+}
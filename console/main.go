@@ -2,50 +2,38 @@ package main
 
 import (
 	"bufio"
-	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 
-	"github.com/joho/godotenv"
+	"goMS1Assignment/console/client"
+	"goMS1Assignment/console/config"
+	"goMS1Assignment/console/offline"
+
 	"github.com/microcosm-cc/bluemonday"
 	log "github.com/sirupsen/logrus"
 )
 
-const baseURL = "https://localhost:5000/api/v1/courses"
-
-type CourseInfo struct {
-	Code        int    `json:"Code"`
-	Title       string `json:"Title"`
-	Dates       string `json:"Dates"`
-	Lecturer    string `json:"Lecturer"`
-	Description string `json:"Description"`
-}
-
 var (
-	key          string
+	api          *client.Client
+	courseAPI    *offline.Client //api with the local copy and outbox used while the server is unreachable
+	cfg          config.Config
 	codeRegExp   *regexp.Regexp
 	detailRegExp *regexp.Regexp
 	pol          = bluemonday.UGCPolicy() //pol for policy. Used for sanitization of input after input validation.
-	client       = &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{RootCAs: loadCA("cert/ca.crt")},
-		},
-	}
 )
 
 func init() {
-	//godotenv package
-	key = goDotEnvVariable("API_KEY")
-
 	//below codes are for initializing third party logrus
 	var filename string = "log/logfile.log"
 	// Create the log file if doesn't exist. And append to it if it already exists.
@@ -59,9 +47,9 @@ func init() {
 
 	if err != nil {
 		// Cannot open log file. Logging to stderr
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 	} else {
-		log.SetOutput(io.MultiWriter(file, os.Stdout)) //default logger will be writing to file and os.Stdout
+		log.SetOutput(io.MultiWriter(file, os.Stderr)) //default logger will be writing to file and os.Stderr, keeping command output clean
 	}
 
 	codeRegExp = regexp.MustCompile(`^[0-9]*$`)                                              //code regexp to check for code pattern match
@@ -80,92 +68,153 @@ func loadCA(caFile string) *x509.CertPool {
 	return pool
 }
 
-//use godot package to load/read the .env file and return the value of the key
-func goDotEnvVariable(key string) string {
-	//load .env file
-	err := godotenv.Load(".env")
-
+//getCourse prints one course, or every course when code is empty.
+//The client remembers replies, so repeated reads are answered with 304 Not Modified.
+func getCourse(code string) {
+	ctx := context.Background()
+	if code == "" {
+		courses, freshness, err := courseAPI.List(ctx, client.Filter{})
+		if err != nil {
+			requestFailed("get course", err)
+			return
+		}
+		staleWarning(freshness)
+		defaultOutput().write(os.Stdout, courses, false)
+		return
+	}
+	n, _ := strconv.Atoi(code)
+	course, freshness, err := courseAPI.Get(ctx, n)
 	if err != nil {
-		log.Fatalf("Error loading .env file")
-
+		requestFailed("get course", err)
+		return
 	}
-	return os.Getenv(key)
+	staleWarning(freshness)
+	defaultOutput().write(os.Stdout, []client.CourseInfo{course}, true)
 }
 
-//addCourse sends a http request with Method Get and awaits a response
-func getCourse(code string) {
-	url := baseURL + "?key=" + key
-	if code != "" {
-		url = baseURL + "/" + code + "?key=" + key
-	}
-	response, err := client.Get(url)
+//searchCourses prints the courses matching query, best match first
+func searchCourses(query string) {
+	results, err := api.Search(context.Background(), query, 20, false)
 	if err != nil {
-		fmt.Printf("The HTTP request failed with error %s\n", err)
-		log.Error("Error at get course function", err.Error())
-	} else {
-		data, _ := ioutil.ReadAll(response.Body)
-		fmt.Println(response.StatusCode)
-		fmt.Println(string(data))
-		response.Body.Close()
+		requestFailed("search courses", err)
+		return
+	}
+	for _, result := range results {
+		fmt.Printf("(%.2f) ", result.Score)
+		printCourse(result.Course)
 	}
 }
 
-//addCourse sends a http request with Method post and awaits a response
-func addCourse(code string, jsonData CourseInfo) {
-	jsonValue, _ := json.Marshal(jsonData)
-
-	response, err := client.Post(baseURL+"/"+code+"?key="+key, "application/json", bytes.NewBuffer(jsonValue))
+//addCourse creates a course; the client retries it safely with an Idempotency-Key
+func addCourse(course client.CourseInfo) {
+	queued, err := courseAPI.Create(context.Background(), course)
+	if err != nil {
+		requestFailed("add course", err)
+		return
+	}
+	if queued {
+		queuedNotice("added", course.Code)
+		return
+	}
+	fmt.Println("Course added:", course.Code)
+}
 
+//updateCourse changes a course, or creates it if it does not exist
+func updateCourse(course client.CourseInfo) {
+	created, queued, err := courseAPI.Update(context.Background(), course)
 	if err != nil {
-		fmt.Printf("The HTTP request failed with error %s\n", err)
-		log.Error("Error at add course function", err.Error())
+		requestFailed("update course", err)
+		return
+	}
+	if queued {
+		queuedNotice("updated", course.Code)
+		return
+	}
+	if created {
+		fmt.Println("Course added:", course.Code)
 	} else {
-		data, _ := ioutil.ReadAll(response.Body)
-		fmt.Println(response.StatusCode)
-		fmt.Println(string(data))
-		response.Body.Close()
+		fmt.Println("Course updated:", course.Code)
 	}
 }
 
-//updateCourse sends a http request with Method put and awaits a response
-func updateCourse(code string, jsonData CourseInfo) {
-	jsonValue, _ := json.Marshal(jsonData)
-
-	request, err := http.NewRequest(http.MethodPut, baseURL+"/"+code+"?key="+key, bytes.NewBuffer(jsonValue))
-
-	request.Header.Set("Content-Type", "application/json")
+//deleteCourse deletes a course
+func deleteCourse(code string) {
+	n, _ := strconv.Atoi(code)
+	queued, err := courseAPI.Delete(context.Background(), n)
+	if err != nil {
+		requestFailed("delete course", err)
+		return
+	}
+	if queued {
+		queuedNotice("deleted", n)
+		return
+	}
+	fmt.Println("Course deleted:", code)
+}
 
-	//client := &http.Client{}
-	response, err := client.Do(request) //this is to send the request
+//openStore opens the local copy and outbox kept for offline use. The console still works without them,
+//for example while another console has the file open, only not offline.
+func openStore(path, baseURL string) *offline.Store {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		log.Warn("Offline mode is off, cannot create the cache directory: ", err)
+		return nil
+	}
+	store, err := offline.Open(path, baseURL)
 	if err != nil {
-		fmt.Printf("The HTTP request failed with error %s\n", err)
-		log.Error("Error at update course function", err.Error())
-	} else {
-		data, _ := ioutil.ReadAll(response.Body)
-		fmt.Println(response.StatusCode)
-		fmt.Println(string(data))
-		response.Body.Close()
+		log.Warn("Offline mode is off, cannot open the cache file "+path+": ", err)
+		return nil
 	}
+	return store
 }
 
-//deleteCourse sends a http request with Method delete and awaits a response
-func deleteCourse(code string) {
-	request, err := http.NewRequest(http.MethodDelete, baseURL+"/"+code+"?key="+key, nil)
+//printCourse writes a course on one line
+func printCourse(course client.CourseInfo) {
+	fmt.Printf("%d | %s | %s | %s | %s\n", course.Code, course.Title, course.Dates, course.Lecturer, course.Description)
+}
 
-	//client := &http.Client{}
-	response, err := client.Do(request)
+//requestFailed reports a failed request. Errors from the server are shown; failures to reach it are also logged.
+func requestFailed(function string, err error) {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		fmt.Println(apiErr)
+		return
+	}
+	fmt.Printf("The HTTP request failed with error %s\n", err)
+	log.Error("Error at "+function+" function", err.Error())
+}
 
-	if err != nil {
-		fmt.Printf("The HTTP request failed with error %s\n", err)
-		log.Error("Error at delete course function", err.Error())
-	} else {
-		data, _ := ioutil.ReadAll(response.Body)
-		fmt.Println(response.StatusCode)
-		fmt.Println(string(data))
-		response.Body.Close()
+//watchCourses prints course changes from the server's event stream until ctx is cancelled
+func watchCourses(ctx context.Context) {
+	err := api.Watch(ctx, func(event client.Event) {
+		switch event.Type {
+		case client.EventReset:
+			fmt.Println("(some changes were missed, please reload the course list)")
+		case client.EventReconnect:
+			fmt.Println("(connection lost, reconnecting)")
+		default:
+			fmt.Printf("[%s] ", event.Type)
+			printCourse(event.Course)
+		}
+	})
+	if err != nil && ctx.Err() == nil {
+		requestFailed("watch courses", err)
 	}
 }
 
+//console function to watch course changes until enter is pressed
+func watchFunc() {
+	fmt.Println("Watching course changes. Press enter to stop.")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		watchCourses(ctx)
+		close(done)
+	}()
+	bufio.NewReader(os.Stdin).ReadString('\n')
+	cancel()
+	<-done
+}
+
 func menu() {
 	var choice string
 
@@ -179,6 +228,7 @@ func menu() {
 		fmt.Println(" r - Read")
 		fmt.Println(" u - Update")
 		fmt.Println(" d - Delete")
+		fmt.Println(" w - Watch course changes")
 		fmt.Println(" e - Exit Console")
 		fmt.Println(" Please make a selection and hit enter.")
 
@@ -193,6 +243,8 @@ func menu() {
 			updateFunc()
 		case "d":
 			deleteFunc()
+		case "w":
+			watchFunc()
 		case "e":
 			fmt.Println("Exiting the program..")
 		default:
@@ -257,8 +309,8 @@ func createFunc() {
 	}
 	description = pol.Sanitize(description)
 
-	newCourse := CourseInfo{code, title, dates, lecturer, description}
-	addCourse(codeString, newCourse)
+	newCourse := client.CourseInfo{Code: code, Title: title, Dates: dates, Lecturer: lecturer, Description: description}
+	addCourse(newCourse)
 }
 
 //console function to read input course code
@@ -266,6 +318,7 @@ func readFunc() {
 	fmt.Println("Please select from the following")
 	fmt.Println("1. Get all courses.")
 	fmt.Println("2. Get specific course.")
+	fmt.Println("3. Search courses.")
 	var choice int
 	var code string
 	fmt.Scanln(&choice)
@@ -283,10 +336,19 @@ func readFunc() {
 		}
 		code = pol.Sanitize(code)
 		getCourse(code)
+	case 3:
+		fmt.Println("Please enter search words:")
+		reader := bufio.NewReader(os.Stdin)
+		query, _ := reader.ReadString('\n')
+		query = strings.TrimRight(query, "\n")
+		if !detailRegExp.MatchString(query) {
+			log.Error("Incorrect input format for search query detected at read function.")
+			return
+		}
+		searchCourses(pol.Sanitize(query))
 	default:
 		fmt.Println("You did not make a valid selection, please try again. Returning to main menu")
 		fmt.Println("==========================================")
-		menu()
 	}
 }
 
@@ -346,8 +408,8 @@ func updateFunc() {
 	}
 	description = pol.Sanitize(description)
 
-	updatedCourse := CourseInfo{code, title, dates, lecturer, description}
-	updateCourse(strconv.Itoa(code), updatedCourse)
+	updatedCourse := client.CourseInfo{Code: code, Title: title, Dates: dates, Lecturer: lecturer, Description: description}
+	updateCourse(updatedCourse)
 }
 
 //console function to delete input course code
@@ -365,7 +427,57 @@ func deleteFunc() {
 }
 
 func main() {
+	//settings are layered from defaults, an optional config file, environment variables (including .env) and flags
+	//the settings come before the command, which is left in args
+	var args []string
+	var err error
+	cfg, args, err = config.Load(os.Args[0], os.Args[1:])
+	if err == flag.ErrHelp {
+		usage(os.Stdout)
+		os.Exit(exitOK)
+	}
+	if err != nil {
+		log.Error("Error loading configuration: ", err)
+		os.Exit(exitUsage)
+	}
+	//these commands do not talk to the API, and login is how a missing access key is supplied
+	if len(args) == 0 || args[0] == "help" || args[0] == "login" {
+		os.Exit(runCommand(args))
+	}
+	if err = cfg.Validate(); err != nil {
+		log.Error(err)
+		os.Exit(exitUsage)
+	}
+
+	tlsConfig := &tls.Config{RootCAs: loadCA(cfg.CAFile)}
+	if cfg.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			log.Error("Error loading client certificate: ", err)
+			os.Exit(exitUsage)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
 
-	menu()
+	api = client.New(cfg.BaseURL, cfg.APIKey, tlsConfig)
+	api.Timeout, api.Retries, api.RetryBackoff = cfg.Timeout, cfg.Retries, cfg.RetryBackoff
+	api.Breaker = nil
+	if cfg.BreakerFailures > 0 {
+		api.Breaker = client.NewBreaker(cfg.BreakerFailures, cfg.BreakerCooldown)
+	}
 
+	courseAPI = &offline.Client{API: api}
+	if cfg.CacheFile != "" {
+		courseAPI.Store = openStore(cfg.CacheFile, cfg.BaseURL)
+	}
+	if courseAPI.Store == nil {
+		os.Exit(runCommand(args))
+	}
+	//edits made offline go out before anything else, so the command sees the server with them
+	if args[0] != "sync" && args[0] != "outbox" {
+		syncOutbox(context.Background(), offline.SyncOptions{})
+	}
+	status := runCommand(args)
+	courseAPI.Store.Close()
+	os.Exit(status)
 }
@@ -0,0 +1,259 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"goMS1Assignment/console/client"
+)
+
+//Freshness tells whether a read was answered by the API or from the local copy
+type Freshness struct {
+	//Stale is set when the API could not be reached and the local copy was used instead
+	Stale bool
+	//FetchedAt is when the oldest course in the answer was last sent by the API; courses created offline do not count
+	FetchedAt time.Time
+	//Err is why the API could not be reached
+	Err error
+}
+
+//Client reads and writes courses through the API, falling back to the Store while the API is unreachable:
+//reads are answered from the local copy and marked stale, and edits are queued in the outbox until Sync sends them.
+//A Client without a Store only passes requests on to the API.
+type Client struct {
+	API   *client.Client
+	Store *Store
+}
+
+//unreachable reports whether err means the API could not answer, as opposed to answering with an error
+func unreachable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+//Get returns one course from the API, or its local copy if the API cannot be reached
+func (c *Client) Get(ctx context.Context, code int) (client.CourseInfo, Freshness, error) {
+	course, etag, err := c.API.GetWithETag(ctx, code)
+	if c.Store == nil {
+		return course, Freshness{}, err
+	}
+	if unreachable(ctx, err) {
+		entry, ok, storeErr := c.Store.Get(code)
+		if storeErr != nil || !ok {
+			return course, Freshness{}, err
+		}
+		return entry.Course, Freshness{Stale: true, FetchedAt: entry.FetchedAt, Err: err}, nil
+	}
+	//a course with a queued edit keeps the local copy that shows the edit
+	if !c.pending(code) {
+		switch {
+		case err == nil:
+			c.Store.Put(Entry{Course: course, ETag: etag, FetchedAt: time.Now()})
+		case errors.Is(err, client.ErrNotFound):
+			c.Store.Remove(code)
+		}
+	}
+	return course, Freshness{FetchedAt: time.Now()}, err
+}
+
+//List returns the courses matching filter from the API, or from the local copy if the API cannot be reached
+func (c *Client) List(ctx context.Context, filter client.Filter) ([]client.CourseInfo, Freshness, error) {
+	courses, err := c.API.List(ctx, filter)
+	if c.Store == nil {
+		return courses, Freshness{}, err
+	}
+	now := time.Now()
+	if err == nil {
+		c.Store.Replace(courses, now, filter == client.Filter{})
+		return courses, Freshness{FetchedAt: now}, nil
+	}
+	if !unreachable(ctx, err) {
+		return nil, Freshness{}, err
+	}
+	entries, storeErr := c.Store.All()
+	if storeErr != nil || len(entries) == 0 {
+		return nil, Freshness{}, err
+	}
+	freshness := Freshness{Stale: true, FetchedAt: now, Err: err}
+	courses = nil
+	for _, entry := range entries {
+		if !contains(entry.Course.Lecturer, filter.Lecturer) || !contains(entry.Course.Title, filter.Title) {
+			continue
+		}
+		courses = append(courses, entry.Course)
+		if !entry.FetchedAt.IsZero() && entry.FetchedAt.Before(freshness.FetchedAt) {
+			freshness.FetchedAt = entry.FetchedAt
+		}
+	}
+	return courses, freshness, nil
+}
+
+//errDeletedOffline refuses edits of a course whose deletion is queued
+var errDeletedOffline = &client.APIError{StatusCode: http.StatusNotFound, Message: "the course was deleted offline"}
+
+//pending reports whether a course has a queued edit
+func (c *Client) pending(code int) bool {
+	_, queued, _ := c.Store.queued(code)
+	return queued
+}
+
+//contains is the API's filter match: text contains part, ignoring case
+func contains(text, part string) bool {
+	return strings.Contains(strings.ToLower(text), strings.ToLower(strings.TrimSpace(part)))
+}
+
+//Create adds a course, or queues it if the API cannot be reached, which queued reports
+func (c *Client) Create(ctx context.Context, course client.CourseInfo) (queued bool, err error) {
+	key := client.NewIdempotencyKey()
+	err = c.API.CreateWithKey(ctx, course, key)
+	if c.Store == nil || !unreachable(ctx, err) {
+		if err == nil && c.Store != nil && !c.pending(course.Code) {
+			c.Store.Put(Entry{Course: course, FetchedAt: time.Now()})
+		}
+		return false, err
+	}
+	return true, c.queue(Edit{Op: OpCreate, Course: course, IdempotencyKey: key})
+}
+
+//Update changes a course, or queues the change if the API cannot be reached, which queued reports.
+//created reports whether the API created the course because it did not exist.
+func (c *Client) Update(ctx context.Context, course client.CourseInfo) (created, queued bool, err error) {
+	created, err = c.API.Update(ctx, course)
+	if c.Store == nil || !unreachable(ctx, err) {
+		if err == nil && c.Store != nil && !c.pending(course.Code) {
+			//the reply has no body, so the local copy is refreshed next time the course is read
+			c.Store.Remove(course.Code)
+		}
+		return created, false, err
+	}
+	return false, true, c.queue(Edit{Op: OpUpdate, Course: course})
+}
+
+//Delete removes a course, or queues its removal if the API cannot be reached, which queued reports
+func (c *Client) Delete(ctx context.Context, code int) (queued bool, err error) {
+	err = c.API.Delete(ctx, code)
+	if c.Store == nil || !unreachable(ctx, err) {
+		if (err == nil || errors.Is(err, client.ErrNotFound)) && c.Store != nil && !c.pending(code) {
+			c.Store.Remove(code)
+		}
+		return false, err
+	}
+	return true, c.queue(Edit{Op: OpDelete, Course: client.CourseInfo{Code: code}})
+}
+
+//overlay returns course with the non-empty fields of changes
+func overlay(course, changes client.CourseInfo) client.CourseInfo {
+	for _, f := range []struct{ to, from *string }{
+		{&course.Title, &changes.Title},
+		{&course.Dates, &changes.Dates},
+		{&course.Lecturer, &changes.Lecturer},
+		{&course.Description, &changes.Description},
+	} {
+		if *f.from != "" {
+			*f.to = *f.from
+		}
+	}
+	return course
+}
+
+//queue puts an edit in the outbox, merged with the one already queued for the course, and applies it to the
+//local copy so reads show it. Edits that the local copy shows cannot work are refused like the API would.
+func (c *Client) queue(edit Edit) error {
+	code := edit.Course.Code
+	edit.QueuedAt = time.Now()
+	entry, cached, err := c.Store.Get(code)
+	if err != nil {
+		return err
+	}
+	pending, isQueued, err := c.Store.queued(code)
+	if err != nil {
+		return err
+	}
+	deleted := isQueued && pending.Op == OpDelete
+	//an update of a course that is not cached only holds some of its fields, too few to show
+	shown := cached || edit.Op == OpCreate
+	if cached {
+		edit.Base = &entry
+	}
+
+	switch edit.Op {
+	case OpCreate:
+		if cached {
+			return &client.APIError{StatusCode: http.StatusConflict, Message: "the course is in the offline copy already"}
+		}
+		if deleted {
+			//deleting and creating again replaces the course the API had
+			edit = Edit{Op: OpUpdate, Course: edit.Course, Base: pending.Base, QueuedAt: edit.QueuedAt}
+		}
+	case OpUpdate:
+		if deleted {
+			return errDeletedOffline
+		}
+		if isQueued {
+			pending.Course = overlay(pending.Course, edit.Course)
+			pending.QueuedAt, pending.Problem, pending.Server = edit.QueuedAt, "", nil
+			edit = pending
+		}
+	case OpDelete:
+		if deleted {
+			return errDeletedOffline
+		}
+		if isQueued && pending.Op == OpCreate {
+			//the API never had it, so there is nothing left to send
+			if err := c.Store.removeEdit(code); err != nil {
+				return err
+			}
+			return c.Store.Remove(code)
+		}
+		if isQueued {
+			edit.Base = pending.Base
+		}
+	}
+	if err := c.Store.putEdit(edit); err != nil {
+		return err
+	}
+
+	switch {
+	case edit.Op == OpDelete:
+		return c.Store.Remove(code)
+	case !shown:
+		return nil
+	}
+	local := overlay(entry.Course, edit.Course)
+	local.Code = code
+	return c.Store.Put(Entry{Course: local, FetchedAt: entry.FetchedAt})
+}
+
+//Outbox returns the edits waiting to be sent, oldest first
+func (c *Client) Outbox() ([]Edit, error) {
+	if c.Store == nil {
+		return nil, nil
+	}
+	return c.Store.Outbox()
+}
+
+//Discard drops the queued edit of a course and the local copy that showed it
+func (c *Client) Discard(code int) error {
+	if c.Store == nil {
+		return client.ErrNotFound
+	}
+	if _, ok, err := c.Store.queued(code); err != nil || !ok {
+		if err == nil {
+			err = client.ErrNotFound
+		}
+		return err
+	}
+	if err := c.Store.removeEdit(code); err != nil {
+		return err
+	}
+	return c.Store.Remove(code)
+}
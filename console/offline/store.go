@@ -0,0 +1,251 @@
+//Package offline keeps a local copy of the courses the console has fetched, so reads can still be answered while
+//the API is unreachable, and queues the edits made meanwhile in an outbox that is sent once the API is back.
+package offline
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"goMS1Assignment/console/client"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	coursesBucket = []byte("courses")
+	outboxBucket  = []byte("outbox")
+)
+
+//Entry is the local copy of one course
+type Entry struct {
+	Course    client.CourseInfo
+	ETag      string    //of the version fetched, if it was fetched on its own rather than in a list
+	FetchedAt time.Time //when the API last sent it
+}
+
+//Edit is a change made while the API was unreachable, waiting in the outbox. The outbox holds at most one edit
+//per course; later edits of the same course are merged into it.
+type Edit struct {
+	Op     string            //create, update or delete
+	Course client.CourseInfo //the course to create, or the fields to update
+	//Base is the course as it was known when the edit was made; sync checks the API still has that version.
+	//An edit of a course that was not cached has no base and is sent without the check.
+	Base           *Entry
+	IdempotencyKey string //sent with a create, so a create that was sent but not answered is not made twice
+	QueuedAt       time.Time
+	//Problem is why the last sync did not send the edit, and Server the API's version at that time
+	Problem string
+	Server  *client.CourseInfo
+}
+
+//Edit operations
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+//Store is the bbolt file the local copy and the outbox are kept in. Each API base URL has its own bucket,
+//so profiles pointing at different servers do not mix their courses.
+type Store struct {
+	db   *bolt.DB
+	root []byte
+}
+
+//Open opens or creates the store at path for the API at baseURL.
+//Only one process can have the file open; Open gives up after a second if another one does.
+func Open(path, baseURL string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{db: db, root: []byte(baseURL)}
+	err = db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(s.root)
+		if err != nil {
+			return err
+		}
+		if _, err := root.CreateBucketIfNotExists(coursesBucket); err != nil {
+			return err
+		}
+		_, err = root.CreateBucketIfNotExists(outboxBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+//Close closes the file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func key(code int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(int64(code)))
+	return b
+}
+
+func (s *Store) bucket(tx *bolt.Tx, name []byte) *bolt.Bucket {
+	return tx.Bucket(s.root).Bucket(name)
+}
+
+//view runs fn in a read transaction on one bucket
+func (s *Store) view(name []byte, fn func(b *bolt.Bucket) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return fn(s.bucket(tx, name))
+	})
+}
+
+//update runs fn in a write transaction on one bucket
+func (s *Store) update(name []byte, fn func(b *bolt.Bucket) error) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return fn(s.bucket(tx, name))
+	})
+}
+
+func put(b *bolt.Bucket, code int, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(key(code), data)
+}
+
+//Get returns the local copy of a course
+func (s *Store) Get(code int) (Entry, bool, error) {
+	var entry Entry
+	var found bool
+	err := s.view(coursesBucket, func(b *bolt.Bucket) error {
+		data := b.Get(key(code))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &entry)
+	})
+	return entry, found, err
+}
+
+//All returns every local copy in code order
+func (s *Store) All() ([]Entry, error) {
+	var entries []Entry
+	err := s.view(coursesBucket, func(b *bolt.Bucket) error {
+		return b.ForEach(func(k, data []byte) error {
+			var entry Entry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Course.Code < entries[j].Course.Code })
+	return entries, err
+}
+
+//Put stores a copy of a course
+func (s *Store) Put(entry Entry) error {
+	return s.update(coursesBucket, func(b *bolt.Bucket) error {
+		return put(b, entry.Course.Code, entry)
+	})
+}
+
+//Replace stores the courses of a listing fetched at fetchedAt. When the listing is complete, copies of courses
+//the API no longer has are dropped. Courses with a queued edit keep their local copy, which already shows the edit.
+//A copy whose course is unchanged keeps its ETag.
+func (s *Store) Replace(courses []client.CourseInfo, fetchedAt time.Time, complete bool) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, outbox := s.bucket(tx, coursesBucket), s.bucket(tx, outboxBucket)
+		listed := make(map[int]bool, len(courses))
+		for _, course := range courses {
+			listed[course.Code] = true
+			if outbox.Get(key(course.Code)) != nil {
+				continue
+			}
+			entry := Entry{Course: course, FetchedAt: fetchedAt}
+			var old Entry
+			if data := b.Get(key(course.Code)); data != nil && json.Unmarshal(data, &old) == nil && old.Course == course {
+				entry.ETag = old.ETag
+			}
+			if err := put(b, course.Code, entry); err != nil {
+				return err
+			}
+		}
+		if !complete {
+			return nil
+		}
+		var gone [][]byte
+		b.ForEach(func(k, data []byte) error {
+			var entry Entry
+			if json.Unmarshal(data, &entry) == nil && !listed[entry.Course.Code] && outbox.Get(k) == nil {
+				gone = append(gone, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		for _, k := range gone {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//Remove drops the copy of a course
+func (s *Store) Remove(code int) error {
+	return s.update(coursesBucket, func(b *bolt.Bucket) error {
+		return b.Delete(key(code))
+	})
+}
+
+//Outbox returns the queued edits, oldest first
+func (s *Store) Outbox() ([]Edit, error) {
+	var edits []Edit
+	err := s.view(outboxBucket, func(b *bolt.Bucket) error {
+		return b.ForEach(func(k, data []byte) error {
+			var edit Edit
+			if err := json.Unmarshal(data, &edit); err != nil {
+				return err
+			}
+			edits = append(edits, edit)
+			return nil
+		})
+	})
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].QueuedAt.Before(edits[j].QueuedAt) })
+	return edits, err
+}
+
+//queued returns the queued edit of a course, if any
+func (s *Store) queued(code int) (Edit, bool, error) {
+	var edit Edit
+	var found bool
+	err := s.view(outboxBucket, func(b *bolt.Bucket) error {
+		data := b.Get(key(code))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &edit)
+	})
+	return edit, found, err
+}
+
+//putEdit stores the edit of a course, replacing the one queued before
+func (s *Store) putEdit(edit Edit) error {
+	return s.update(outboxBucket, func(b *bolt.Bucket) error {
+		return put(b, edit.Course.Code, edit)
+	})
+}
+
+//removeEdit takes the edit of a course out of the outbox
+func (s *Store) removeEdit(code int) error {
+	return s.update(outboxBucket, func(b *bolt.Bucket) error {
+		return b.Delete(key(code))
+	})
+}
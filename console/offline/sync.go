@@ -0,0 +1,143 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"goMS1Assignment/console/client"
+)
+
+//SyncOptions changes how Sync sends the outbox
+type SyncOptions struct {
+	//Force sends edits without checking the API still has the version they were made on,
+	//overwriting changes made there meanwhile
+	Force bool
+	//Retry also sends edits the last sync could not, which are otherwise left for the user to look at
+	Retry bool
+}
+
+//SyncReport is what Sync did with the outbox
+type SyncReport struct {
+	Sent []Edit
+	//Conflicts are edits of courses changed on the API since the edit was made, with Server set to its version.
+	//They stay queued until sent with Force or discarded.
+	Conflicts []Edit
+	//Rejected are edits the API refused, with Problem set to why. They stay queued.
+	Rejected []Edit
+	//Left counts the edits not tried because the API could not be reached, which Offline says why
+	Left    int
+	Offline error
+}
+
+//Empty reports whether there was nothing to sync
+func (r SyncReport) Empty() bool {
+	return len(r.Sent) == 0 && len(r.Conflicts) == 0 && len(r.Rejected) == 0 && r.Left == 0
+}
+
+//errChanged is the conflict of an edit made on a version of the course the API no longer has
+var errChanged = fmt.Errorf("changed on the server since it was edited offline: %w", client.ErrConflict)
+
+//Sync sends the queued edits, oldest first, and stops at the first one the API cannot be reached for.
+//Before an edit is sent the API's version of the course is checked against the version the edit was made on,
+//by ETag when one is known and by content otherwise, so an edit does not overwrite a change it never saw.
+func (c *Client) Sync(ctx context.Context, opts SyncOptions) (SyncReport, error) {
+	var report SyncReport
+	edits, err := c.Outbox()
+	if err != nil {
+		return report, err
+	}
+	for i, edit := range edits {
+		if edit.Problem != "" && !opts.Retry {
+			continue
+		}
+		server, err := c.send(ctx, edit, opts.Force)
+		if err == nil {
+			if err := c.Store.removeEdit(edit.Course.Code); err != nil {
+				return report, err
+			}
+			report.Sent = append(report.Sent, edit)
+			//fetch the API's version, which may hold changes the local copy does not
+			c.Store.Remove(edit.Course.Code)
+			if edit.Op != OpDelete {
+				c.Get(ctx, edit.Course.Code)
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			return report, ctx.Err()
+		}
+		if unreachable(ctx, err) {
+			for _, left := range edits[i:] {
+				if left.Problem == "" || opts.Retry {
+					report.Left++
+				}
+			}
+			report.Offline = err
+			return report, nil
+		}
+		edit.Problem, edit.Server = err.Error(), server
+		if err := c.Store.putEdit(edit); err != nil {
+			return report, err
+		}
+		if errors.Is(err, client.ErrConflict) {
+			report.Conflicts = append(report.Conflicts, edit)
+		} else {
+			report.Rejected = append(report.Rejected, edit)
+		}
+	}
+	return report, nil
+}
+
+//send makes the API call of an edit, after checking for a conflict unless force is set.
+//On a conflict it returns the API's version of the course, if it has one.
+func (c *Client) send(ctx context.Context, edit Edit, force bool) (*client.CourseInfo, error) {
+	code := edit.Course.Code
+	if !force && (edit.Op == OpCreate || edit.Base != nil) {
+		current, etag, err := c.API.GetWithETag(ctx, code)
+		switch {
+		case errors.Is(err, client.ErrNotFound):
+			switch edit.Op {
+			case OpDelete:
+				//someone else deleted it already
+				return nil, nil
+			case OpUpdate:
+				return nil, fmt.Errorf("deleted on the server since it was edited offline: %w", client.ErrConflict)
+			}
+		case err != nil:
+			return nil, err
+		case edit.Op == OpCreate:
+			if current == edit.Course {
+				//an earlier sync created it but never got the reply
+				return nil, nil
+			}
+			return &current, fmt.Errorf("created on the server meanwhile: %w", client.ErrConflict)
+		case changed(*edit.Base, current, etag):
+			return &current, errChanged
+		}
+	}
+
+	var err error
+	switch edit.Op {
+	case OpCreate:
+		err = c.API.CreateWithKey(ctx, edit.Course, edit.IdempotencyKey)
+	case OpUpdate:
+		_, err = c.API.Update(ctx, edit.Course)
+	case OpDelete:
+		err = c.API.Delete(ctx, code)
+		if errors.Is(err, client.ErrNotFound) {
+			err = nil
+		}
+	default:
+		err = fmt.Errorf("unknown edit %q", edit.Op)
+	}
+	return nil, err
+}
+
+//changed reports whether the API's current version of a course differs from base
+func changed(base Entry, current client.CourseInfo, etag string) bool {
+	if base.ETag != "" && base.ETag == etag {
+		return false
+	}
+	return base.Course != current
+}
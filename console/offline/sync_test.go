@@ -0,0 +1,513 @@
+package offline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"goMS1Assignment/console/client"
+)
+
+//fakeAPI is an in-memory course API. While down it answers 503, which the client takes as unreachable;
+//with loseReplies set it makes each write and then answers 503, as if the reply was lost on the way.
+type fakeAPI struct {
+	mu          sync.Mutex
+	courses     map[int]client.CourseInfo
+	versions    map[int]int    //bumped by every write, and part of the ETag
+	keys        map[string]int //Idempotency-Key of each create made, with its status
+	writes      map[string]int //writes made, by method
+	down        bool
+	loseReplies bool
+}
+
+func newFakeAPI(courses ...client.CourseInfo) *fakeAPI {
+	f := &fakeAPI{courses: map[int]client.CourseInfo{}, versions: map[int]int{}, keys: map[string]int{}, writes: map[string]int{}}
+	for _, course := range courses {
+		f.courses[course.Code] = course
+	}
+	return f
+}
+
+func (f *fakeAPI) etag(code int) string {
+	data, _ := json.Marshal(f.courses[code])
+	sum := sha256.Sum256(append(data, byte(f.versions[code])))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/courses")
+	if path == "" {
+		json.NewEncoder(w).Encode(f.courses)
+		return
+	}
+	code, _ := strconv.Atoi(strings.TrimPrefix(path, "/"))
+	course, exists := f.courses[code]
+	status := http.StatusOK
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			status = http.StatusNotFound
+			break
+		}
+		w.Header().Set("ETag", f.etag(code))
+		json.NewEncoder(w).Encode(course)
+		return
+	case http.MethodPost:
+		key := r.Header.Get("Idempotency-Key")
+		if replayed, ok := f.keys[key]; ok && key != "" {
+			status = replayed
+			break
+		}
+		status = http.StatusCreated
+		if exists {
+			status = http.StatusConflict
+		} else {
+			json.NewDecoder(r.Body).Decode(&course)
+			f.write(r.Method, course)
+		}
+		f.keys[key] = status
+	case http.MethodPut:
+		var changes client.CourseInfo
+		json.NewDecoder(r.Body).Decode(&changes)
+		status = http.StatusAccepted
+		if !exists {
+			status = http.StatusCreated
+		}
+		f.write(r.Method, overlay(course, changes))
+	case http.MethodDelete:
+		if !exists {
+			status = http.StatusNotFound
+			break
+		}
+		f.writes[r.Method]++
+		delete(f.courses, code)
+		f.versions[code]++
+	}
+	if f.loseReplies && status < 400 {
+		status = http.StatusServiceUnavailable
+	}
+	w.WriteHeader(status)
+}
+
+func (f *fakeAPI) write(method string, course client.CourseInfo) {
+	f.writes[method]++
+	f.courses[course.Code] = course
+	f.versions[course.Code]++
+}
+
+//set changes the API's state, as another client would
+func (f *fakeAPI) set(fn func(f *fakeAPI)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fn(f)
+}
+
+func (f *fakeAPI) course(code int) (client.CourseInfo, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	course, ok := f.courses[code]
+	return course, ok
+}
+
+//newTestClient returns a Client with a fresh store in front of api
+func newTestClient(t *testing.T, api *fakeAPI) *Client {
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	store, err := Open(filepath.Join(t.TempDir(), "offline.db"), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	c := client.New(srv.URL, "k", nil)
+	c.Retries = 0
+	c.Breaker = nil
+	return &Client{API: c, Store: store}
+}
+
+var (
+	goCourse   = client.CourseInfo{Code: 1, Title: "Go", Dates: "Jan", Lecturer: "Tan", Description: "intro"}
+	rustCourse = client.CourseInfo{Code: 2, Title: "Rust", Dates: "Feb", Lecturer: "Lee", Description: "systems"}
+)
+
+func outbox(t *testing.T, c *Client) []Edit {
+	edits, err := c.Outbox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return edits
+}
+
+//TestOfflineReads checks reads fall back to the local copy, marked stale, only while the API is unreachable
+func TestOfflineReads(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeAPI(goCourse, rustCourse)
+	c := newTestClient(t, api)
+
+	if _, _, err := c.List(ctx, client.Filter{}); err != nil {
+		t.Fatal(err)
+	}
+	api.set(func(f *fakeAPI) { f.down = true })
+
+	courses, freshness, err := c.List(ctx, client.Filter{Lecturer: "lee"})
+	if err != nil || !freshness.Stale || freshness.FetchedAt.IsZero() || freshness.Err == nil {
+		t.Fatalf("List offline: %v, freshness %+v", err, freshness)
+	}
+	if len(courses) != 1 || courses[0] != rustCourse {
+		t.Errorf("List offline filtered by lecturer: %+v", courses)
+	}
+	if course, freshness, err := c.Get(ctx, 1); err != nil || !freshness.Stale || course != goCourse {
+		t.Errorf("Get offline: %+v, %+v, %v", course, freshness, err)
+	}
+	if _, _, err := c.Get(ctx, 9); err == nil {
+		t.Error("Get offline of a course never fetched did not fail")
+	}
+
+	//an answer from the API, even an error, is not replaced by the local copy
+	api.set(func(f *fakeAPI) { f.down = false; delete(f.courses, 2) })
+	if _, _, err := c.Get(ctx, 2); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("Get of a course deleted on the API: %v, want ErrNotFound", err)
+	}
+	api.set(func(f *fakeAPI) { f.down = true })
+	if _, _, err := c.Get(ctx, 2); err == nil {
+		t.Error("the local copy of a course the API reported deleted is still served")
+	}
+}
+
+//TestQueueMerge checks how an edit made offline combines with the one already queued for the course
+func TestQueueMerge(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name   string
+		edits  func(c *Client) error
+		wantOp string //empty when nothing is left queued
+		want   client.CourseInfo
+		shown  bool //whether the local copy has the course
+	}{
+		{"update merged into a queued create", func(c *Client) error {
+			if _, err := c.Create(ctx, client.CourseInfo{Code: 3, Title: "Zig", Dates: "Mar", Lecturer: "Ng", Description: "new"}); err != nil {
+				return err
+			}
+			_, _, err := c.Update(ctx, client.CourseInfo{Code: 3, Dates: "Apr"})
+			return err
+		}, OpCreate, client.CourseInfo{Code: 3, Title: "Zig", Dates: "Apr", Lecturer: "Ng", Description: "new"}, true},
+		{"delete cancels a queued create", func(c *Client) error {
+			if _, err := c.Create(ctx, client.CourseInfo{Code: 3, Title: "Zig", Dates: "Mar", Lecturer: "Ng", Description: "new"}); err != nil {
+				return err
+			}
+			_, err := c.Delete(ctx, 3)
+			return err
+		}, "", client.CourseInfo{Code: 3}, false},
+		{"updates merged", func(c *Client) error {
+			if _, _, err := c.Update(ctx, client.CourseInfo{Code: 1, Title: "Go 2"}); err != nil {
+				return err
+			}
+			_, _, err := c.Update(ctx, client.CourseInfo{Code: 1, Lecturer: "Lim"})
+			return err
+		}, OpUpdate, client.CourseInfo{Code: 1, Title: "Go 2", Lecturer: "Lim"}, true},
+		{"delete after an update", func(c *Client) error {
+			if _, _, err := c.Update(ctx, client.CourseInfo{Code: 1, Title: "Go 2"}); err != nil {
+				return err
+			}
+			_, err := c.Delete(ctx, 1)
+			return err
+		}, OpDelete, client.CourseInfo{Code: 1}, false},
+		{"delete then create", func(c *Client) error {
+			if _, err := c.Delete(ctx, 1); err != nil {
+				return err
+			}
+			_, err := c.Create(ctx, client.CourseInfo{Code: 1, Title: "Go again", Dates: "May", Lecturer: "Ng", Description: "redo"})
+			return err
+		}, OpUpdate, client.CourseInfo{Code: 1, Title: "Go again", Dates: "May", Lecturer: "Ng", Description: "redo"}, true},
+	}
+	for _, tt := range tests {
+		api := newFakeAPI(goCourse)
+		c := newTestClient(t, api)
+		c.Get(ctx, 1)
+		api.set(func(f *fakeAPI) { f.down = true })
+
+		if err := tt.edits(c); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		edits := outbox(t, c)
+		switch {
+		case tt.wantOp == "" && len(edits) != 0:
+			t.Errorf("%s: %d edits queued, want none", tt.name, len(edits))
+		case tt.wantOp != "" && (len(edits) != 1 || edits[0].Op != tt.wantOp || edits[0].Course != tt.want):
+			t.Errorf("%s: queued %+v, want one %s of %+v", tt.name, edits, tt.wantOp, tt.want)
+		}
+		if len(edits) == 1 && edits[0].Op != OpCreate && (edits[0].Base == nil || edits[0].Base.Course != goCourse) {
+			t.Errorf("%s: base %+v, want the course as fetched", tt.name, edits[0].Base)
+		}
+		if _, shown, _ := c.Store.Get(tt.want.Code); shown != tt.shown {
+			t.Errorf("%s: local copy present %v, want %v", tt.name, shown, tt.shown)
+		}
+	}
+}
+
+//TestQueueRefusals checks edits the local copy shows cannot work are refused straight away
+func TestQueueRefusals(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeAPI(goCourse)
+	c := newTestClient(t, api)
+	c.Get(ctx, 1)
+	api.set(func(f *fakeAPI) { f.down = true })
+
+	if _, err := c.Create(ctx, goCourse); !errors.Is(err, client.ErrConflict) {
+		t.Errorf("create of a cached course: %v, want ErrConflict", err)
+	}
+	c.Delete(ctx, 1)
+	if _, _, err := c.Update(ctx, client.CourseInfo{Code: 1, Title: "X"}); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("update of a course deleted offline: %v, want ErrNotFound", err)
+	}
+	if _, err := c.Delete(ctx, 1); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("second delete: %v, want ErrNotFound", err)
+	}
+}
+
+//TestSyncSends checks queued edits reach the API once it is back, and the outbox empties
+func TestSyncSends(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeAPI(goCourse, rustCourse)
+	c := newTestClient(t, api)
+	c.List(ctx, client.Filter{})
+	api.set(func(f *fakeAPI) { f.down = true })
+
+	zig := client.CourseInfo{Code: 3, Title: "Zig", Dates: "Mar", Lecturer: "Ng", Description: "new"}
+	c.Create(ctx, zig)
+	c.Update(ctx, client.CourseInfo{Code: 1, Title: "Go 2"})
+	c.Delete(ctx, 2)
+	//delete then create of the same course is sent as one update
+	c.Delete(ctx, 1)
+	c.Create(ctx, client.CourseInfo{Code: 1, Title: "Go 3", Dates: "May", Lecturer: "Lim", Description: "redo"})
+
+	report, err := c.Sync(ctx, SyncOptions{})
+	if err != nil || report.Left != 3 || report.Offline == nil || len(report.Sent) != 0 {
+		t.Fatalf("sync while down: %+v, %v", report, err)
+	}
+
+	api.set(func(f *fakeAPI) { f.down = false })
+	report, err = c.Sync(ctx, SyncOptions{})
+	if err != nil || len(report.Sent) != 3 || len(report.Conflicts)+len(report.Rejected)+report.Left != 0 {
+		t.Fatalf("sync: %+v, %v", report, err)
+	}
+	if len(outbox(t, c)) != 0 {
+		t.Error("outbox not empty after a full sync")
+	}
+	if course, _ := api.course(3); course != zig {
+		t.Errorf("created course on the API: %+v", course)
+	}
+	if course, _ := api.course(1); course.Title != "Go 3" || course.Description != "redo" {
+		t.Errorf("recreated course on the API: %+v", course)
+	}
+	if _, ok := api.course(2); ok {
+		t.Error("deleted course still on the API")
+	}
+	if api.writes[http.MethodDelete] != 1 {
+		t.Errorf("%d deletes sent, want 1: delete then create is one update", api.writes[http.MethodDelete])
+	}
+	//the local copy now follows the API again
+	if entry, ok, _ := c.Store.Get(1); !ok || entry.Course.Title != "Go 3" || entry.ETag == "" {
+		t.Errorf("local copy after sync: %+v, %v", entry, ok)
+	}
+}
+
+//TestSyncLostReply checks a create that reached the API but whose reply was lost is not made twice
+func TestSyncLostReply(t *testing.T) {
+	ctx := context.Background()
+	zig := client.CourseInfo{Code: 3, Title: "Zig", Dates: "Mar", Lecturer: "Ng", Description: "new"}
+	for _, force := range []bool{false, true} {
+		api := newFakeAPI()
+		c := newTestClient(t, api)
+		api.set(func(f *fakeAPI) { f.loseReplies = true })
+		queued, err := c.Create(ctx, zig)
+		if err != nil || !queued {
+			t.Fatalf("create with a lost reply: queued %v, %v", queued, err)
+		}
+		api.set(func(f *fakeAPI) { f.loseReplies = false })
+
+		//without force the check finds the course already there; with force the replayed key answers
+		report, err := c.Sync(ctx, SyncOptions{Force: force})
+		if err != nil || len(report.Sent) != 1 {
+			t.Errorf("force %v: sync %+v, %v", force, report, err)
+		}
+		if n := api.writes[http.MethodPost]; n != 1 {
+			t.Errorf("force %v: course created %d times", force, n)
+		}
+	}
+}
+
+//TestSyncConflicts checks an edit is held back when the API's course is not the version it was made on,
+//judged by ETag when the base has one and by content otherwise
+func TestSyncConflicts(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		fetch    func(c *Client) //how the base was fetched: Get stores an ETag, List does not
+		meantime func(f *fakeAPI)
+		edit     func(c *Client)
+		conflict bool
+	}{
+		{"ETag unchanged", getOne, nil, updateTitle, false},
+		{"ETag changed, content changed", getOne, changeDates, updateTitle, true},
+		{"ETag changed, content the same", getOne, rewriteSame, updateTitle, false},
+		{"no ETag, content unchanged", listAll, rewriteSame, updateTitle, false},
+		{"no ETag, content changed", listAll, changeDates, updateTitle, true},
+		{"deleted meanwhile, update", getOne, deleteOne, updateTitle, true},
+		{"deleted meanwhile, delete", getOne, deleteOne, deleteOneOffline, false},
+		{"changed meanwhile, delete", getOne, changeDates, deleteOneOffline, true},
+		{"already on the server, different", nil, createOther, createNew, true},
+		{"already on the server, identical", nil, createSame, createNew, false},
+	}
+	for _, tt := range tests {
+		api := newFakeAPI(goCourse)
+		c := newTestClient(t, api)
+		if tt.fetch != nil {
+			tt.fetch(c)
+		}
+		api.set(func(f *fakeAPI) { f.down = true })
+		tt.edit(c)
+		api.set(func(f *fakeAPI) { f.down = false })
+		if tt.meantime != nil {
+			api.set(tt.meantime)
+		}
+		before, _ := api.course(1)
+
+		report, err := c.Sync(ctx, SyncOptions{})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := len(report.Conflicts) == 1; got != tt.conflict {
+			t.Errorf("%s: conflict %v, want %v: %+v", tt.name, got, tt.conflict, report)
+			continue
+		}
+		if !tt.conflict {
+			continue
+		}
+		edit := report.Conflicts[0]
+		if edit.Problem == "" {
+			t.Errorf("%s: conflict without a problem", tt.name)
+		}
+		if after, _ := api.course(1); after != before {
+			t.Errorf("%s: the API's course changed despite the conflict: %+v", tt.name, after)
+		}
+		if server, exists := api.course(1); exists && (edit.Server == nil || *edit.Server != server) {
+			t.Errorf("%s: conflict shows server version %+v, want %+v", tt.name, edit.Server, server)
+		}
+		if queued := outbox(t, c); len(queued) != 1 || queued[0].Problem == "" {
+			t.Errorf("%s: outbox %+v, want the edit kept with its problem", tt.name, queued)
+		}
+	}
+}
+
+func getOne(c *Client)  { c.Get(context.Background(), 1) }
+func listAll(c *Client) { c.List(context.Background(), client.Filter{}) }
+
+func updateTitle(c *Client) {
+	c.Update(context.Background(), client.CourseInfo{Code: 1, Title: "Go 2"})
+}
+func deleteOneOffline(c *Client) { c.Delete(context.Background(), 1) }
+func createNew(c *Client) {
+	c.Create(context.Background(), client.CourseInfo{Code: 1, Title: "Go", Dates: "Jan", Lecturer: "Tan", Description: "intro"})
+}
+
+func changeDates(f *fakeAPI) { course := f.courses[1]; course.Dates = "Dec"; f.write("test", course) }
+func rewriteSame(f *fakeAPI) { f.write("test", f.courses[1]) }
+func deleteOne(f *fakeAPI)   { delete(f.courses, 1); f.versions[1]++ }
+func createSame(f *fakeAPI)  { f.write("test", goCourse) }
+func createOther(f *fakeAPI) {
+	course := goCourse
+	course.Title = "Other"
+	f.write("test", course)
+}
+
+//TestSyncForceRetry checks edits held back by a conflict are left alone by a plain sync, tried again with Retry
+//and sent over the API's change with Force
+func TestSyncForceRetry(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeAPI(goCourse)
+	c := newTestClient(t, api)
+	c.Get(ctx, 1)
+	api.set(func(f *fakeAPI) { f.down = true })
+	c.Update(ctx, client.CourseInfo{Code: 1, Title: "Go 2"})
+	api.set(func(f *fakeAPI) { f.down = false })
+	api.set(changeDates)
+
+	if report, _ := c.Sync(ctx, SyncOptions{}); len(report.Conflicts) != 1 {
+		t.Fatalf("first sync: %+v, want a conflict", report)
+	}
+	if report, _ := c.Sync(ctx, SyncOptions{}); !report.Empty() {
+		t.Errorf("plain sync tried the held back edit again: %+v", report)
+	}
+	if report, _ := c.Sync(ctx, SyncOptions{Retry: true}); len(report.Conflicts) != 1 {
+		t.Errorf("retry: %+v, want the conflict again", report)
+	}
+	if report, _ := c.Sync(ctx, SyncOptions{Force: true}); !report.Empty() {
+		t.Errorf("force without retry sent the held back edit: %+v", report)
+	}
+	report, err := c.Sync(ctx, SyncOptions{Force: true, Retry: true})
+	if err != nil || len(report.Sent) != 1 {
+		t.Fatalf("force and retry: %+v, %v", report, err)
+	}
+	//the update only overwrote the field it changed
+	if course, _ := api.course(1); course.Title != "Go 2" || course.Dates != "Dec" {
+		t.Errorf("API course after the forced update: %+v", course)
+	}
+	if len(outbox(t, c)) != 0 {
+		t.Error("outbox not empty")
+	}
+}
+
+//TestSyncRejected checks an edit the API refuses is kept with the reason, apart from conflicts
+func TestSyncRejected(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		fmt.Fprint(w, `{"Message":"title too long"}`)
+	}))
+	defer srv.Close()
+	c := newTestClient(t, newFakeAPI())
+	c.API.BaseURL = srv.URL
+	//queued directly, as if made offline
+	if err := c.queue(Edit{Op: OpCreate, Course: client.CourseInfo{Code: 5, Title: "T"}, IdempotencyKey: "k5"}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := c.Sync(ctx, SyncOptions{})
+	if err != nil || len(report.Rejected) != 1 || len(report.Conflicts) != 0 {
+		t.Fatalf("sync: %+v, %v", report, err)
+	}
+	if !strings.Contains(report.Rejected[0].Problem, "title too long") {
+		t.Errorf("problem %q does not give the API's reason", report.Rejected[0].Problem)
+	}
+	if err := c.Discard(5); err != nil {
+		t.Fatal(err)
+	}
+	if len(outbox(t, c)) != 0 {
+		t.Error("discarded edit still queued")
+	}
+	if _, ok, _ := c.Store.Get(5); ok {
+		t.Error("local copy of the discarded create still there")
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"goMS1Assignment/console/client"
+
+	"gopkg.in/yaml.v2"
+)
+
+//column is one course field that can be shown, selected and sorted on
+type column struct {
+	name  string
+	value func(client.CourseInfo) string
+	less  func(a, b client.CourseInfo) bool
+}
+
+var courseColumns = []column{
+	{"Code", func(c client.CourseInfo) string { return strconv.Itoa(c.Code) }, func(a, b client.CourseInfo) bool { return a.Code < b.Code }},
+	{"Title", func(c client.CourseInfo) string { return c.Title }, nil},
+	{"Dates", func(c client.CourseInfo) string { return c.Dates }, nil},
+	{"Lecturer", func(c client.CourseInfo) string { return c.Lecturer }, nil},
+	{"Description", func(c client.CourseInfo) string { return c.Description }, nil},
+}
+
+//findColumn looks a column up by name, ignoring case
+func findColumn(name string) (column, bool) {
+	for _, c := range courseColumns {
+		if strings.EqualFold(c.name, strings.TrimSpace(name)) {
+			return c, true
+		}
+	}
+	return column{}, false
+}
+
+//compare orders two courses by the column, as numbers for the code and without regard to case for text
+func (c column) compare(a, b client.CourseInfo) int {
+	if c.less != nil {
+		switch {
+		case c.less(a, b):
+			return -1
+		case c.less(b, a):
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(strings.ToLower(c.value(a)), strings.ToLower(c.value(b)))
+}
+
+//sortKey is a column to sort on and its direction
+type sortKey struct {
+	column     column
+	descending bool
+}
+
+//outputOptions are the flags that choose how courses are printed
+type outputOptions struct {
+	format   string
+	text     string
+	columns  string
+	sortBy   string
+	wrap     int
+	template *template.Template
+	selected []column
+	keys     []sortKey
+}
+
+//outputFlags adds the output flags to a command
+func outputFlags(fs *flag.FlagSet) *outputOptions {
+	o := &outputOptions{}
+	fs.StringVar(&o.format, "output", "table", "output format: table, json, yaml, csv or template")
+	fs.StringVar(&o.text, "template", "", "Go text/template run for each course with -output template, such as '{{.Code}} {{.Title}}'")
+	fs.StringVar(&o.columns, "columns", "code,title,dates,lecturer,description", "comma separated columns for table and csv output")
+	fs.StringVar(&o.sortBy, "sort", "", "comma separated columns to sort on; prefix one with - to sort it in descending order")
+	fs.IntVar(&o.wrap, "wrap", 40, "wrap table cells longer than this many characters, 0 for no wrapping")
+	return o
+}
+
+//check validates the output flags and prepares the columns, sort order and template
+func (o *outputOptions) check() error {
+	switch o.format {
+	case "table", "json", "yaml", "csv":
+	case "template":
+		if o.text == "" {
+			return errors.New("-output template needs -template")
+		}
+		t, err := template.New("course").Option("missingkey=error").Parse(o.text)
+		if err != nil {
+			return err
+		}
+		//a field that does not exist only shows up when the template runs
+		if err := t.Execute(ioutil.Discard, client.CourseInfo{}); err != nil {
+			return err
+		}
+		o.template = t
+	default:
+		return fmt.Errorf("output format %q is not table, json, yaml, csv or template", o.format)
+	}
+	o.selected = nil
+	for _, name := range strings.Split(o.columns, ",") {
+		c, ok := findColumn(name)
+		if !ok {
+			return fmt.Errorf("unknown column %q", name)
+		}
+		o.selected = append(o.selected, c)
+	}
+	o.keys = nil
+	if o.sortBy != "" {
+		for _, name := range strings.Split(o.sortBy, ",") {
+			name = strings.TrimSpace(name)
+			key := sortKey{descending: strings.HasPrefix(name, "-")}
+			c, ok := findColumn(strings.TrimPrefix(name, "-"))
+			if !ok {
+				return fmt.Errorf("unknown sort column %q", name)
+			}
+			key.column = c
+			o.keys = append(o.keys, key)
+		}
+	}
+	if o.wrap < 0 {
+		return errors.New("-wrap must not be negative")
+	}
+	return nil
+}
+
+//defaultOutput is a table of every column, used by the interactive menu
+func defaultOutput() *outputOptions {
+	o := &outputOptions{format: "table", columns: "code,title,dates,lecturer,description", wrap: 40}
+	o.check()
+	return o
+}
+
+//write prints the courses in the chosen format. single prints one course as an object rather than a list.
+func (o *outputOptions) write(w io.Writer, courses []client.CourseInfo, single bool) error {
+	if len(o.keys) > 0 {
+		sort.SliceStable(courses, func(i, j int) bool {
+			for _, key := range o.keys {
+				if c := key.column.compare(courses[i], courses[j]); c != 0 {
+					return (c < 0) != key.descending
+				}
+			}
+			return false
+		})
+	}
+	var v interface{} = courses
+	if single && len(courses) == 1 {
+		v = courses[0]
+	}
+	switch o.format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case "yaml":
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case "csv":
+		return o.writeCSV(w, courses)
+	case "template":
+		for _, course := range courses {
+			var out strings.Builder
+			if err := o.template.Execute(&out, course); err != nil {
+				return err
+			}
+			if !strings.HasSuffix(out.String(), "\n") {
+				out.WriteString("\n")
+			}
+			if _, err := io.WriteString(w, out.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return o.writeTable(w, courses)
+}
+
+//writeCSV writes the selected columns with a header row, in the format the import endpoint reads
+func (o *outputOptions) writeCSV(w io.Writer, courses []client.CourseInfo) error {
+	writer := csv.NewWriter(w)
+	record := make([]string, len(o.selected))
+	for i, c := range o.selected {
+		record[i] = c.name
+	}
+	writer.Write(record)
+	for _, course := range courses {
+		for i, c := range o.selected {
+			record[i] = c.value(course)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+//writeTable writes the selected columns aligned under an upper case header, wrapping long cells onto more lines
+func (o *outputOptions) writeTable(w io.Writer, courses []client.CourseInfo) error {
+	rows := make([][][]string, 0, len(courses)+1) //row, column, line
+	header := make([][]string, len(o.selected))
+	for i, c := range o.selected {
+		header[i] = []string{strings.ToUpper(c.name)}
+	}
+	rows = append(rows, header)
+	for _, course := range courses {
+		row := make([][]string, len(o.selected))
+		for i, c := range o.selected {
+			row[i] = wrapText(c.value(course), o.wrap)
+		}
+		rows = append(rows, row)
+	}
+
+	widths := make([]int, len(o.selected))
+	for _, row := range rows {
+		for i, cell := range row {
+			for _, line := range cell {
+				if n := utf8.RuneCountInString(line); n > widths[i] {
+					widths[i] = n
+				}
+			}
+		}
+	}
+
+	var out strings.Builder
+	for _, row := range rows {
+		lines := 1
+		for _, cell := range row {
+			if len(cell) > lines {
+				lines = len(cell)
+			}
+		}
+		for l := 0; l < lines; l++ {
+			var line strings.Builder
+			for i, cell := range row {
+				text := ""
+				if l < len(cell) {
+					text = cell[l]
+				}
+				if i > 0 {
+					line.WriteString("  ")
+				}
+				line.WriteString(text)
+				line.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(text)))
+			}
+			out.WriteString(strings.TrimRight(line.String(), " "))
+			out.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
+
+//wrapText breaks s into lines of at most width characters at spaces, splitting words that are longer.
+//A width of 0 keeps s on one line.
+func wrapText(s string, width int) []string {
+	words := strings.Fields(s)
+	if width == 0 || utf8.RuneCountInString(s) <= width {
+		return []string{strings.Join(words, " ")}
+	}
+	var lines []string
+	line := ""
+	for _, word := range words {
+		for utf8.RuneCountInString(word) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			runes := []rune(word)
+			lines = append(lines, string(runes[:width]))
+			word = string(runes[width:])
+		}
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
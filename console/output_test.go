@@ -0,0 +1,146 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"goMS1Assignment/console/client"
+)
+
+//TestWrapText checks cells are broken at spaces and words longer than the width are split, counting letters not bytes
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  []string
+	}{
+		{"", 10, []string{""}},
+		{"short", 10, []string{"short"}},
+		{"exactly ten", 11, []string{"exactly ten"}},
+		{"one two three four", 9, []string{"one two", "three", "four"}},
+		{"microservices", 5, []string{"micro", "servi", "ces"}},
+		{"go microservices now", 6, []string{"go", "micros", "ervice", "s now"}},
+		{"café crème brûlée", 10, []string{"café crème", "brûlée"}},
+		{"spaces   are    collapsed", 0, []string{"spaces are collapsed"}},
+		{"a long line kept whole", 0, []string{"a long line kept whole"}},
+	}
+	for _, tt := range tests {
+		if got := wrapText(tt.s, tt.width); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("wrapText(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+//TestOutputCheck checks the output flags are validated before any request is made
+func TestOutputCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		options outputOptions
+		wantErr string
+	}{
+		{"table", outputOptions{format: "table", columns: "code,title"}, ""},
+		{"columns in any case with spaces", outputOptions{format: "csv", columns: "CODE, Lecturer"}, ""},
+		{"unknown format", outputOptions{format: "xml", columns: "code"}, `output format "xml"`},
+		{"unknown column", outputOptions{format: "table", columns: "code,room"}, `unknown column "room"`},
+		{"unknown sort column", outputOptions{format: "table", columns: "code", sortBy: "title,-room"}, `unknown sort column "-room"`},
+		{"negative wrap", outputOptions{format: "table", columns: "code", wrap: -1}, "-wrap"},
+		{"template", outputOptions{format: "template", text: "{{.Code}} {{.Title}}", columns: "code"}, ""},
+		{"template missing", outputOptions{format: "template", columns: "code"}, "needs -template"},
+		{"template that does not parse", outputOptions{format: "template", text: "{{.Code", columns: "code"}, "unclosed action"},
+		{"template with an unknown field", outputOptions{format: "template", text: "{{.Room}}", columns: "code"}, "Room"},
+	}
+	for _, tt := range tests {
+		o := tt.options
+		err := o.check()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: %v, want an error containing %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+var outputCourses = []client.CourseInfo{
+	{Code: 10, Title: "Go", Dates: "Jan", Lecturer: "tan", Description: "basics"},
+	{Code: 2, Title: "Rust", Dates: "Feb", Lecturer: "Lee", Description: "ownership and borrowing"},
+	{Code: 9, Title: "Zig", Dates: "Mar", Lecturer: "Tan", Description: "comptime"},
+}
+
+//TestOutputWrite checks each format prints the selected columns, sorted on several keys with - for descending
+func TestOutputWrite(t *testing.T) {
+	tests := []struct {
+		format, text, columns, sortBy string
+		wrap                          int
+		want                          string
+	}{
+		//lecturers descending ignoring case, then codes ascending as numbers
+		{"table", "", "code,lecturer,description", "-lecturer,code", 12,
+			"CODE  LECTURER  DESCRIPTION\n" +
+				"9     Tan       comptime\n" +
+				"10    tan       basics\n" +
+				"2     Lee       ownership\n" +
+				"                and\n" +
+				"                borrowing\n"},
+		{"table", "", "title", "-code", 0, "TITLE\nGo\nZig\nRust\n"},
+		{"csv", "", "code,title", "title", 0, "Code,Title\n10,Go\n2,Rust\n9,Zig\n"},
+		{"csv", "", "Lecturer , code", "lecturer,-code", 0, "Lecturer,Code\nLee,2\ntan,10\nTan,9\n"},
+		{"template", "{{.Code}}: {{.Title}}", "code", "code", 0, "2: Rust\n9: Zig\n10: Go\n"},
+		{"template", "{{.Title}}\n", "code", "-dates", 0, "Zig\nGo\nRust\n"},
+		{"json", "", "code", "-code", 0, `[
+  {
+    "Code": 10,
+    "Title": "Go",
+    "Dates": "Jan",
+    "Lecturer": "tan",
+    "Description": "basics"
+  },
+  {
+    "Code": 9,
+    "Title": "Zig",
+    "Dates": "Mar",
+    "Lecturer": "Tan",
+    "Description": "comptime"
+  },
+  {
+    "Code": 2,
+    "Title": "Rust",
+    "Dates": "Feb",
+    "Lecturer": "Lee",
+    "Description": "ownership and borrowing"
+  }
+]
+`},
+		{"yaml", "", "code", "code", 0, "- code: 2\n  title: Rust\n  dates: Feb\n  lecturer: Lee\n  description: ownership and borrowing\n" +
+			"- code: 9\n  title: Zig\n  dates: Mar\n  lecturer: Tan\n  description: comptime\n" +
+			"- code: 10\n  title: Go\n  dates: Jan\n  lecturer: tan\n  description: basics\n"},
+	}
+	for _, tt := range tests {
+		o := &outputOptions{format: tt.format, text: tt.text, columns: tt.columns, sortBy: tt.sortBy, wrap: tt.wrap}
+		if err := o.check(); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		courses := append([]client.CourseInfo(nil), outputCourses...)
+		var out strings.Builder
+		if err := o.write(&out, courses, false); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s sorted by %s:\n%s\nwant\n%s", tt.format, tt.sortBy, out.String(), tt.want)
+		}
+	}
+}
+
+//TestOutputSingle checks one course asked for on its own is printed as an object rather than a list
+func TestOutputSingle(t *testing.T) {
+	o := &outputOptions{format: "json", columns: "code"}
+	if err := o.check(); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	o.write(&out, outputCourses[:1], true)
+	if !strings.HasPrefix(out.String(), "{") {
+		t.Errorf("single course printed as %s", out.String())
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"goMS1Assignment/console/client"
+	"goMS1Assignment/console/offline"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+//browserHelp is shown under the status bar
+const browserHelp = "[yellow]/[-] search  [yellow]n[-] new  [yellow]e[-]/[yellow]enter[-] edit  [yellow]d[-] delete  [yellow]r[-] reload  [yellow]q[-] quit"
+
+//courseBrowser is the full screen course browser of the tui command.
+//Requests run in the background and hand their results back to the UI goroutine with QueueUpdateDraw,
+//so courses and shown are only touched from there.
+type courseBrowser struct {
+	ctx    context.Context
+	app    *tview.Application
+	pages  *tview.Pages
+	search *tview.InputField
+	list   *tview.Table
+	detail *tview.TextView
+	status *tview.TextView
+
+	courses []client.CourseInfo //every course, in code order
+	shown   []client.CourseInfo //the courses matching the search, one per list row after the header
+}
+
+func tuiCommand(ctx context.Context, args []string) int {
+	fs := newFlagSet("tui", "")
+	if ok, status := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, errors.New("unexpected argument "+fs.Arg(0)))
+	}
+	b := newCourseBrowser(ctx)
+	b.reload()
+	if err := b.app.Run(); err != nil {
+		fmt.Fprintln(fs.Output(), "tui:", err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+func newCourseBrowser(ctx context.Context) *courseBrowser {
+	b := &courseBrowser{ctx: ctx, app: tview.NewApplication()}
+
+	b.search = tview.NewInputField().SetLabel("Search: ").SetChangedFunc(func(string) { b.filter() })
+	b.search.SetDoneFunc(func(tcell.Key) { b.app.SetFocus(b.list) })
+
+	b.list = tview.NewTable().SetSelectable(true, false).SetFixed(1, 0)
+	b.list.SetSelectionChangedFunc(func(row, column int) { b.showDetail() })
+	b.list.SetSelectedFunc(func(row, column int) { b.edit() })
+	b.list.SetInputCapture(b.listKeys)
+	b.list.SetBorder(true).SetTitle(" Courses ")
+
+	b.detail = tview.NewTextView().SetDynamicColors(true).SetWordWrap(true)
+	b.detail.SetBorder(true).SetTitle(" Details ")
+
+	b.status = tview.NewTextView().SetDynamicColors(true)
+	help := tview.NewTextView().SetDynamicColors(true).SetText(browserHelp)
+
+	panes := tview.NewFlex().
+		AddItem(b.list, 0, 3, true).
+		AddItem(b.detail, 0, 2, false)
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(b.search, 1, 0, false).
+		AddItem(panes, 0, 1, true).
+		AddItem(b.status, 1, 0, false).
+		AddItem(help, 1, 0, false)
+	b.pages = tview.NewPages().AddPage("main", layout, true, true)
+	b.app.SetRoot(b.pages, true).SetFocus(b.list)
+	return b
+}
+
+//listKeys handles the single key commands of the course list
+func (b *courseBrowser) listKeys(event *tcell.EventKey) *tcell.EventKey {
+	if event.Key() == tcell.KeyEscape && b.search.GetText() != "" {
+		b.search.SetText("")
+		return nil
+	}
+	switch event.Rune() {
+	case '/':
+		b.app.SetFocus(b.search)
+	case 'n':
+		b.openForm(nil)
+	case 'e':
+		b.edit()
+	case 'd':
+		b.confirmDelete()
+	case 'r':
+		b.reload()
+	case 'q':
+		b.app.Stop()
+	default:
+		return event
+	}
+	return nil
+}
+
+//setStatus shows a message in the status bar
+func (b *courseBrowser) setStatus(message string) {
+	b.status.SetText(tview.Escape(message))
+}
+
+//fail shows a failed request in the status bar
+func (b *courseBrowser) fail(action string, err error) {
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) {
+		b.status.SetText("[red]" + tview.Escape(action+" failed: "+apiErr.Error()))
+		return
+	}
+	b.status.SetText("[red]" + tview.Escape(action+" failed, the server could not be reached: "+err.Error()))
+}
+
+//reload sends the edits made offline and fetches every course again.
+//While the server cannot be reached the local copy is shown instead, with a warning.
+func (b *courseBrowser) reload() {
+	b.setStatus("Loading courses...")
+	go func() {
+		var report offline.SyncReport
+		if courseAPI.Store != nil {
+			report, _ = courseAPI.Sync(b.ctx, offline.SyncOptions{})
+		}
+		courses, freshness, err := courseAPI.List(b.ctx, client.Filter{})
+		b.app.QueueUpdateDraw(func() {
+			if err != nil {
+				b.fail("Loading courses", err)
+				return
+			}
+			b.courses = courses
+			b.filter()
+			switch {
+			case freshness.Stale:
+				b.status.SetText("[yellow]" + tview.Escape(fmt.Sprintf("Server unreachable, showing %d courses as fetched %s", len(courses), fetchedAt(freshness.FetchedAt))))
+			case len(report.Conflicts)+len(report.Rejected) > 0:
+				b.status.SetText("[red]" + tview.Escape(fmt.Sprintf("%d courses loaded, %d offline edits not sent, see the outbox command", len(courses), len(report.Conflicts)+len(report.Rejected))))
+			case len(report.Sent) > 0:
+				b.setStatus(fmt.Sprintf("%d courses loaded, %d offline edits sent", len(courses), len(report.Sent)))
+			default:
+				b.setStatus(fmt.Sprintf("%d courses loaded", len(courses)))
+			}
+		})
+	}()
+}
+
+//matches reports whether every search word appears in the course's code, title, lecturer, dates or description
+func matches(course client.CourseInfo, words []string) bool {
+	text := strings.ToLower(strings.Join([]string{strconv.Itoa(course.Code), course.Title, course.Lecturer, course.Dates, course.Description}, " "))
+	for _, word := range words {
+		if !strings.Contains(text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+//filter lists the courses matching the search, keeping the selected course selected if it is still listed
+func (b *courseBrowser) filter() {
+	selected, hadSelection := b.selected()
+	words := strings.Fields(strings.ToLower(b.search.GetText()))
+	b.shown = b.shown[:0]
+	for _, course := range b.courses {
+		if matches(course, words) {
+			b.shown = append(b.shown, course)
+		}
+	}
+
+	b.list.Clear()
+	for i, name := range []string{"Code", "Title", "Lecturer", "Dates"} {
+		b.list.SetCell(0, i, tview.NewTableCell(name).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+	}
+	row := 1
+	for i, course := range b.shown {
+		b.list.SetCell(i+1, 0, tview.NewTableCell(strconv.Itoa(course.Code)))
+		b.list.SetCell(i+1, 1, tview.NewTableCell(course.Title).SetExpansion(1))
+		b.list.SetCell(i+1, 2, tview.NewTableCell(course.Lecturer))
+		b.list.SetCell(i+1, 3, tview.NewTableCell(course.Dates))
+		if hadSelection && course.Code == selected.Code {
+			row = i + 1
+		}
+	}
+	b.list.SetTitle(fmt.Sprintf(" Courses (%d of %d) ", len(b.shown), len(b.courses)))
+	b.list.Select(row, 0)
+}
+
+//selected returns the course selected in the list, if any
+func (b *courseBrowser) selected() (client.CourseInfo, bool) {
+	row, _ := b.list.GetSelection()
+	if row < 1 || row > len(b.shown) {
+		return client.CourseInfo{}, false
+	}
+	return b.shown[row-1], true
+}
+
+//showDetail shows every field of the selected course in the detail pane
+func (b *courseBrowser) showDetail() {
+	course, ok := b.selected()
+	if !ok {
+		b.detail.SetText("No course selected")
+		return
+	}
+	var text strings.Builder
+	fields := []struct{ name, value string }{
+		{"Code", strconv.Itoa(course.Code)},
+		{"Title", course.Title},
+		{"Dates", course.Dates},
+		{"Lecturer", course.Lecturer},
+		{"Description", course.Description},
+	}
+	for _, f := range fields {
+		fmt.Fprintf(&text, "[yellow]%s[-]\n%s\n\n", f.name, tview.Escape(f.value))
+	}
+	b.detail.SetText(text.String()).ScrollToBeginning()
+}
+
+func (b *courseBrowser) edit() {
+	if course, ok := b.selected(); ok {
+		b.openForm(&course)
+	}
+}
+
+//centered places p in the middle of the screen at the given size
+func centered(p tview.Primitive, width, height int) tview.Primitive {
+	return tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(p, height, 0, true).
+			AddItem(nil, 0, 1, false), width, 0, true).
+		AddItem(nil, 0, 1, false)
+}
+
+//closeDialog removes a form or dialog and returns to the list
+func (b *courseBrowser) closeDialog(name string) {
+	b.pages.RemovePage(name)
+	b.app.SetFocus(b.list)
+}
+
+//openForm shows a form to add a course, or to edit course when it is not nil.
+//The fields are checked like the other console commands before anything is sent.
+func (b *courseBrowser) openForm(course *client.CourseInfo) {
+	creating := course == nil
+	if creating {
+		course = &client.CourseInfo{}
+	}
+	form := tview.NewForm()
+	if creating {
+		form.AddInputField("Code", "", 10, tview.InputFieldInteger, nil)
+		form.SetTitle(" New course ")
+	} else {
+		form.SetTitle(fmt.Sprintf(" Edit course %d ", course.Code))
+	}
+	form.AddInputField("Title", course.Title, 0, nil, nil)
+	form.AddInputField("Dates", course.Dates, 0, nil, nil)
+	form.AddInputField("Lecturer", course.Lecturer, 0, nil, nil)
+	form.AddInputField("Description", course.Description, 0, nil, nil)
+	text := func(label string) string {
+		return form.GetFormItemByLabel(label).(*tview.InputField).GetText()
+	}
+
+	form.AddButton("Save", func() {
+		edited := client.CourseInfo{
+			Code:        course.Code,
+			Title:       text("Title"),
+			Dates:       text("Dates"),
+			Lecturer:    text("Lecturer"),
+			Description: text("Description"),
+		}
+		if creating {
+			edited.Code, _ = strconv.Atoi(text("Code"))
+		}
+		if err := checkCourse(&edited, false); err != nil {
+			b.status.SetText("[red]" + tview.Escape("Check the course: "+err.Error()))
+			return
+		}
+		b.save(edited, creating)
+	})
+	form.AddButton("Cancel", func() { b.closeDialog("form") })
+	form.SetCancelFunc(func() { b.closeDialog("form") })
+	form.SetBorder(true)
+
+	b.pages.AddPage("form", centered(form, 70, 15), true, true)
+	b.app.SetFocus(form)
+}
+
+//save sends a course from the form. The form stays open if the server refuses it, so it can be corrected.
+func (b *courseBrowser) save(course client.CourseInfo, creating bool) {
+	b.setStatus("Saving...")
+	go func() {
+		var queued bool
+		var err error
+		if creating {
+			queued, err = courseAPI.Create(b.ctx, course)
+		} else {
+			_, queued, err = courseAPI.Update(b.ctx, course)
+		}
+		b.app.QueueUpdateDraw(func() {
+			if err != nil {
+				b.fail("Saving course "+strconv.Itoa(course.Code), err)
+				return
+			}
+			b.closeDialog("form")
+			b.replace(course)
+			switch {
+			case queued:
+				b.status.SetText("[yellow]" + tview.Escape("Server unreachable, course "+strconv.Itoa(course.Code)+" is saved offline and sent on reload"))
+			case creating:
+				b.setStatus("Course added: " + strconv.Itoa(course.Code))
+			default:
+				b.setStatus("Course updated: " + strconv.Itoa(course.Code))
+			}
+		})
+	}()
+}
+
+//replace puts a saved course into the list, in code order, and selects it
+func (b *courseBrowser) replace(course client.CourseInfo) {
+	i := 0
+	for i < len(b.courses) && b.courses[i].Code < course.Code {
+		i++
+	}
+	if i < len(b.courses) && b.courses[i].Code == course.Code {
+		b.courses[i] = course
+	} else {
+		b.courses = append(b.courses, client.CourseInfo{})
+		copy(b.courses[i+1:], b.courses[i:])
+		b.courses[i] = course
+	}
+	b.filter()
+	for row, shown := range b.shown {
+		if shown.Code == course.Code {
+			b.list.Select(row+1, 0)
+		}
+	}
+}
+
+//confirmDelete asks before deleting the selected course
+func (b *courseBrowser) confirmDelete() {
+	course, ok := b.selected()
+	if !ok {
+		return
+	}
+	dialog := tview.NewModal().
+		SetText(fmt.Sprintf("Delete course %d, %s?", course.Code, course.Title)).
+		AddButtons([]string{"Delete", "Cancel"}).
+		SetFocus(1).
+		SetDoneFunc(func(index int, label string) {
+			b.closeDialog("confirm")
+			if label == "Delete" {
+				b.remove(course.Code)
+			}
+		})
+	b.pages.AddPage("confirm", dialog, true, true)
+	b.app.SetFocus(dialog)
+}
+
+func (b *courseBrowser) remove(code int) {
+	b.setStatus("Deleting...")
+	go func() {
+		queued, err := courseAPI.Delete(b.ctx, code)
+		b.app.QueueUpdateDraw(func() {
+			if err != nil {
+				b.fail("Deleting course "+strconv.Itoa(code), err)
+				return
+			}
+			for i := range b.courses {
+				if b.courses[i].Code == code {
+					b.courses = append(b.courses[:i], b.courses[i+1:]...)
+					break
+				}
+			}
+			b.filter()
+			if queued {
+				b.status.SetText("[yellow]" + tview.Escape("Server unreachable, course "+strconv.Itoa(code)+" is deleted offline and on the server once it is reloaded"))
+				return
+			}
+			b.setStatus("Course deleted: " + strconv.Itoa(code))
+		})
+	}()
+}
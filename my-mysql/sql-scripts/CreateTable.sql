@@ -1 +1,4 @@
-CREATE TABLE CourseInfo (Code INT NOT NULL PRIMARY KEY, Title VARCHAR (30), Dates VARCHAR (30), Lecturer VARCHAR (50), Description VARCHAR (250));
\ No newline at end of file
+CREATE TABLE CourseInfo (Code INT NOT NULL PRIMARY KEY, Title VARCHAR (30), Dates VARCHAR (30), Lecturer VARCHAR (50), Description VARCHAR (250), UpdatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, FULLTEXT KEY CourseSearch (Title, Description, Lecturer));
+CREATE TABLE Outbox (ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY, Type VARCHAR (30) NOT NULL, Payload TEXT NOT NULL, CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, ProcessedAt TIMESTAMP NULL, KEY OutboxPending (ProcessedAt, ID));
+CREATE TABLE Webhooks (ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY, URL VARCHAR (2048) NOT NULL, Events VARCHAR (100) NOT NULL, Secret VARCHAR (64) NOT NULL, CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP);
+CREATE TABLE WebhookDeliveries (ID BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY, WebhookID BIGINT NOT NULL, EventID BIGINT NOT NULL, Event VARCHAR (30) NOT NULL, Payload TEXT NOT NULL, Status VARCHAR (10) NOT NULL, Attempts INT NOT NULL DEFAULT 0, NextAttemptAt DATETIME (6) NOT NULL, LastStatusCode INT NOT NULL DEFAULT 0, LastError VARCHAR (500) NOT NULL DEFAULT '', CreatedAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, KEY DeliveriesDue (Status, NextAttemptAt), FOREIGN KEY (WebhookID) REFERENCES Webhooks (ID) ON DELETE CASCADE);
\ No newline at end of file